@@ -0,0 +1,245 @@
+// Command fakeuci is a tiny UCI engine for exercising the engine service
+// without a real engine. It scores each legal move by the material it wins
+// and reports the best ones as MultiPV lines, one iteration per depth.
+// Searches limited only by movetime run until it elapses or a stop arrives,
+// so callers can exercise timeouts and cancellation. Setting the CrashDepth
+// option makes it exit mid-search once it has reported that depth, to
+// exercise recovery from an engine that dies.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+)
+
+var pieceValues = map[chess.PieceType]int{
+	chess.Pawn:   100,
+	chess.Knight: 300,
+	chess.Bishop: 300,
+	chess.Rook:   500,
+	chess.Queen:  900,
+}
+
+type engine struct {
+	out        *bufio.Writer
+	outMu      sync.Mutex
+	position   *chess.Position
+	multiPV    int
+	crashDepth int
+
+	stop    chan struct{}
+	running sync.WaitGroup
+}
+
+func main() {
+	e := &engine{
+		out:      bufio.NewWriter(os.Stdout),
+		position: chess.NewPosition(),
+		multiPV:  1,
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "uci":
+			e.println("id name FakeUCI")
+			e.println("id author openings-master")
+			e.println("option name MultiPV type spin default 1 min 1 max 500")
+			e.println("option name CrashDepth type spin default 0 min 0 max 500")
+			e.println("uciok")
+		case "isready":
+			e.println("readyok")
+		case "setoption":
+			e.setOption(fields[1:])
+		case "ucinewgame":
+			e.position = chess.NewPosition()
+		case "position":
+			e.setPosition(fields[1:])
+		case "go":
+			e.stopSearch()
+			e.stop = make(chan struct{})
+			e.running.Add(1)
+			go e.search(e.position, e.multiPV, e.crashDepth, fields[1:], e.stop)
+		case "stop":
+			e.stopSearch()
+		case "quit":
+			e.stopSearch()
+			return
+		}
+	}
+	e.stopSearch()
+}
+
+func (e *engine) println(line string) {
+	e.outMu.Lock()
+	defer e.outMu.Unlock()
+	e.out.WriteString(line + "\n")
+	e.out.Flush()
+}
+
+func (e *engine) stopSearch() {
+	if e.stop != nil {
+		close(e.stop)
+		e.stop = nil
+	}
+	e.running.Wait()
+}
+
+func (e *engine) setOption(args []string) {
+	// setoption name <name> value <value>
+	if len(args) != 4 || args[0] != "name" || args[2] != "value" {
+		return
+	}
+	n, err := strconv.Atoi(args[3])
+	if err != nil {
+		return
+	}
+	switch {
+	case strings.EqualFold(args[1], "MultiPV") && n > 0:
+		e.multiPV = n
+	case strings.EqualFold(args[1], "CrashDepth") && n >= 0:
+		e.crashDepth = n
+	}
+}
+
+func (e *engine) setPosition(args []string) {
+	if len(args) == 0 {
+		return
+	}
+
+	var pos *chess.Position
+	rest := args[1:]
+	switch args[0] {
+	case "startpos":
+		pos = chess.NewPosition()
+	case "fen":
+		end := len(rest)
+		for i, arg := range rest {
+			if arg == "moves" {
+				end = i
+				break
+			}
+		}
+		var err error
+		pos, err = chess.ParseFEN(strings.Join(rest[:end], " "))
+		if err != nil {
+			e.println("info string invalid fen: " + err.Error())
+			return
+		}
+		rest = rest[end:]
+	default:
+		return
+	}
+
+	if len(rest) > 0 && rest[0] == "moves" {
+		for _, uci := range rest[1:] {
+			m, err := pos.ParseUCI(uci)
+			if err != nil {
+				e.println("info string illegal move: " + uci)
+				return
+			}
+			pos, _ = pos.Apply(m)
+		}
+	}
+	e.position = pos
+}
+
+type scoredMove struct {
+	uci   string
+	score int
+}
+
+func (e *engine) search(pos *chess.Position, multiPV, crashDepth int, args []string, stop <-chan struct{}) {
+	defer e.running.Done()
+
+	depth, moveTime := 0, time.Duration(0)
+	for i := 0; i+1 < len(args); i++ {
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			continue
+		}
+		switch args[i] {
+		case "depth":
+			depth = n
+		case "movetime":
+			moveTime = time.Duration(n) * time.Millisecond
+		}
+	}
+	waitForTime := depth <= 0 && moveTime > 0
+	if depth <= 0 {
+		depth = 1
+	}
+
+	moves := rankMoves(pos)
+	if len(moves) == 0 {
+		score := "cp 0"
+		if pos.InCheck() {
+			score = "mate 0"
+		}
+		e.println("info depth 0 score " + score)
+		e.println("bestmove (none)")
+		return
+	}
+
+	for d := 1; d <= depth; d++ {
+		for i, m := range moves[:min(multiPV, len(moves))] {
+			e.println(fmt.Sprintf("info depth %d seldepth %d multipv %d score cp %d nodes %d pv %s", d, d, i+1, m.score, d*len(moves), m.uci))
+		}
+		if d == crashDepth {
+			os.Exit(1)
+		}
+	}
+
+	if waitForTime {
+		select {
+		case <-time.After(moveTime):
+		case <-stop:
+		}
+	}
+	e.println("bestmove " + moves[0].uci)
+}
+
+// rankMoves scores every legal move by the material balance after it, from
+// the mover's point of view, best first.
+func rankMoves(pos *chess.Position) []scoredMove {
+	var moves []scoredMove
+	for _, m := range pos.LegalMoves() {
+		next, err := pos.Apply(m)
+		if err != nil {
+			continue
+		}
+		moves = append(moves, scoredMove{uci: m.UCI(), score: material(next, pos.Turn)})
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return moves[i].score > moves[j].score
+	})
+	return moves
+}
+
+func material(pos *chess.Position, side chess.Color) int {
+	total := 0
+	for sq := chess.Square(0); sq < 64; sq++ {
+		piece := pos.PieceAt(sq)
+		if piece.IsEmpty() {
+			continue
+		}
+		if piece.Color == side {
+			total += pieceValues[piece.Type]
+		} else {
+			total -= pieceValues[piece.Type]
+		}
+	}
+	return total
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -34,7 +36,22 @@ func main() {
 		log.Printf("Warning: Failed to create user indexes: %v", err)
 	}
 
-	repertoireRepo := repository.NewRepertoireRepository()
+	sessionRepo := repository.NewSessionRepository()
+	if err := sessionRepo.CreateIndexes(ctx); err != nil {
+		log.Printf("Warning: Failed to create session indexes: %v", err)
+	}
+
+	mailTokenRepo := repository.NewMailTokenRepository()
+	if err := mailTokenRepo.CreateIndexes(ctx); err != nil {
+		log.Printf("Warning: Failed to create mail token indexes: %v", err)
+	}
+
+	loginAttemptRepo := repository.NewLoginAttemptRepository()
+	if err := loginAttemptRepo.CreateIndexes(ctx); err != nil {
+		log.Printf("Warning: Failed to create login attempt indexes: %v", err)
+	}
+
+	repertoireRepo := repository.NewRepertoireRepository(services.IndexPositions)
 	if err := repertoireRepo.CreateIndexes(ctx); err != nil {
 		log.Printf("Warning: Failed to create repertoire indexes: %v", err)
 	}
@@ -44,12 +61,126 @@ func main() {
 		log.Printf("Warning: Failed to create practice indexes: %v", err)
 	}
 
+	reviewRepo := repository.NewReviewRepository()
+	if err := reviewRepo.CreateIndexes(ctx); err != nil {
+		log.Printf("Warning: Failed to create review indexes: %v", err)
+	}
+
+	revisionRepo := repository.NewRevisionRepository()
+	if err := revisionRepo.CreateIndexes(ctx); err != nil {
+		log.Printf("Warning: Failed to create revision indexes: %v", err)
+	}
+
+	positionRepo := repository.NewPositionRepository()
+	if err := positionRepo.CreateIndexes(ctx); err != nil {
+		log.Printf("Warning: Failed to create position indexes: %v", err)
+	}
+
+	gameRepo := repository.NewGameRepository()
+	if err := gameRepo.CreateIndexes(ctx); err != nil {
+		log.Printf("Warning: Failed to create game indexes: %v", err)
+	}
+
+	explorerCacheRepo := repository.NewExplorerCacheRepository()
+	if err := explorerCacheRepo.CreateIndexes(ctx); err != nil {
+		log.Printf("Warning: Failed to create explorer cache indexes: %v", err)
+	}
+
+	evalRepo := repository.NewEvalRepository()
+	if err := evalRepo.CreateIndexes(ctx); err != nil {
+		log.Printf("Warning: Failed to create eval indexes: %v", err)
+	}
+
+	annotationJobRepo := repository.NewAnnotationJobRepository()
+	if err := annotationJobRepo.CreateIndexes(ctx); err != nil {
+		log.Printf("Warning: Failed to create annotation job indexes: %v", err)
+	}
+
+	// Run data migrations
+	if n, err := repertoireRepo.BackfillVersions(ctx); err != nil {
+		log.Printf("Warning: Failed to backfill repertoire versions: %v", err)
+	} else if n > 0 {
+		log.Printf("Backfilled versions of %d repertoires", n)
+	}
+	if err := backfillNodeIDs(ctx, repertoireRepo); err != nil {
+		log.Printf("Warning: Failed to backfill move node IDs: %v", err)
+	}
+
 	// Initialize services
-	authService := services.NewAuthService(config.AppConfig.JWTSecret)
+	authService := services.NewAuthService(config.AppConfig.JWTSecret, config.AppConfig.EmailVerificationTTL, config.AppConfig.PasswordResetTTL)
 	openaiService := services.NewOpenAIService(config.AppConfig.OpenAIAPIKey, config.AppConfig.OpenAIModel, config.AppConfig.OpenAIBaseURL)
+	explorerService := services.NewExplorerService(config.AppConfig.LichessExplorerURL, config.AppConfig.LichessAPIToken, explorerCacheRepo, config.AppConfig.ExplorerCacheTTL)
+
+	moveClassifier := services.NewMoveClassifier(services.MoveThresholds{
+		Best:       config.AppConfig.BestMoveMaxLoss,
+		Good:       config.AppConfig.GoodMoveMaxLoss,
+		Inaccuracy: config.AppConfig.InaccuracyMaxLoss,
+		Mistake:    config.AppConfig.MistakeMaxLoss,
+	})
+
+	engineService := services.NewEngineService(
+		config.AppConfig.EnginePath,
+		config.AppConfig.EnginePoolSize,
+		config.AppConfig.EngineMaxDepth,
+		config.AppConfig.EngineMaxMoveTime,
+		map[string]string{
+			"Threads": strconv.Itoa(config.AppConfig.EngineThreads),
+			"Hash":    strconv.Itoa(config.AppConfig.EngineHashMB),
+		},
+	)
+	defer engineService.Close()
+
+	var mailer services.Mailer
+	switch config.AppConfig.MailDriver {
+	case "smtp":
+		mailer = services.NewSMTPMailer(
+			config.AppConfig.SMTPHost,
+			config.AppConfig.SMTPPort,
+			config.AppConfig.SMTPUsername,
+			config.AppConfig.SMTPPassword,
+			config.AppConfig.MailFrom,
+		)
+	case "file":
+		mailer = services.NewFileMailer(config.AppConfig.MailDir, config.AppConfig.MailFrom)
+	default:
+		log.Fatalf("Unknown MAIL_DRIVER %q", config.AppConfig.MailDriver)
+	}
+	accountMailer := services.NewAccountMailer(mailer, config.AppConfig.AppURL)
+
+	loginLockout := &services.LoginLockout{
+		Account: services.LockoutPolicy{
+			MaxFailures: config.AppConfig.LoginMaxFailuresPerAccount,
+			Window:      config.AppConfig.LoginFailureWindow,
+			BaseLockout: config.AppConfig.LoginLockoutBase,
+			MaxLockout:  config.AppConfig.LoginLockoutMax,
+		},
+		IP: services.LockoutPolicy{
+			MaxFailures: config.AppConfig.LoginMaxFailuresPerIP,
+			Window:      config.AppConfig.LoginFailureWindow,
+			BaseLockout: config.AppConfig.LoginLockoutBase,
+			MaxLockout:  config.AppConfig.LoginLockoutMax,
+		},
+		MailAccount: services.LockoutPolicy{
+			MaxFailures: config.AppConfig.MailMaxPerAccount,
+			Window:      config.AppConfig.MailLimitWindow,
+			BaseLockout: config.AppConfig.MailLimitWindow,
+			MaxLockout:  config.AppConfig.LoginLockoutMax,
+		},
+		MailIP: services.LockoutPolicy{
+			MaxFailures: config.AppConfig.MailMaxPerIP,
+			Window:      config.AppConfig.MailLimitWindow,
+			BaseLockout: config.AppConfig.MailLimitWindow,
+			MaxLockout:  config.AppConfig.LoginLockoutMax,
+		},
+	}
 
 	// Setup router
-	r := router.Setup(authService, openaiService)
+	r := router.Setup(authService, openaiService, explorerService, moveClassifier, engineService, loginLockout, accountMailer, config.AppConfig.AdminEmails, config.AppConfig.TrustedProxies)
+
+	go failInterruptedAnnotations(annotationJobRepo)
+	if config.AppConfig.EvalStoreMaxEntries > 0 && config.AppConfig.EvalStorePruneInterval > 0 {
+		go pruneEvals(evalRepo, int64(config.AppConfig.EvalStoreMaxEntries), config.AppConfig.EvalStorePruneInterval)
+	}
 
 	// Start server
 	port := config.AppConfig.Port
@@ -68,3 +199,72 @@ func main() {
 	<-quit
 	log.Println("Shutting down server...")
 }
+
+// backfillNodeIDs gives an ID to every move node stored before nodes had
+// one. It runs at startup and only touches repertoires that need it.
+func backfillNodeIDs(ctx context.Context, repertoireRepo *repository.RepertoireRepository) error {
+	repertoires, err := repertoireRepo.FindMissingNodeIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	migrated := 0
+	for i := range repertoires {
+		repertoire := &repertoires[i]
+		for j := range repertoire.Openings {
+			services.AssignNodeIDs(repertoire.Openings[j].Moves)
+		}
+		// A repertoire edited meanwhile is left for the next startup
+		err := repertoireRepo.BackfillOpenings(ctx, repertoire)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		log.Printf("Backfilled move node IDs in %d repertoires", migrated)
+	}
+	return nil
+}
+
+// failInterruptedAnnotations marks as failed the annotation jobs of server
+// instances that stopped, once their lease has run out.
+func failInterruptedAnnotations(jobRepo *repository.AnnotationJobRepository) {
+	ticker := time.NewTicker(repository.AnnotationJobLease)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		n, err := jobRepo.FailInterrupted(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("Warning: Failed to close interrupted annotation jobs: %v", err)
+		} else if n > 0 {
+			log.Printf("Marked %d interrupted annotation jobs as failed", n)
+		}
+		<-ticker.C
+	}
+}
+
+// pruneEvals keeps the shared eval store within maxEntries, evicting the
+// least recently read positions.
+func pruneEvals(evalRepo *repository.EvalRepository, maxEntries int64, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		n, err := evalRepo.Prune(ctx, maxEntries)
+		cancel()
+		if err != nil {
+			log.Printf("Warning: Failed to prune evals: %v", err)
+		} else if n > 0 {
+			log.Printf("Evicted %d evals", n)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,212 @@
+package chess
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseFEN parses and validates a FEN string. The halfmove clock and fullmove
+// number are optional and default to 0 and 1.
+func ParseFEN(fen string) (*Position, error) {
+	fields := strings.Fields(fen)
+	if len(fields) != 4 && len(fields) != 6 {
+		return nil, fmt.Errorf("%w: expected 6 fields, got %d", ErrInvalidFEN, len(fields))
+	}
+
+	p := &Position{EnPassant: NoSquare, FullmoveNumber: 1}
+
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return nil, fmt.Errorf("%w: expected 8 ranks, got %d", ErrInvalidFEN, len(ranks))
+	}
+	for i, row := range ranks {
+		rank := 7 - i
+		file := 0
+		for j := 0; j < len(row); j++ {
+			c := row[j]
+			if c >= '1' && c <= '8' {
+				file += int(c - '0')
+				continue
+			}
+			t := pieceTypeFromLetter(c)
+			if t == NoPieceType {
+				return nil, fmt.Errorf("%w: unknown piece %q", ErrInvalidFEN, c)
+			}
+			if file > 7 {
+				return nil, fmt.Errorf("%w: rank %d has more than 8 squares", ErrInvalidFEN, rank+1)
+			}
+			color := White
+			if c >= 'a' {
+				color = Black
+			}
+			p.board[NewSquare(file, rank)] = Piece{Type: t, Color: color}
+			file++
+		}
+		if file != 8 {
+			return nil, fmt.Errorf("%w: rank %d has %d squares", ErrInvalidFEN, rank+1, file)
+		}
+	}
+
+	switch fields[1] {
+	case "w":
+		p.Turn = White
+	case "b":
+		p.Turn = Black
+	default:
+		return nil, fmt.Errorf("%w: invalid side to move %q", ErrInvalidFEN, fields[1])
+	}
+
+	if fields[2] != "-" {
+		for i := 0; i < len(fields[2]); i++ {
+			var right CastlingRights
+			switch fields[2][i] {
+			case 'K':
+				right = WhiteKingside
+			case 'Q':
+				right = WhiteQueenside
+			case 'k':
+				right = BlackKingside
+			case 'q':
+				right = BlackQueenside
+			default:
+				return nil, fmt.Errorf("%w: invalid castling rights %q", ErrInvalidFEN, fields[2])
+			}
+			p.Castling |= right
+		}
+	}
+
+	if fields[3] != "-" {
+		sq, err := ParseSquare(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFEN, err)
+		}
+		if (p.Turn == White && sq.Rank() != 5) || (p.Turn == Black && sq.Rank() != 2) {
+			return nil, fmt.Errorf("%w: impossible en passant square %s", ErrInvalidFEN, sq)
+		}
+		p.EnPassant = sq
+	}
+
+	if len(fields) == 6 {
+		halfmove, err := strconv.Atoi(fields[4])
+		if err != nil || halfmove < 0 {
+			return nil, fmt.Errorf("%w: invalid halfmove clock %q", ErrInvalidFEN, fields[4])
+		}
+		fullmove, err := strconv.Atoi(fields[5])
+		if err != nil || fullmove < 1 {
+			return nil, fmt.Errorf("%w: invalid fullmove number %q", ErrInvalidFEN, fields[5])
+		}
+		p.HalfmoveClock = halfmove
+		p.FullmoveNumber = fullmove
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Position) validate() error {
+	var kings [2]int
+	for sq := Square(0); sq < 64; sq++ {
+		piece := p.board[sq]
+		if piece.Type == King {
+			kings[piece.Color]++
+		}
+		if piece.Type == Pawn && (sq.Rank() == 0 || sq.Rank() == 7) {
+			return fmt.Errorf("%w: pawn on %s", ErrInvalidFEN, sq)
+		}
+	}
+	if kings[White] != 1 || kings[Black] != 1 {
+		return fmt.Errorf("%w: each side must have exactly one king", ErrInvalidFEN)
+	}
+
+	home := []struct {
+		right CastlingRights
+		king  Square
+		rook  Square
+		color Color
+	}{
+		{WhiteKingside, NewSquare(4, 0), NewSquare(7, 0), White},
+		{WhiteQueenside, NewSquare(4, 0), NewSquare(0, 0), White},
+		{BlackKingside, NewSquare(4, 7), NewSquare(7, 7), Black},
+		{BlackQueenside, NewSquare(4, 7), NewSquare(0, 7), Black},
+	}
+	for _, h := range home {
+		if p.Castling&h.right == 0 {
+			continue
+		}
+		if p.board[h.king] != (Piece{Type: King, Color: h.color}) || p.board[h.rook] != (Piece{Type: Rook, Color: h.color}) {
+			return fmt.Errorf("%w: castling right %s without king and rook on their original squares", ErrInvalidFEN, h.right)
+		}
+	}
+
+	if p.EnPassant != NoSquare {
+		// The pawn that just moved two squares must be in front of the target.
+		pawnRank := 4
+		if p.Turn == Black {
+			pawnRank = 3
+		}
+		pawn := p.board[NewSquare(p.EnPassant.File(), pawnRank)]
+		if pawn != (Piece{Type: Pawn, Color: p.Turn.Other()}) || !p.board[p.EnPassant].IsEmpty() {
+			return fmt.Errorf("%w: impossible en passant square %s", ErrInvalidFEN, p.EnPassant)
+		}
+	}
+
+	if king := p.kingSquare(p.Turn.Other()); p.isAttacked(king, p.Turn) {
+		return fmt.Errorf("%w: side not to move is in check", ErrInvalidFEN)
+	}
+	return nil
+}
+
+// NormalizeFEN parses a FEN and returns its position key, so that FENs
+// differing only in move counters or an unusable en passant square compare equal.
+func NormalizeFEN(fen string) (string, error) {
+	p, err := ParseFEN(fen)
+	if err != nil {
+		return "", err
+	}
+	return p.Key(), nil
+}
+
+func (p *Position) FEN() string {
+	return fmt.Sprintf("%s %d %d", p.Key(), p.HalfmoveClock, p.FullmoveNumber)
+}
+
+// Key returns the first four FEN fields: everything that identifies the
+// position itself, without the move counters. Two positions reached through
+// different move orders share the same key.
+func (p *Position) Key() string {
+	var b strings.Builder
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := 0; file < 8; file++ {
+			piece := p.board[NewSquare(file, rank)]
+			if piece.IsEmpty() {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				b.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			b.WriteByte(piece.FENRune())
+		}
+		if empty > 0 {
+			b.WriteByte(byte('0' + empty))
+		}
+		if rank > 0 {
+			b.WriteByte('/')
+		}
+	}
+
+	if p.Turn == White {
+		b.WriteString(" w ")
+	} else {
+		b.WriteString(" b ")
+	}
+	b.WriteString(p.Castling.String())
+	b.WriteByte(' ')
+	b.WriteString(p.enPassantTarget().String())
+	return b.String()
+}
@@ -0,0 +1,214 @@
+package chess
+
+var (
+	knightOffsets = [8][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	kingOffsets   = [8][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	bishopDirs    = [4][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+	rookDirs      = [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	promotions    = [4]PieceType{Queen, Rook, Bishop, Knight}
+)
+
+// LegalMoves returns every legal move for the side to move.
+func (p *Position) LegalMoves() []Move {
+	pseudo := p.pseudoLegalMoves()
+	legal := make([]Move, 0, len(pseudo))
+	for _, m := range pseudo {
+		next := p.play(m)
+		if !next.isAttacked(next.kingSquare(p.Turn), p.Turn.Other()) {
+			legal = append(legal, m)
+		}
+	}
+	return legal
+}
+
+func (p *Position) pseudoLegalMoves() []Move {
+	moves := make([]Move, 0, 48)
+	for from := Square(0); from < 64; from++ {
+		piece := p.board[from]
+		if piece.IsEmpty() || piece.Color != p.Turn {
+			continue
+		}
+		switch piece.Type {
+		case Pawn:
+			moves = p.pawnMoves(moves, from)
+		case Knight:
+			moves = p.stepMoves(moves, from, knightOffsets[:])
+		case Bishop:
+			moves = p.slideMoves(moves, from, bishopDirs[:])
+		case Rook:
+			moves = p.slideMoves(moves, from, rookDirs[:])
+		case Queen:
+			moves = p.slideMoves(moves, from, bishopDirs[:])
+			moves = p.slideMoves(moves, from, rookDirs[:])
+		case King:
+			moves = p.stepMoves(moves, from, kingOffsets[:])
+			moves = p.castlingMoves(moves, from)
+		}
+	}
+	return moves
+}
+
+func (p *Position) pawnMoves(moves []Move, from Square) []Move {
+	dir, startRank, lastRank := 1, 1, 7
+	if p.Turn == Black {
+		dir, startRank, lastRank = -1, 6, 0
+	}
+
+	add := func(to Square) {
+		if to.Rank() == lastRank {
+			for _, promo := range promotions {
+				moves = append(moves, Move{From: from, To: to, Promotion: promo})
+			}
+			return
+		}
+		moves = append(moves, Move{From: from, To: to})
+	}
+
+	one := NewSquare(from.File(), from.Rank()+dir)
+	if one != NoSquare && p.board[one].IsEmpty() {
+		add(one)
+		two := NewSquare(from.File(), from.Rank()+2*dir)
+		if from.Rank() == startRank && p.board[two].IsEmpty() {
+			add(two)
+		}
+	}
+
+	for _, df := range [2]int{-1, 1} {
+		to := NewSquare(from.File()+df, from.Rank()+dir)
+		if to == NoSquare {
+			continue
+		}
+		target := p.board[to]
+		if (!target.IsEmpty() && target.Color != p.Turn) || to == p.EnPassant {
+			add(to)
+		}
+	}
+	return moves
+}
+
+func (p *Position) stepMoves(moves []Move, from Square, offsets [][2]int) []Move {
+	for _, o := range offsets {
+		to := NewSquare(from.File()+o[0], from.Rank()+o[1])
+		if to == NoSquare {
+			continue
+		}
+		if target := p.board[to]; target.IsEmpty() || target.Color != p.Turn {
+			moves = append(moves, Move{From: from, To: to})
+		}
+	}
+	return moves
+}
+
+func (p *Position) slideMoves(moves []Move, from Square, dirs [][2]int) []Move {
+	for _, d := range dirs {
+		for i := 1; ; i++ {
+			to := NewSquare(from.File()+d[0]*i, from.Rank()+d[1]*i)
+			if to == NoSquare {
+				break
+			}
+			target := p.board[to]
+			if target.IsEmpty() {
+				moves = append(moves, Move{From: from, To: to})
+				continue
+			}
+			if target.Color != p.Turn {
+				moves = append(moves, Move{From: from, To: to})
+			}
+			break
+		}
+	}
+	return moves
+}
+
+func (p *Position) castlingMoves(moves []Move, from Square) []Move {
+	rank := 0
+	kingside, queenside := WhiteKingside, WhiteQueenside
+	if p.Turn == Black {
+		rank = 7
+		kingside, queenside = BlackKingside, BlackQueenside
+	}
+	if from != NewSquare(4, rank) || p.Castling&(kingside|queenside) == 0 {
+		return moves
+	}
+	enemy := p.Turn.Other()
+	if p.isAttacked(from, enemy) {
+		return moves
+	}
+
+	empty := func(files ...int) bool {
+		for _, f := range files {
+			if !p.board[NewSquare(f, rank)].IsEmpty() {
+				return false
+			}
+		}
+		return true
+	}
+	safe := func(files ...int) bool {
+		for _, f := range files {
+			if p.isAttacked(NewSquare(f, rank), enemy) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if p.Castling&kingside != 0 && empty(5, 6) && safe(5, 6) {
+		moves = append(moves, Move{From: from, To: NewSquare(6, rank)})
+	}
+	if p.Castling&queenside != 0 && empty(1, 2, 3) && safe(2, 3) {
+		moves = append(moves, Move{From: from, To: NewSquare(2, rank)})
+	}
+	return moves
+}
+
+// isAttacked reports whether any piece of color by attacks sq.
+func (p *Position) isAttacked(sq Square, by Color) bool {
+	if sq == NoSquare {
+		return false
+	}
+	file, rank := sq.File(), sq.Rank()
+
+	pawnRank := rank - 1
+	if by == Black {
+		pawnRank = rank + 1
+	}
+	for _, df := range [2]int{-1, 1} {
+		if p.PieceAt(NewSquare(file+df, pawnRank)) == (Piece{Type: Pawn, Color: by}) {
+			return true
+		}
+	}
+	for _, o := range knightOffsets {
+		if p.PieceAt(NewSquare(file+o[0], rank+o[1])) == (Piece{Type: Knight, Color: by}) {
+			return true
+		}
+	}
+	for _, o := range kingOffsets {
+		if p.PieceAt(NewSquare(file+o[0], rank+o[1])) == (Piece{Type: King, Color: by}) {
+			return true
+		}
+	}
+	if p.slidingAttack(file, rank, bishopDirs[:], by, Bishop) {
+		return true
+	}
+	return p.slidingAttack(file, rank, rookDirs[:], by, Rook)
+}
+
+func (p *Position) slidingAttack(file, rank int, dirs [][2]int, by Color, slider PieceType) bool {
+	for _, d := range dirs {
+		for i := 1; ; i++ {
+			to := NewSquare(file+d[0]*i, rank+d[1]*i)
+			if to == NoSquare {
+				break
+			}
+			piece := p.board[to]
+			if piece.IsEmpty() {
+				continue
+			}
+			if piece.Color == by && (piece.Type == slider || piece.Type == Queen) {
+				return true
+			}
+			break
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SAN returns the standard algebraic notation of a legal move, including the
+// check or checkmate suffix.
+func (p *Position) SAN(m Move) string {
+	san := p.sanWithoutSuffix(m)
+	next := p.play(m)
+	if next.InCheck() {
+		if len(next.LegalMoves()) == 0 {
+			return san + "#"
+		}
+		return san + "+"
+	}
+	return san
+}
+
+func (p *Position) sanWithoutSuffix(m Move) string {
+	piece := p.board[m.From]
+
+	if piece.Type == King {
+		switch m.To.File() - m.From.File() {
+		case 2:
+			return "O-O"
+		case -2:
+			return "O-O-O"
+		}
+	}
+
+	capture := !p.board[m.To].IsEmpty()
+	var b strings.Builder
+
+	if piece.Type == Pawn {
+		if m.From.File() != m.To.File() {
+			b.WriteByte(byte('a' + m.From.File()))
+			b.WriteByte('x')
+		}
+		b.WriteString(m.To.String())
+		if m.Promotion != NoPieceType {
+			b.WriteByte('=')
+			b.WriteString(m.Promotion.Letter())
+		}
+		return b.String()
+	}
+
+	b.WriteString(piece.Type.Letter())
+	b.WriteString(p.disambiguation(m))
+	if capture {
+		b.WriteByte('x')
+	}
+	b.WriteString(m.To.String())
+	return b.String()
+}
+
+// disambiguation returns the origin file, rank or square needed to tell m
+// apart from other legal moves of the same piece type to the same square.
+func (p *Position) disambiguation(m Move) string {
+	piece := p.board[m.From]
+	var others []Square
+	for _, other := range p.LegalMoves() {
+		if other.To == m.To && other.From != m.From && p.board[other.From] == piece {
+			others = append(others, other.From)
+		}
+	}
+	if len(others) == 0 {
+		return ""
+	}
+
+	sameFile, sameRank := false, false
+	for _, sq := range others {
+		if sq.File() == m.From.File() {
+			sameFile = true
+		}
+		if sq.Rank() == m.From.Rank() {
+			sameRank = true
+		}
+	}
+	switch {
+	case !sameFile:
+		return m.From.String()[:1]
+	case !sameRank:
+		return m.From.String()[1:]
+	default:
+		return m.From.String()
+	}
+}
+
+// ParseSAN resolves a move in standard algebraic notation against the legal
+// moves of the position. It is lenient about check suffixes, annotation
+// glyphs, missing capture marks, "=" in promotions and over-disambiguation.
+func (p *Position) ParseSAN(san string) (Move, error) {
+	s := strings.TrimRight(strings.TrimSpace(san), "+#!?")
+	if s == "" {
+		return Move{}, fmt.Errorf("%w: empty move", ErrInvalidMove)
+	}
+
+	legal := p.LegalMoves()
+
+	switch s {
+	case "O-O", "0-0", "O-O-O", "0-0-0":
+		file := 6
+		if len(s) == 5 {
+			file = 2
+		}
+		for _, m := range legal {
+			if p.board[m.From].Type == King && m.From.File() == 4 && m.To.File() == file {
+				return m, nil
+			}
+		}
+		return Move{}, fmt.Errorf("%w: %s", ErrIllegalMove, san)
+	}
+
+	pieceType := Pawn
+	if strings.IndexByte("NBRQK", s[0]) >= 0 {
+		pieceType = pieceTypeFromLetter(s[0])
+		s = s[1:]
+	}
+
+	promotion := NoPieceType
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		if i+2 != len(s) {
+			return Move{}, fmt.Errorf("%w: %s", ErrInvalidMove, san)
+		}
+		promotion = pieceTypeFromLetter(s[i+1])
+		if promotion == NoPieceType {
+			return Move{}, fmt.Errorf("%w: %s", ErrInvalidMove, san)
+		}
+		s = s[:i]
+	} else if n := len(s); n > 2 && strings.IndexByte("NBRQnbrq", s[n-1]) >= 0 {
+		promotion = pieceTypeFromLetter(s[n-1])
+		s = s[:n-1]
+	}
+	if promotion == Pawn || promotion == King {
+		return Move{}, fmt.Errorf("%w: %s", ErrInvalidMove, san)
+	}
+
+	if len(s) < 2 {
+		return Move{}, fmt.Errorf("%w: %s", ErrInvalidMove, san)
+	}
+	to, err := ParseSquare(s[len(s)-2:])
+	if err != nil {
+		return Move{}, fmt.Errorf("%w: %s", ErrInvalidMove, san)
+	}
+	s = strings.TrimRight(s[:len(s)-2], "x:-")
+
+	fromFile, fromRank := -1, -1
+	if len(s) > 2 {
+		return Move{}, fmt.Errorf("%w: %s", ErrInvalidMove, san)
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= 'a' && c <= 'h':
+			fromFile = int(c - 'a')
+		case c >= '1' && c <= '8':
+			fromRank = int(c - '1')
+		default:
+			return Move{}, fmt.Errorf("%w: %s", ErrInvalidMove, san)
+		}
+	}
+
+	var matches []Move
+	for _, m := range legal {
+		if m.To != to || p.board[m.From].Type != pieceType || m.Promotion != promotion {
+			continue
+		}
+		if fromFile >= 0 && m.From.File() != fromFile {
+			continue
+		}
+		if fromRank >= 0 && m.From.Rank() != fromRank {
+			continue
+		}
+		matches = append(matches, m)
+	}
+
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		if pieceType == Pawn && promotion == NoPieceType && (to.Rank() == 0 || to.Rank() == 7) {
+			return Move{}, fmt.Errorf("%w: %s is missing a promotion piece", ErrInvalidMove, san)
+		}
+		return Move{}, fmt.Errorf("%w: %s", ErrIllegalMove, san)
+	default:
+		return Move{}, fmt.Errorf("%w: %s", ErrAmbiguousMove, san)
+	}
+}
+
+// ParseUCI resolves a move in UCI notation, e.g. "g1f3" or "a7a8q".
+func (p *Position) ParseUCI(uci string) (Move, error) {
+	s := strings.TrimSpace(uci)
+	if len(s) != 4 && len(s) != 5 {
+		return Move{}, fmt.Errorf("%w: %s", ErrInvalidMove, uci)
+	}
+	from, err := ParseSquare(s[0:2])
+	if err != nil {
+		return Move{}, fmt.Errorf("%w: %s", ErrInvalidMove, uci)
+	}
+	to, err := ParseSquare(s[2:4])
+	if err != nil {
+		return Move{}, fmt.Errorf("%w: %s", ErrInvalidMove, uci)
+	}
+	m := Move{From: from, To: to}
+	if len(s) == 5 {
+		m.Promotion = pieceTypeFromLetter(s[4])
+		if m.Promotion == NoPieceType || m.Promotion == Pawn || m.Promotion == King {
+			return Move{}, fmt.Errorf("%w: %s", ErrInvalidMove, uci)
+		}
+	}
+	if !p.IsLegal(m) {
+		return Move{}, fmt.Errorf("%w: %s", ErrIllegalMove, uci)
+	}
+	return m, nil
+}
+
+// ParseMove accepts either UCI or SAN notation.
+func (p *Position) ParseMove(s string) (Move, error) {
+	if isUCI(strings.TrimSpace(s)) {
+		return p.ParseUCI(s)
+	}
+	return p.ParseSAN(s)
+}
+
+func isUCI(s string) bool {
+	if len(s) != 4 && len(s) != 5 {
+		return false
+	}
+	if _, err := ParseSquare(s[0:2]); err != nil {
+		return false
+	}
+	if _, err := ParseSquare(s[2:4]); err != nil {
+		return false
+	}
+	return len(s) == 4 || strings.IndexByte("qrbn", s[4]) >= 0
+}
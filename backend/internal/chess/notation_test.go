@@ -0,0 +1,129 @@
+package chess
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSAN(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		uci  string
+		want string
+	}{
+		{"pawn push", StartingFEN, "e2e4", "e4"},
+		{"knight", StartingFEN, "g1f3", "Nf3"},
+		{"file disambiguation", "4k3/8/8/8/8/2N3N1/8/4K3 w - - 0 1", "c3e4", "Nce4"},
+		{"rank disambiguation", "4k3/8/8/2N5/8/2N5/8/4K3 w - - 0 1", "c3e4", "N3e4"},
+		{"square disambiguation", "7K/8/8/7k/8/Q7/8/Q1Q5 w - - 0 1", "a1b2", "Qa1b2"},
+		{"pinned piece needs none", "4k3/8/8/b7/8/2N3N1/8/4K3 w - - 0 1", "g3e4", "Ne4"},
+		{"pawn capture", "4k3/8/8/3p4/4P3/8/8/4K3 w - - 0 1", "e4d5", "exd5"},
+		{"promotion with check", "8/P7/8/8/8/8/8/k6K w - - 0 1", "a7a8q", "a8=Q+"},
+		{"underpromotion", "8/P7/8/8/8/8/8/k6K w - - 0 1", "a7a8n", "a8=N"},
+		{"capture promotion", "1r6/P7/8/8/8/8/8/k6K w - - 0 1", "a7b8r", "axb8=R"},
+		{"kingside castling", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", "O-O"},
+		{"queenside castling", "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", "O-O-O"},
+		{"mate", "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "a1a8", "Ra8#"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := p.ParseUCI(tt.uci)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.SAN(m); got != tt.want {
+				t.Errorf("SAN = %s, want %s", got, tt.want)
+			}
+			back, err := p.ParseSAN(tt.want)
+			if err != nil {
+				t.Fatalf("ParseSAN(%s): %v", tt.want, err)
+			}
+			if back != m {
+				t.Errorf("ParseSAN(%s) = %s, want %s", tt.want, back.UCI(), m.UCI())
+			}
+		})
+	}
+}
+
+func TestParseSANLenient(t *testing.T) {
+	tests := []struct {
+		fen  string
+		san  string
+		want string
+	}{
+		{StartingFEN, "Nf3!?", "g1f3"},
+		{StartingFEN, "0-0", ""},
+		{"4k3/8/8/3p4/4P3/8/8/4K3 w - - 0 1", "ed5", "e4d5"},
+		{"4k3/8/8/8/8/2N3N1/8/4K3 w - - 0 1", "Nc3e4", "c3e4"},
+		{"8/P7/8/8/8/8/8/k6K w - - 0 1", "a8Q", "a7a8q"},
+		{"8/P7/8/8/8/8/8/k6K w - - 0 1", "a8=q", "a7a8q"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.san, func(t *testing.T) {
+			p, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := p.ParseSAN(tt.san)
+			if tt.want == "" {
+				if !errors.Is(err, ErrIllegalMove) {
+					t.Errorf("err = %v, want ErrIllegalMove", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.UCI() != tt.want {
+				t.Errorf("move = %s, want %s", m.UCI(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSANErrors(t *testing.T) {
+	tests := []struct {
+		fen  string
+		san  string
+		want error
+	}{
+		{"4k3/8/8/8/8/2N3N1/8/4K3 w - - 0 1", "Ne4", ErrAmbiguousMove},
+		{StartingFEN, "e5", ErrIllegalMove},
+		{"8/P7/8/8/8/8/8/k6K w - - 0 1", "a8", ErrInvalidMove},
+		{"8/P7/8/8/8/8/8/k6K w - - 0 1", "a8=K", ErrInvalidMove},
+		{StartingFEN, "", ErrInvalidMove},
+		{StartingFEN, "Nz9", ErrInvalidMove},
+	}
+	for _, tt := range tests {
+		t.Run(tt.san, func(t *testing.T) {
+			p, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.ParseSAN(tt.san); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMove(t *testing.T) {
+	p := NewPosition()
+	for _, s := range []string{"e2e4", "e4", "  e2e4 "} {
+		m, err := p.ParseMove(s)
+		if err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if m.UCI() != "e2e4" {
+			t.Errorf("%q = %s, want e2e4", s, m.UCI())
+		}
+	}
+	if _, err := p.ParseUCI("e2e5"); !errors.Is(err, ErrIllegalMove) {
+		t.Errorf("e2e5: err = %v, want ErrIllegalMove", err)
+	}
+}
@@ -0,0 +1,152 @@
+package chess
+
+import "fmt"
+
+const StartingFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+type Position struct {
+	board          [64]Piece
+	Turn           Color
+	Castling       CastlingRights
+	EnPassant      Square
+	HalfmoveClock  int
+	FullmoveNumber int
+}
+
+func NewPosition() *Position {
+	p, err := ParseFEN(StartingFEN)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p *Position) PieceAt(sq Square) Piece {
+	if sq < 0 || sq > 63 {
+		return NoPiece
+	}
+	return p.board[sq]
+}
+
+func (p *Position) kingSquare(c Color) Square {
+	for sq := Square(0); sq < 64; sq++ {
+		if p.board[sq] == (Piece{Type: King, Color: c}) {
+			return sq
+		}
+	}
+	return NoSquare
+}
+
+func (p *Position) InCheck() bool {
+	king := p.kingSquare(p.Turn)
+	return king != NoSquare && p.isAttacked(king, p.Turn.Other())
+}
+
+func (p *Position) IsCheckmate() bool {
+	return p.InCheck() && len(p.LegalMoves()) == 0
+}
+
+func (p *Position) IsStalemate() bool {
+	return !p.InCheck() && len(p.LegalMoves()) == 0
+}
+
+func (p *Position) IsLegal(m Move) bool {
+	for _, legal := range p.LegalMoves() {
+		if legal == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply plays a move after checking that it is legal and returns the resulting
+// position. The receiver is left untouched.
+func (p *Position) Apply(m Move) (*Position, error) {
+	if !p.IsLegal(m) {
+		return nil, fmt.Errorf("%w: %s", ErrIllegalMove, m.UCI())
+	}
+	return p.play(m), nil
+}
+
+// play applies a pseudo-legal move without any legality checks.
+func (p *Position) play(m Move) *Position {
+	next := *p
+	piece := next.board[m.From]
+	captured := next.board[m.To]
+
+	next.board[m.From] = NoPiece
+	next.board[m.To] = piece
+	next.EnPassant = NoSquare
+
+	switch piece.Type {
+	case Pawn:
+		if m.To == p.EnPassant && m.From.File() != m.To.File() && captured.IsEmpty() {
+			// En passant: the captured pawn sits beside the destination square.
+			next.board[NewSquare(m.To.File(), m.From.Rank())] = NoPiece
+			captured = Piece{Type: Pawn, Color: piece.Color.Other()}
+		}
+		if d := m.To.Rank() - m.From.Rank(); d == 2 || d == -2 {
+			next.EnPassant = NewSquare(m.From.File(), (m.From.Rank()+m.To.Rank())/2)
+		}
+		if m.Promotion != NoPieceType {
+			next.board[m.To] = Piece{Type: m.Promotion, Color: piece.Color}
+		}
+	case King:
+		if d := m.To.File() - m.From.File(); d == 2 || d == -2 {
+			rank := m.From.Rank()
+			rookFrom, rookTo := NewSquare(7, rank), NewSquare(5, rank)
+			if d < 0 {
+				rookFrom, rookTo = NewSquare(0, rank), NewSquare(3, rank)
+			}
+			next.board[rookTo] = next.board[rookFrom]
+			next.board[rookFrom] = NoPiece
+		}
+		if piece.Color == White {
+			next.Castling &^= WhiteKingside | WhiteQueenside
+		} else {
+			next.Castling &^= BlackKingside | BlackQueenside
+		}
+	}
+
+	next.Castling &^= castlingMask(m.From) | castlingMask(m.To)
+
+	if piece.Type == Pawn || !captured.IsEmpty() {
+		next.HalfmoveClock = 0
+	} else {
+		next.HalfmoveClock++
+	}
+	if p.Turn == Black {
+		next.FullmoveNumber++
+	}
+	next.Turn = p.Turn.Other()
+	return &next
+}
+
+// castlingMask returns the rights lost when a piece leaves or lands on sq.
+func castlingMask(sq Square) CastlingRights {
+	switch sq {
+	case NewSquare(0, 0):
+		return WhiteQueenside
+	case NewSquare(7, 0):
+		return WhiteKingside
+	case NewSquare(0, 7):
+		return BlackQueenside
+	case NewSquare(7, 7):
+		return BlackKingside
+	}
+	return 0
+}
+
+// enPassantTarget returns the en passant square only when an en passant
+// capture is actually legal, which is how chess.js and Lichess write FENs.
+func (p *Position) enPassantTarget() Square {
+	if p.EnPassant == NoSquare {
+		return NoSquare
+	}
+	for _, m := range p.LegalMoves() {
+		if m.To == p.EnPassant && p.board[m.From].Type == Pawn {
+			return p.EnPassant
+		}
+	}
+	return NoSquare
+}
@@ -0,0 +1,120 @@
+package chess
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// playMoves plays moves in any notation ParseMove accepts.
+func playMoves(t *testing.T, fen string, moves ...string) *Position {
+	t.Helper()
+	p, err := ParseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range moves {
+		m, err := p.ParseMove(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if p, err = p.Apply(m); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+	return p
+}
+
+func TestCastlingRights(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		moves []string
+		want  string
+	}{
+		{"king move", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", []string{"Kf1"}, "kq"},
+		{"kingside rook move", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", []string{"Rh2"}, "Qkq"},
+		{"rook captured", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", []string{"Rxa8+"}, "Kk"},
+		{"castling", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", []string{"O-O", "O-O-O"}, "-"},
+		{"rook returns", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", []string{"Ra2", "Ra7", "Ra1", "Ra8"}, "Kk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := playMoves(t, tt.fen, tt.moves...)
+			if got := p.Castling.String(); got != tt.want {
+				t.Errorf("castling = %s, want %s", got, tt.want)
+			}
+			if fields := strings.Fields(p.Key()); fields[2] != tt.want {
+				t.Errorf("key castling field = %s, want %s", fields[2], tt.want)
+			}
+		})
+	}
+}
+
+func TestCastlingPlacesRook(t *testing.T) {
+	p := playMoves(t, "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", "e8c8")
+	if got, want := p.Key(), "2kr3r/8/8/8/8/8/8/R4RK1 w - -"; got != want {
+		t.Errorf("key = %s, want %s", got, want)
+	}
+}
+
+func TestCastlingThroughCheck(t *testing.T) {
+	// The bishop on a6 covers f1
+	p := playMoves(t, "r3k2r/8/b7/8/8/8/8/R3K2R w KQkq - 0 1")
+	if _, err := p.ParseSAN("O-O"); !errors.Is(err, ErrIllegalMove) {
+		t.Errorf("O-O through check: err = %v, want ErrIllegalMove", err)
+	}
+	if _, err := p.ParseSAN("O-O-O"); err != nil {
+		t.Errorf("O-O-O: %v", err)
+	}
+}
+
+func TestEnPassantKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		moves []string
+		want  string
+	}{
+		// Only written when a capture is actually possible
+		{"no capturing pawn", StartingFEN, []string{"e4"}, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq -"},
+		{"capture possible", StartingFEN, []string{"e4", "Nf6", "e5", "d5"}, "rnbqkb1r/ppp1pppp/5n2/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6"},
+		{"capture pinned", "4k3/5p2/8/K3P2r/8/8/8/8 b - - 0 1", []string{"f5"}, "4k3/8/8/K3Pp1r/8/8/8/8 w - -"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := playMoves(t, tt.fen, tt.moves...).Key(); got != tt.want {
+				t.Errorf("key = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnPassantCapture(t *testing.T) {
+	p := playMoves(t, StartingFEN, "e4", "Nf6", "e5", "d5", "exd6")
+	if got, want := p.Key(), "rnbqkb1r/ppp1pppp/3P1n2/8/8/8/PPPP1PPP/RNBQKBNR b KQkq -"; got != want {
+		t.Errorf("key = %s, want %s", got, want)
+	}
+}
+
+func TestTranspositionsShareKey(t *testing.T) {
+	a := playMoves(t, StartingFEN, "Nf3", "Nf6", "Nc3", "Nc6")
+	b := playMoves(t, StartingFEN, "Nc3", "Nc6", "Nf3", "Nf6")
+	if a.Key() != b.Key() {
+		t.Errorf("keys differ: %s and %s", a.Key(), b.Key())
+	}
+	if a.FEN() == b.Key() {
+		t.Error("FEN should carry the move counters")
+	}
+}
+
+func TestMate(t *testing.T) {
+	p := playMoves(t, StartingFEN, "f3", "e5", "g4", "Qh4")
+	if !p.IsCheckmate() {
+		t.Error("fool's mate is not checkmate")
+	}
+	stalemate := playMoves(t, "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1")
+	if !stalemate.IsStalemate() {
+		t.Error("expected stalemate")
+	}
+}
@@ -0,0 +1,192 @@
+package chess
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidFEN    = errors.New("invalid FEN")
+	ErrInvalidMove   = errors.New("invalid move notation")
+	ErrIllegalMove   = errors.New("illegal move")
+	ErrAmbiguousMove = errors.New("ambiguous move")
+)
+
+type Color int8
+
+const (
+	White Color = iota
+	Black
+)
+
+func (c Color) Other() Color {
+	return c ^ 1
+}
+
+func (c Color) String() string {
+	if c == White {
+		return "white"
+	}
+	return "black"
+}
+
+// ParseColor accepts the "white" / "black" strings used throughout the models.
+func ParseColor(s string) (Color, error) {
+	switch s {
+	case "white":
+		return White, nil
+	case "black":
+		return Black, nil
+	}
+	return White, fmt.Errorf("unknown color %q", s)
+}
+
+type PieceType int8
+
+const (
+	NoPieceType PieceType = iota
+	Pawn
+	Knight
+	Bishop
+	Rook
+	Queen
+	King
+)
+
+// Letter returns the upper-case SAN letter of the piece type (empty for pawns).
+func (t PieceType) Letter() string {
+	switch t {
+	case Knight:
+		return "N"
+	case Bishop:
+		return "B"
+	case Rook:
+		return "R"
+	case Queen:
+		return "Q"
+	case King:
+		return "K"
+	}
+	return ""
+}
+
+func pieceTypeFromLetter(r byte) PieceType {
+	switch r {
+	case 'P', 'p':
+		return Pawn
+	case 'N', 'n':
+		return Knight
+	case 'B', 'b':
+		return Bishop
+	case 'R', 'r':
+		return Rook
+	case 'Q', 'q':
+		return Queen
+	case 'K', 'k':
+		return King
+	}
+	return NoPieceType
+}
+
+type Piece struct {
+	Type  PieceType
+	Color Color
+}
+
+var NoPiece = Piece{}
+
+func (p Piece) IsEmpty() bool {
+	return p.Type == NoPieceType
+}
+
+// FENRune returns the FEN character of the piece: upper case for white.
+func (p Piece) FENRune() byte {
+	c := "?PNBRQK"[p.Type]
+	if p.Color == Black {
+		c += 'a' - 'A'
+	}
+	return c
+}
+
+// Square indexes the board from a1 = 0 to h8 = 63.
+type Square int8
+
+const NoSquare Square = -1
+
+func NewSquare(file, rank int) Square {
+	if file < 0 || file > 7 || rank < 0 || rank > 7 {
+		return NoSquare
+	}
+	return Square(rank*8 + file)
+}
+
+func (s Square) File() int {
+	return int(s) % 8
+}
+
+func (s Square) Rank() int {
+	return int(s) / 8
+}
+
+func (s Square) String() string {
+	if s == NoSquare {
+		return "-"
+	}
+	return string([]byte{byte('a' + s.File()), byte('1' + s.Rank())})
+}
+
+func ParseSquare(s string) (Square, error) {
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return NoSquare, fmt.Errorf("invalid square %q", s)
+	}
+	return NewSquare(int(s[0]-'a'), int(s[1]-'1')), nil
+}
+
+type CastlingRights uint8
+
+const (
+	WhiteKingside CastlingRights = 1 << iota
+	WhiteQueenside
+	BlackKingside
+	BlackQueenside
+)
+
+func (c CastlingRights) String() string {
+	if c == 0 {
+		return "-"
+	}
+	var b strings.Builder
+	if c&WhiteKingside != 0 {
+		b.WriteByte('K')
+	}
+	if c&WhiteQueenside != 0 {
+		b.WriteByte('Q')
+	}
+	if c&BlackKingside != 0 {
+		b.WriteByte('k')
+	}
+	if c&BlackQueenside != 0 {
+		b.WriteByte('q')
+	}
+	return b.String()
+}
+
+type Move struct {
+	From      Square
+	To        Square
+	Promotion PieceType
+}
+
+// UCI returns the move in long algebraic UCI notation, e.g. "e2e4" or "e7e8q".
+func (m Move) UCI() string {
+	s := m.From.String() + m.To.String()
+	if m.Promotion != NoPieceType {
+		s += strings.ToLower(m.Promotion.Letter())
+	}
+	return s
+}
+
+func (m Move) String() string {
+	return m.UCI()
+}
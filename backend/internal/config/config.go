@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,6 +18,68 @@ type Config struct {
 	OpenAIAPIKey    string
 	OpenAIModel     string
 	OpenAIBaseURL   string
+
+	LichessExplorerURL string
+	LichessAPIToken    string
+	ExplorerCacheTTL   time.Duration
+
+	// Largest centipawn losses judged best, good, an inaccuracy and a mistake
+	BestMoveMaxLoss   int
+	GoodMoveMaxLoss   int
+	InaccuracyMaxLoss int
+	MistakeMaxLoss    int
+
+	// UCI engine for server-side analysis, disabled when EnginePath is empty
+	EnginePath        string
+	EnginePoolSize    int
+	EngineMaxDepth    int
+	EngineMaxMoveTime time.Duration
+	EngineThreads     int
+	EngineHashMB      int
+
+	// Shared eval store, pruned to EvalStoreMaxEntries (0 = unbounded)
+	EvalStoreMaxEntries    int
+	EvalStorePruneInterval time.Duration
+
+	// Frontend base URL, for links in emails
+	AppURL string
+
+	// Mail delivery: "smtp", or "file" to write mails to MailDir (the log
+	// when MailDir is empty)
+	MailDriver   string
+	MailFrom     string
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	EmailVerificationTTL time.Duration
+	PasswordResetTTL     time.Duration
+
+	// Login lockout: this many failures within LoginFailureWindow lock an
+	// account or client IP out, first for LoginLockoutBase, doubling up to
+	// LoginLockoutMax
+	LoginMaxFailuresPerAccount int
+	LoginMaxFailuresPerIP      int
+	LoginFailureWindow         time.Duration
+	LoginLockoutBase           time.Duration
+	LoginLockoutMax            time.Duration
+
+	// Account mail: this many password reset or verification mails within
+	// MailLimitWindow, per account or client IP, lock further requests
+	// out for MailLimitWindow, doubling up to LoginLockoutMax
+	MailMaxPerAccount int
+	MailMaxPerIP      int
+	MailLimitWindow   time.Duration
+
+	// Users whose verified email is listed may use the admin routes
+	AdminEmails []string
+
+	// Proxies, as IPs or CIDRs, whose X-Forwarded-For header is believed
+	// for the client IP the lockouts count against. None by default, so
+	// clients cannot pick their own IP
+	TrustedProxies []string
 }
 
 var AppConfig *Config
@@ -32,6 +97,52 @@ func Load() {
 		OpenAIAPIKey:    getEnv("OPENAI_API_KEY", ""),
 		OpenAIModel:     getEnv("OPENAI_MODEL", "gemini-3-pro-high"),
 		OpenAIBaseURL:   getEnv("OPENAI_BASE_URL", ""),
+
+		LichessExplorerURL: getEnv("LICHESS_EXPLORER_URL", "https://explorer.lichess.ovh"),
+		LichessAPIToken:    getEnv("LICHESS_API_TOKEN", ""),
+		ExplorerCacheTTL:   getDurationEnv("EXPLORER_CACHE_TTL", 7*24*time.Hour),
+
+		BestMoveMaxLoss:   getIntEnv("BEST_MOVE_MAX_LOSS", 10),
+		GoodMoveMaxLoss:   getIntEnv("GOOD_MOVE_MAX_LOSS", 50),
+		InaccuracyMaxLoss: getIntEnv("INACCURACY_MAX_LOSS", 100),
+		MistakeMaxLoss:    getIntEnv("MISTAKE_MAX_LOSS", 300),
+
+		EnginePath:        getEnv("ENGINE_PATH", ""),
+		EnginePoolSize:    getIntEnv("ENGINE_POOL_SIZE", 2),
+		EngineMaxDepth:    getIntEnv("ENGINE_MAX_DEPTH", 30),
+		EngineMaxMoveTime: getDurationEnv("ENGINE_MAX_MOVE_TIME", 10*time.Second),
+		EngineThreads:     getIntEnv("ENGINE_THREADS", 1),
+		EngineHashMB:      getIntEnv("ENGINE_HASH_MB", 64),
+
+		EvalStoreMaxEntries:    getIntEnv("EVAL_STORE_MAX_ENTRIES", 1000000),
+		EvalStorePruneInterval: getDurationEnv("EVAL_STORE_PRUNE_INTERVAL", 10*time.Minute),
+
+		AppURL: getEnv("APP_URL", "http://localhost:5173"),
+
+		MailDriver:   getEnv("MAIL_DRIVER", "file"),
+		MailFrom:     getEnv("MAIL_FROM", "Openings Master <no-reply@localhost>"),
+		MailDir:      getEnv("MAIL_DIR", ""),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getIntEnv("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		EmailVerificationTTL: getDurationEnv("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		PasswordResetTTL:     getDurationEnv("PASSWORD_RESET_TTL", time.Hour),
+
+		LoginMaxFailuresPerAccount: getIntEnv("LOGIN_MAX_FAILURES_PER_ACCOUNT", 5),
+		LoginMaxFailuresPerIP:      getIntEnv("LOGIN_MAX_FAILURES_PER_IP", 20),
+		LoginFailureWindow:         getDurationEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		LoginLockoutBase:           getDurationEnv("LOGIN_LOCKOUT_BASE", time.Minute),
+		LoginLockoutMax:            getDurationEnv("LOGIN_LOCKOUT_MAX", 24*time.Hour),
+
+		MailMaxPerAccount: getIntEnv("MAIL_MAX_PER_ACCOUNT", 3),
+		MailMaxPerIP:      getIntEnv("MAIL_MAX_PER_IP", 10),
+		MailLimitWindow:   getDurationEnv("MAIL_LIMIT_WINDOW", time.Hour),
+
+		AdminEmails: getListEnv("ADMIN_EMAILS"),
+
+		TrustedProxies: getListEnv("TRUSTED_PROXIES"),
 	}
 }
 
@@ -41,3 +152,40 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getListEnv splits a comma-separated variable, dropping empty items.
+func getListEnv(key string) []string {
+	items := []string{}
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration %q for %s, using %s", value, key, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+func getIntEnv(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer %q for %s, using %d", value, key, defaultValue)
+		return defaultValue
+	}
+	return n
+}
@@ -0,0 +1,101 @@
+// Package eco names chess openings after the Encyclopaedia of Chess
+// Openings. The table is embedded in the binary and indexed by position, so
+// a line reached through a different move order is still recognised.
+//
+// eco.tsv lists every code from A00 to E99 with the line that defines it,
+// named after the lichess chess-openings dataset (CC0). Its a.tsv to e.tsv
+// share the eco/name/pgn columns and can be concatenated in its place.
+package eco
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+)
+
+//go:embed eco.tsv
+var table string
+
+// Opening is one entry of the table.
+type Opening struct {
+	ECO   string
+	Name  string
+	Moves []string // SAN line from the standard starting position
+	FEN   string   // position key after the line
+}
+
+var (
+	loadOnce   sync.Once
+	byPosition map[string]*Opening
+)
+
+// Lookup returns the table entry for the position key, if any. Keys are
+// normalized FENs, as returned by chess.NormalizeFEN and Position.Key.
+func Lookup(key string) (*Opening, bool) {
+	loadOnce.Do(load)
+	opening, ok := byPosition[key]
+	return opening, ok
+}
+
+// ClassifyMoves plays moves (SAN or UCI) from pos and returns the entry of
+// the last position along the line found in the table, with the number of
+// plies it took to reach it. Ply 0 means the starting position itself
+// matched. It returns nil when nothing matched.
+func ClassifyMoves(pos *chess.Position, moves []string) (*Opening, int, error) {
+	best, ply := (*Opening)(nil), 0
+	if opening, ok := Lookup(pos.Key()); ok {
+		best = opening
+	}
+
+	for i, s := range moves {
+		m, err := pos.ParseMove(s)
+		if err != nil {
+			return nil, 0, fmt.Errorf("move %d %q: %w", i+1, s, err)
+		}
+		if pos, err = pos.Apply(m); err != nil {
+			return nil, 0, fmt.Errorf("move %d %q: %w", i+1, s, err)
+		}
+		if opening, ok := Lookup(pos.Key()); ok {
+			best, ply = opening, i+1
+		}
+	}
+	return best, ply, nil
+}
+
+// load parses the embedded table. The table ships with the binary, so an
+// entry that does not parse is a programming error.
+func load() {
+	byPosition = map[string]*Opening{}
+
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	for n, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			panic(fmt.Sprintf("eco: line %d: expected 3 fields, got %d", n+2, len(fields)))
+		}
+
+		opening := &Opening{ECO: fields[0], Name: fields[1], Moves: []string{}}
+		pos := chess.NewPosition()
+		for _, token := range strings.Fields(fields[2]) {
+			if strings.HasSuffix(token, ".") {
+				continue
+			}
+			m, err := pos.ParseSAN(token)
+			if err != nil {
+				panic(fmt.Sprintf("eco: line %d: %s: %v", n+2, token, err))
+			}
+			opening.Moves = append(opening.Moves, pos.SAN(m))
+			pos, _ = pos.Apply(m)
+		}
+		opening.FEN = pos.Key()
+
+		// The first entry for a position names it; later ones are
+		// transpositions into an already named line
+		if _, ok := byPosition[opening.FEN]; !ok {
+			byPosition[opening.FEN] = opening
+		}
+	}
+}
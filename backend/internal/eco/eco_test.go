@@ -0,0 +1,35 @@
+package eco
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+)
+
+func TestTableCoversEveryCode(t *testing.T) {
+	loadOnce.Do(load)
+
+	seen := map[string]bool{}
+	for _, opening := range byPosition {
+		seen[opening.ECO] = true
+	}
+	for _, volume := range "ABCDE" {
+		for n := 0; n < 100; n++ {
+			if code := fmt.Sprintf("%c%02d", volume, n); !seen[code] {
+				t.Errorf("no position is named %s", code)
+			}
+		}
+	}
+}
+
+func TestClassifyMoves(t *testing.T) {
+	moves := []string{"e4", "c5", "Nf3", "d6", "d4", "cxd4", "Nxd4", "Nf6", "Nc3", "a6", "Bg5", "e6", "f4", "h6"}
+	opening, ply, err := ClassifyMoves(chess.NewPosition(), moves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opening == nil || opening.ECO != "B96" || ply != 13 {
+		t.Fatalf("got %+v at ply %d, want B96 at ply 13", opening, ply)
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/repository"
+)
+
+// maxListedLockouts caps the login failure counters listed at once.
+const maxListedLockouts = 200
+
+type AdminHandler struct {
+	attemptRepo *repository.LoginAttemptRepository
+}
+
+func NewAdminHandler(attemptRepo *repository.LoginAttemptRepository) *AdminHandler {
+	return &AdminHandler{
+		attemptRepo: attemptRepo,
+	}
+}
+
+// Lockouts lists the login failure counters, only those locked out now
+// with ?locked=true.
+func (h *AdminHandler) Lockouts(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	attempts, err := h.attemptRepo.List(ctx, c.Query("locked") == "true", maxListedLockouts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lockouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, attempts)
+}
+
+// ClearLockout forgets a counter's failures and lockouts. The counter is
+// named by ?key=, as listed.
+func (h *AdminHandler) ClearLockout(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	deleted, err := h.attemptRepo.Delete(ctx, key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear lockout"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "lockout not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "lockout cleared"})
+}
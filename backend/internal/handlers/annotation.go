@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxRunningAnnotations = 2 // jobs analyzing at once, across all users
+	annotationEvalTimeout = 2 * time.Minute
+	annotationStoreBatch  = 200
+	progressInterval      = 10 // positions between progress updates
+
+	annotationRenewInterval = repository.AnnotationJobLease / 4
+)
+
+// AnnotationHandler runs annotation jobs in the background of the server
+// that accepted them, renewing each job's lease while it runs. Jobs whose
+// server stopped are marked failed once their lease runs out.
+type AnnotationHandler struct {
+	jobRepo        *repository.AnnotationJobRepository
+	repertoireRepo *repository.RepertoireRepository
+	evalRepo       *repository.EvalRepository
+	engineService  *services.EngineService
+	classifier     *services.MoveClassifier
+	instance       string // owner of the jobs this server runs
+
+	slots   chan struct{}
+	mu      sync.Mutex
+	cancels map[primitive.ObjectID]context.CancelFunc
+}
+
+func NewAnnotationHandler(jobRepo *repository.AnnotationJobRepository, repertoireRepo *repository.RepertoireRepository, evalRepo *repository.EvalRepository, engineService *services.EngineService, classifier *services.MoveClassifier) *AnnotationHandler {
+	return &AnnotationHandler{
+		jobRepo:        jobRepo,
+		repertoireRepo: repertoireRepo,
+		evalRepo:       evalRepo,
+		engineService:  engineService,
+		classifier:     classifier,
+		instance:       primitive.NewObjectID().Hex(),
+		slots:          make(chan struct{}, maxRunningAnnotations),
+		cancels:        map[primitive.ObjectID]context.CancelFunc{},
+	}
+}
+
+// Start queues a job evaluating every position of the repertoire and
+// returns it at once; its progress is polled with Get.
+func (h *AnnotationHandler) Start(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := parseObjectID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid repertoire ID"})
+		return
+	}
+
+	// The body is optional
+	var req models.StartAnnotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.engineService.Enabled() {
+		writeEngineError(c, services.ErrEngineUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	repertoire, err := h.repertoireRepo.FindByIDAndUserID(ctx, id, userID)
+	if err != nil || repertoire == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "repertoire not found"})
+		return
+	}
+
+	active, err := h.jobRepo.FindActive(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check annotation jobs"})
+		return
+	}
+	if active != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "repertoire is already being annotated", "job": active})
+		return
+	}
+
+	job := &models.AnnotationJob{
+		UserID:            userID,
+		RepertoireID:      id,
+		RepertoireVersion: repertoire.Version,
+		Depth:             req.Depth,
+		Owner:             h.instance,
+	}
+	if job.Depth == 0 {
+		job.Depth = services.DefaultEngineDepth
+	}
+	if err := h.jobRepo.Create(ctx, job); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start annotation"})
+		return
+	}
+
+	runCtx, stop := context.WithCancel(context.Background())
+	h.mu.Lock()
+	h.cancels[job.ID] = stop
+	h.mu.Unlock()
+	go h.run(runCtx, *job, repertoire)
+	go h.heartbeat(runCtx, stop, job.ID)
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// run evaluates the repertoire's positions, reusing the eval store and
+// feeding it what the engine computes, then annotates every move.
+func (h *AnnotationHandler) run(ctx context.Context, job models.AnnotationJob, repertoire *models.Repertoire) {
+	defer func() {
+		h.mu.Lock()
+		if stop, ok := h.cancels[job.ID]; ok {
+			stop()
+			delete(h.cancels, job.ID)
+		}
+		h.mu.Unlock()
+	}()
+
+	select {
+	case h.slots <- struct{}{}:
+		defer func() { <-h.slots }()
+	case <-ctx.Done():
+		job.Status = models.JobCanceled
+		h.finish(&job)
+		return
+	}
+
+	positions := services.AnnotationPositions(repertoire)
+	dbCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	err := h.jobRepo.Start(dbCtx, job.ID, job.Owner, len(positions))
+	cancel()
+	if err != nil {
+		log.Printf("Warning: Failed to start annotation job %s: %v", job.ID.Hex(), err)
+	}
+
+	evals := make(map[string]models.PositionEval, len(positions))
+	for start := 0; start < len(positions); start += annotationStoreBatch {
+		batch := positions[start:min(start+annotationStoreBatch, len(positions))]
+		dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		stored, err := h.evalRepo.FindByFENs(dbCtx, batch, job.Depth)
+		cancel()
+		if err != nil {
+			log.Printf("Warning: Failed to read stored evals: %v", err)
+			break
+		}
+		// Positions with only a submitted eval are analyzed again
+		for key, eval := range services.EngineEvals(stored) {
+			evals[key] = eval
+		}
+	}
+	job.FromStore = len(evals)
+	job.Evaluated = len(evals)
+	h.progress(&job)
+
+	for _, key := range positions {
+		if _, ok := evals[key]; ok {
+			continue
+		}
+		if ctx.Err() != nil {
+			job.Status = models.JobCanceled
+			h.finish(&job)
+			return
+		}
+
+		if eval, ok := services.TerminalEval(key); ok {
+			evals[key] = eval
+		} else {
+			evalCtx, cancel := context.WithTimeout(ctx, annotationEvalTimeout)
+			analysis, err := h.engineService.Analyze(evalCtx, services.EngineQuery{FEN: key, Depth: job.Depth})
+			cancel()
+			if err != nil {
+				job.Status = models.JobFailed
+				if ctx.Err() != nil {
+					job.Status = models.JobCanceled
+				} else {
+					job.Error = "engine analysis failed: " + err.Error()
+				}
+				h.finish(&job)
+				return
+			}
+			if eval, ok := services.EngineEval(analysis); ok {
+				evals[key] = eval
+				dbCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				if _, err := h.evalRepo.SaveDeepest(dbCtx, []models.PositionEval{eval}); err != nil {
+					log.Printf("Warning: Failed to store engine eval: %v", err)
+				}
+				cancel()
+			}
+		}
+
+		job.Evaluated++
+		if job.Evaluated%progressInterval == 0 {
+			h.progress(&job)
+		}
+	}
+
+	job.Annotations = services.AnnotateRepertoire(repertoire, evals, h.classifier)
+	for _, annotation := range job.Annotations {
+		if annotation.Dubious {
+			job.Dubious++
+		}
+	}
+	job.Status = models.JobDone
+	h.finish(&job)
+}
+
+// heartbeat renews the job's lease until ctx ends. It stops the job once
+// the job is no longer this server's to run, as after a cancellation
+// received by another instance.
+func (h *AnnotationHandler) heartbeat(ctx context.Context, stop context.CancelFunc, id primitive.ObjectID) {
+	ticker := time.NewTicker(annotationRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		ok, err := h.jobRepo.Renew(dbCtx, id, h.instance)
+		cancel()
+		if err != nil {
+			log.Printf("Warning: Failed to renew annotation job %s: %v", id.Hex(), err)
+			continue
+		}
+		if !ok {
+			stop()
+			return
+		}
+	}
+}
+
+func (h *AnnotationHandler) progress(job *models.AnnotationJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := h.jobRepo.UpdateProgress(ctx, job.ID, job.Owner, job.Evaluated, job.FromStore); err != nil {
+		log.Printf("Warning: Failed to update annotation job %s: %v", job.ID.Hex(), err)
+	}
+}
+
+func (h *AnnotationHandler) finish(job *models.AnnotationJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := h.jobRepo.Finish(ctx, job); err != nil {
+		log.Printf("Warning: Failed to finish annotation job %s: %v", job.ID.Hex(), err)
+	}
+}
+
+// Get reports a job's progress, and its annotations once done.
+func (h *AnnotationHandler) Get(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := parseObjectID(c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	job, err := h.jobRepo.FindByIDAndUserID(ctx, id, userID)
+	if err != nil || job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// Latest returns the repertoire's most recent annotation job.
+func (h *AnnotationHandler) Latest(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := parseObjectID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid repertoire ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	job, err := h.jobRepo.FindLatest(ctx, id, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch annotations"})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "repertoire has not been annotated"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+func (h *AnnotationHandler) Cancel(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := parseObjectID(c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	job, err := h.jobRepo.FindByIDAndUserID(ctx, id, userID)
+	if err != nil || job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	// The job is canceled before its runner is stopped, so the runner
+	// finishing meanwhile cannot mark it done
+	canceled, err := h.jobRepo.Cancel(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel job"})
+		return
+	}
+	if !canceled {
+		c.JSON(http.StatusConflict, gin.H{"error": "job is not running"})
+		return
+	}
+
+	// A runner on another instance stops when it next renews its lease
+	h.mu.Lock()
+	stop, ok := h.cancels[id]
+	h.mu.Unlock()
+	if ok {
+		stop()
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "cancellation requested"})
+}
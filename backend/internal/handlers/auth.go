@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"time"
 
@@ -14,13 +15,19 @@ import (
 
 type AuthHandler struct {
 	userRepo    *repository.UserRepository
+	sessionRepo *repository.SessionRepository
 	authService *services.AuthService
+	guard       *loginGuard
+	mail        *accountMail
 }
 
-func NewAuthHandler(userRepo *repository.UserRepository, authService *services.AuthService) *AuthHandler {
+func NewAuthHandler(userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository, mailTokenRepo *repository.MailTokenRepository, attemptRepo *repository.LoginAttemptRepository, authService *services.AuthService, lockout *services.LoginLockout, accountMailer *services.AccountMailer) *AuthHandler {
 	return &AuthHandler{
 		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
 		authService: authService,
+		guard:       &loginGuard{userRepo: userRepo, authService: authService, attemptRepo: attemptRepo, lockout: lockout},
+		mail:        &accountMail{authService: authService, mailTokenRepo: mailTokenRepo, mailer: accountMailer},
 	}
 }
 
@@ -69,17 +76,18 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// Generate tokens
-	accessToken, refreshToken, err := h.authService.GenerateTokenPair(user.ID.Hex(), user.Email)
+	response, err := h.startSession(ctx, c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate tokens"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User:         *user,
-	})
+	// The account works before the address is confirmed
+	if err := h.mail.sendVerification(ctx, user, user.Email); err != nil {
+		log.Printf("Warning: Failed to issue verification token: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, response)
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -92,34 +100,58 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
+	// Locked out clients do not get to try a password at all
+	targets := lockoutTargets(c, req.Email)
+	if remaining := h.guard.lockedFor(ctx, targets); remaining > 0 {
+		writeLockedOut(c, remaining)
+		return
+	}
+
 	user, err := h.userRepo.FindByEmail(ctx, req.Email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
 		return
 	}
-	if user == nil {
+
+	// Unknown emails count too, so lockouts do not reveal who is registered
+	if user == nil || !h.authService.VerifyPassword(user.PasswordHash, req.Password) {
+		if remaining := h.guard.recordFailure(ctx, targets); remaining > 0 {
+			writeLockedOut(c, remaining)
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
 
-	if !h.authService.VerifyPassword(user.PasswordHash, req.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+	if user.TwoFactorEnabled {
+		// The account's failures stand until the second factor is in
+		challenge, expiresAt, err := h.authService.GenerateChallengeToken(user.ID.Hex())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate tokens"})
+			return
+		}
+		c.JSON(http.StatusOK, models.TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresAt:         expiresAt,
+		})
 		return
 	}
 
-	accessToken, refreshToken, err := h.authService.GenerateTokenPair(user.ID.Hex(), user.Email)
+	h.guard.clearAccount(ctx, user.Email)
+
+	response, err := h.startSession(ctx, c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate tokens"})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User:         *user,
-	})
+	c.JSON(http.StatusOK, response)
 }
 
+// Refresh trades a refresh token for a new pair, retiring the old one.
+// Presenting a retired token revokes its whole session, since either the
+// token or the one that replaced it has been stolen.
 func (h *AuthHandler) Refresh(c *gin.Context) {
 	var req models.RefreshRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -133,26 +165,220 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 		return
 	}
 
+	sessionID, err := parseObjectID(claims.SessionID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	user, err := h.userRepo.FindByEmail(ctx, claims.Email)
+	session, err := h.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if session == nil || session.RevokedAt != nil || session.UserID.Hex() != claims.UserID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	if session.TokenID != claims.ID {
+		h.revokeReused(ctx, session)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(ctx, session.UserID)
 	if err != nil || user == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
 		return
 	}
 
-	accessToken, refreshToken, err := h.authService.GenerateTokenPair(user.ID.Hex(), user.Email)
+	tokenID, err := services.NewTokenID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate tokens"})
+		return
+	}
+	now := time.Now()
+	session.TokenID = tokenID
+	session.UserAgent = c.Request.UserAgent()
+	session.IP = c.ClientIP()
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(h.authService.RefreshExpiry())
+
+	rotated, err := h.sessionRepo.Rotate(ctx, session, claims.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if !rotated {
+		// The same token was refreshed concurrently
+		h.revokeReused(ctx, session)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+
+	response, err := h.tokens(user, session)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate tokens"})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.AuthResponse{
+	c.JSON(http.StatusOK, response)
+}
+
+// Logout ends the session of the given refresh token.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := h.authService.ValidateRefreshToken(req.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	sessionID, err := parseObjectID(claims.SessionID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	userID, err := parseObjectID(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	// Logging out twice is not an error
+	if _, err := h.sessionRepo.Revoke(ctx, sessionID, userID, models.RevokedLogout); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// LogoutAll ends every session of the user, the caller's included.
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	revoked, err := h.sessionRepo.RevokeAll(ctx, userID, models.RevokedLogoutAll)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out everywhere", "revoked": revoked})
+}
+
+// Sessions lists the devices the user is signed in on.
+func (h *AuthHandler) Sessions(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	sessions, err := h.sessionRepo.ListActive(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+		return
+	}
+
+	current := c.GetString("sessionID")
+	infos := make([]models.SessionInfo, len(sessions))
+	for i, session := range sessions {
+		infos[i] = models.SessionInfo{Session: session, Current: session.ID.Hex() == current}
+	}
+
+	c.JSON(http.StatusOK, infos)
+}
+
+// RevokeSession signs the user out of one device.
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	sessionID, err := parseObjectID(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	revoked, err := h.sessionRepo.Revoke(ctx, sessionID, userID, models.RevokedLogout)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
+
+// startSession signs the user in on the requesting device.
+func (h *AuthHandler) startSession(ctx context.Context, c *gin.Context, user *models.User) (models.AuthResponse, error) {
+	tokenID, err := services.NewTokenID()
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	session := &models.Session{
+		UserID:    user.ID,
+		TokenID:   tokenID,
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+		ExpiresAt: time.Now().Add(h.authService.RefreshExpiry()),
+	}
+	if err := h.sessionRepo.Create(ctx, session); err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	return h.tokens(user, session)
+}
+
+func (h *AuthHandler) tokens(user *models.User, session *models.Session) (models.AuthResponse, error) {
+	accessToken, refreshToken, err := h.authService.GenerateTokenPair(user.ID.Hex(), user.Email, session.ID.Hex(), session.TokenID)
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	return models.AuthResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		User:         *user,
-	})
+	}, nil
+}
+
+func (h *AuthHandler) revokeReused(ctx context.Context, session *models.Session) {
+	log.Printf("Warning: Refresh token reused in session %s of user %s; revoking the session", session.ID.Hex(), session.UserID.Hex())
+	if _, err := h.sessionRepo.Revoke(ctx, session.ID, session.UserID, models.RevokedReuse); err != nil {
+		log.Printf("Warning: Failed to revoke session %s: %v", session.ID.Hex(), err)
+	}
 }
 
 func (h *AuthHandler) GetMe(c *gin.Context) {
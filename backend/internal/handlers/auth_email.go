@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ResendVerification mails the signed-in user a new verification link,
+// retiring the previous one. Requests are limited per account and client.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	user, err := h.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	// A pending email change is what awaits verification
+	email := user.PendingEmail
+	if email == "" {
+		if user.EmailVerified {
+			c.JSON(http.StatusOK, gin.H{"message": "email already verified"})
+			return
+		}
+		email = user.Email
+	}
+	if !h.guard.allowMail(ctx, c, user.Email) {
+		return
+	}
+
+	if err := h.mail.sendVerification(ctx, user, email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "verification email sent"})
+}
+
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := h.authService.ValidateMailToken(req.Token, services.TokenVerifyEmail)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+		return
+	}
+	userID, err := parseObjectID(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	consumed, err := h.mail.mailTokenRepo.Consume(ctx, claims.ID, services.TokenVerifyEmail)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if !consumed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+		return
+	}
+
+	verified, err := h.userRepo.MarkEmailVerified(ctx, userID, claims.Email)
+	if err == nil && !verified {
+		// The token confirms an email change
+		verified, err = h.userRepo.ConfirmPendingEmail(ctx, userID, claims.Email)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "email already registered"})
+			return
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify email"})
+		return
+	}
+	if !verified {
+		c.JSON(http.StatusConflict, gin.H{"error": "token was sent to an address the account no longer uses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+// ForgotPassword mails a reset link if the address belongs to an account.
+// The reply is the same either way, so it does not reveal who is registered;
+// requests are limited per address, registered or not, and per client.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if !h.guard.allowMail(ctx, c, req.Email) {
+		return
+	}
+
+	user, err := h.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if user != nil {
+		if err := h.mail.sendPasswordReset(ctx, user); err != nil {
+			log.Printf("Warning: Failed to issue password reset token: %v", err)
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the address is registered, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password from a mailed token and signs the user
+// out everywhere.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := h.authService.ValidateMailToken(req.Token, services.TokenPasswordReset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+		return
+	}
+	userID, err := parseObjectID(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	user, err := h.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if user == nil || user.Email != claims.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+		return
+	}
+
+	consumed, err := h.mail.mailTokenRepo.Consume(ctx, claims.ID, services.TokenPasswordReset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if !consumed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+		return
+	}
+
+	hash, err := h.authService.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		return
+	}
+	if err := h.userRepo.UpdatePassword(ctx, user.ID, hash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+
+	// Receiving the link proves the address too
+	if !user.EmailVerified {
+		if _, err := h.userRepo.MarkEmailVerified(ctx, user.ID, user.Email); err != nil {
+			log.Printf("Warning: Failed to mark email verified: %v", err)
+		}
+	}
+	if _, err := h.sessionRepo.RevokeAll(ctx, user.ID, models.RevokedPasswordReset); err != nil {
+		log.Printf("Warning: Failed to revoke sessions after password reset: %v", err)
+	}
+	// The new password is not the one being guessed
+	h.guard.clearAccount(ctx, user.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset"})
+}
+
+// accountMail issues the single-use tokens of the account flows and mails
+// them.
+type accountMail struct {
+	authService   *services.AuthService
+	mailTokenRepo *repository.MailTokenRepository
+	mailer        *services.AccountMailer
+}
+
+// sendVerification mails a verification link for email, which is the
+// user's address or the one they are changing to.
+func (m *accountMail) sendVerification(ctx context.Context, user *models.User, email string) error {
+	token, expiresAt, err := m.issue(ctx, services.TokenVerifyEmail, user, email)
+	if err != nil {
+		return err
+	}
+	m.send("verification", func(ctx context.Context) error {
+		return m.mailer.SendVerification(ctx, email, user.Username, token, expiresAt)
+	})
+	return nil
+}
+
+func (m *accountMail) sendPasswordReset(ctx context.Context, user *models.User) error {
+	token, expiresAt, err := m.issue(ctx, services.TokenPasswordReset, user, user.Email)
+	if err != nil {
+		return err
+	}
+	m.send("password reset", func(ctx context.Context) error {
+		return m.mailer.SendPasswordReset(ctx, user.Email, user.Username, token, expiresAt)
+	})
+	return nil
+}
+
+func (m *accountMail) issue(ctx context.Context, purpose string, user *models.User, email string) (string, time.Time, error) {
+	tokenID, err := services.NewTokenID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	token, expiresAt, err := m.authService.GenerateMailToken(purpose, user.ID.Hex(), email, tokenID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	record := &models.MailToken{
+		ID:        tokenID,
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     email,
+		ExpiresAt: expiresAt,
+	}
+	if err := m.mailTokenRepo.Create(ctx, record); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// send delivers in the background: mail servers can be slow, and how long
+// a reply takes should not tell whether an address is registered.
+func (m *accountMail) send(kind string, send func(ctx context.Context) error) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := send(ctx); err != nil {
+			log.Printf("Warning: Failed to send %s email: %v", kind, err)
+		}
+	}()
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+)
+
+// maxSaveRetries bounds the retries of a counter update that keeps losing
+// to other instances updating the same counter.
+const maxSaveRetries = 5
+
+// loginGuard keeps the failure counters of every check of a user's
+// credentials, at login and before sensitive account changes alike, so
+// that none of them can be used to guess a password without limit.
+type loginGuard struct {
+	userRepo    *repository.UserRepository
+	authService *services.AuthService
+	attemptRepo *repository.LoginAttemptRepository
+	lockout     *services.LoginLockout
+}
+
+type lockoutTarget struct {
+	kind string
+	key  string
+}
+
+// lockoutTargets are the counters a login with email from the client
+// counts against.
+func lockoutTargets(c *gin.Context, email string) []lockoutTarget {
+	return []lockoutTarget{
+		{services.LockoutAccount, services.LoginAttemptKey(services.LockoutAccount, email)},
+		{services.LockoutIP, services.LoginAttemptKey(services.LockoutIP, c.ClientIP())},
+	}
+}
+
+// lockedFor returns how long the longest lockout among targets has left.
+// Failing to read the counters does not block logins.
+func (g *loginGuard) lockedFor(ctx context.Context, targets []lockoutTarget) time.Duration {
+	keys := make([]string, len(targets))
+	for i, target := range targets {
+		keys[i] = target.key
+	}
+	attempts, err := g.attemptRepo.FindLocked(ctx, keys)
+	if err != nil {
+		log.Printf("Warning: Failed to read login attempts: %v", err)
+		return 0
+	}
+
+	now := time.Now()
+	var remaining time.Duration
+	for i := range attempts {
+		remaining = max(remaining, services.LockRemaining(&attempts[i], now))
+	}
+	return remaining
+}
+
+// recordFailure counts a failed login against every target and returns how
+// long the client is now locked out, if at all.
+func (g *loginGuard) recordFailure(ctx context.Context, targets []lockoutTarget) time.Duration {
+	now := time.Now()
+	var lockedFor time.Duration
+	for _, target := range targets {
+		policy := g.lockout.Policy(target.kind)
+		for try := 0; try < maxSaveRetries; try++ {
+			attempt, err := g.attemptRepo.Find(ctx, target.key)
+			if err != nil {
+				log.Printf("Warning: Failed to read login attempts: %v", err)
+				break
+			}
+			if attempt == nil {
+				attempt = &models.LoginAttempt{Key: target.key, Kind: target.kind}
+			}
+
+			policy.Fail(attempt, now)
+			saved, err := g.attemptRepo.Save(ctx, attempt)
+			if err != nil {
+				log.Printf("Warning: Failed to record login attempt: %v", err)
+				break
+			}
+			if saved {
+				lockedFor = max(lockedFor, services.LockRemaining(attempt, now))
+				break
+			}
+		}
+	}
+	return lockedFor
+}
+
+// confirmPassword checks the signed-in user's password before an account
+// change. Wrong passwords count as failed logins, so they lock out as they
+// would at login. It writes the error response when it returns false.
+func (g *loginGuard) confirmPassword(ctx context.Context, c *gin.Context, user *models.User, password string) bool {
+	targets := lockoutTargets(c, user.Email)
+	if remaining := g.lockedFor(ctx, targets); remaining > 0 {
+		writeLockedOut(c, remaining)
+		return false
+	}
+	if !g.authService.VerifyPassword(user.PasswordHash, password) {
+		if remaining := g.recordFailure(ctx, targets); remaining > 0 {
+			writeLockedOut(c, remaining)
+			return false
+		}
+		// Not 401, which clients take for an expired access token
+		c.JSON(http.StatusForbidden, gin.H{"error": "incorrect password"})
+		return false
+	}
+	return true
+}
+
+// checkSecondFactor accepts an authenticator code or a recovery code, each
+// only once. Wrong codes count as failed logins, so they lock out like
+// wrong passwords. It writes the error response, with failStatus for a
+// wrong code, when it returns false.
+func (g *loginGuard) checkSecondFactor(ctx context.Context, c *gin.Context, user *models.User, code string, failStatus int) bool {
+	targets := lockoutTargets(c, user.Email)
+	if remaining := g.lockedFor(ctx, targets); remaining > 0 {
+		writeLockedOut(c, remaining)
+		return false
+	}
+
+	var valid bool
+	var err error
+	if services.IsTOTPCode(code) {
+		if step, ok := services.VerifyTOTP(user.TOTP.Secret, code, time.Now(), user.TOTP.LastStep); ok {
+			valid, err = g.userRepo.UseTOTPStep(ctx, user.ID, step)
+		}
+	} else {
+		valid, err = g.userRepo.UseRecoveryCode(ctx, user.ID, services.HashRecoveryCode(code))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return false
+	}
+
+	if !valid {
+		if remaining := g.recordFailure(ctx, targets); remaining > 0 {
+			writeLockedOut(c, remaining)
+			return false
+		}
+		c.JSON(failStatus, gin.H{"error": "invalid code"})
+		return false
+	}
+	return true
+}
+
+// confirmAccountChange confirms the signed-in user before a change that
+// could take over or destroy the account: their password, and with
+// two-factor on, an authenticator or recovery code as well. It writes the
+// error response when it returns false.
+func (g *loginGuard) confirmAccountChange(ctx context.Context, c *gin.Context, user *models.User, password, code string) bool {
+	if !g.confirmPassword(ctx, c, user, password) {
+		return false
+	}
+	if !user.TwoFactorEnabled {
+		return true
+	}
+	if code == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "two-factor code required"})
+		return false
+	}
+	return g.checkSecondFactor(ctx, c, user, code, http.StatusForbidden)
+}
+
+// clearAccount forgets the failed logins counted against the account.
+// Only the account's counter: a client's own valid login must not wipe
+// the failures it made against other accounts.
+func (g *loginGuard) clearAccount(ctx context.Context, email string) {
+	if _, err := g.attemptRepo.Delete(ctx, services.LoginAttemptKey(services.LockoutAccount, email)); err != nil {
+		log.Printf("Warning: Failed to clear login attempts: %v", err)
+	}
+}
+
+// allowMail counts a request for account mail to email against the mail
+// counters of the account and the client, and rejects it once either has
+// asked for too many. It reports whether the request may go ahead.
+func (g *loginGuard) allowMail(ctx context.Context, c *gin.Context, email string) bool {
+	targets := []lockoutTarget{
+		{services.LockoutMailAccount, services.LoginAttemptKey(services.LockoutMailAccount, email)},
+		{services.LockoutMailIP, services.LoginAttemptKey(services.LockoutMailIP, c.ClientIP())},
+	}
+	if remaining := g.lockedFor(ctx, targets); remaining > 0 {
+		writeTooManyRequests(c, remaining, "too many email requests")
+		return false
+	}
+	// The request reaching the limit is still served; the next ones wait
+	g.recordFailure(ctx, targets)
+	return true
+}
+
+// writeLockedOut rejects a login while the account or client is locked out.
+func writeLockedOut(c *gin.Context, remaining time.Duration) {
+	writeTooManyRequests(c, remaining, "too many failed login attempts")
+}
+
+func writeTooManyRequests(c *gin.Context, remaining time.Duration, message string) {
+	seconds := int(math.Ceil(remaining.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": message, "retry_after": seconds})
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+)
+
+const (
+	maxCoveragePositions = 500
+	gamesSource          = "games"
+)
+
+type CoverageHandler struct {
+	repertoireRepo  *repository.RepertoireRepository
+	gameRepo        *repository.GameRepository
+	explorerService *services.ExplorerService
+}
+
+func NewCoverageHandler(repertoireRepo *repository.RepertoireRepository, gameRepo *repository.GameRepository, explorerService *services.ExplorerService) *CoverageHandler {
+	return &CoverageHandler{
+		repertoireRepo:  repertoireRepo,
+		gameRepo:        gameRepo,
+		explorerService: explorerService,
+	}
+}
+
+// Coverage lists popular opponent replies the repertoire does not answer.
+// Move frequencies come from the explorer database picked by "source",
+// filtered by "ratings" and "speeds" as for the explorer endpoint, or from
+// the user's imported games with source=games.
+// "min_share", "min_games", "max_depth" and "max_positions" tune the report.
+func (h *CoverageHandler) Coverage(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := parseObjectID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid repertoire ID"})
+		return
+	}
+
+	opts, err := coverageOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ratings, speeds, err := services.ParseExplorerFilters(c.Query("ratings"), c.Query("speeds"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	source := c.DefaultQuery("source", services.ExplorerSourceLichess)
+	stats := func(ctx context.Context, fen string) (*models.ExplorerResponse, error) {
+		return h.explorerService.Lookup(ctx, services.ExplorerQuery{
+			Source:  source,
+			FEN:     fen,
+			Ratings: ratings,
+			Speeds:  speeds,
+		})
+	}
+
+	// Uncached positions are fetched one by one from upstream
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	repertoire, err := h.repertoireRepo.FindByIDAndUserID(ctx, id, userID)
+	if err != nil || repertoire == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "repertoire not found"})
+		return
+	}
+
+	if source == gamesSource {
+		games, err := h.gameRepo.FindByUserID(ctx, userID, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch games"})
+			return
+		}
+		// Only games played with the repertoire's color show what it meets
+		played := games[:0]
+		for _, game := range games {
+			if game.UserColor == repertoire.Color {
+				played = append(played, game)
+			}
+		}
+		stats = services.GameMoveStats(played)
+	}
+
+	report, err := services.RepertoireCoverage(ctx, repertoire, stats, opts)
+	if err != nil {
+		writeExplorerError(c, h.explorerService, err)
+		return
+	}
+	report.Source = source
+
+	c.JSON(http.StatusOK, report)
+}
+
+func coverageOptions(c *gin.Context) (services.CoverageOptions, error) {
+	opts := services.CoverageOptions{
+		MinShare:     services.DefaultCoverageMinShare,
+		MaxDepth:     services.DefaultCoverageMaxDepth,
+		MaxPositions: services.DefaultCoverageMaxPositions,
+	}
+
+	if s := c.Query("min_share"); s != "" {
+		share, err := strconv.ParseFloat(s, 64)
+		if err != nil || share < 0 || share > 1 {
+			return opts, errors.New("min_share must be between 0 and 1")
+		}
+		opts.MinShare = share
+	}
+	if s := c.Query("min_games"); s != "" {
+		games, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || games < 0 {
+			return opts, errors.New("invalid min_games")
+		}
+		opts.MinGames = games
+	}
+	if s := c.Query("max_depth"); s != "" {
+		depth, err := strconv.Atoi(s)
+		if err != nil || depth < 0 {
+			return opts, errors.New("invalid max_depth")
+		}
+		opts.MaxDepth = depth
+	}
+	if s := c.Query("max_positions"); s != "" {
+		positions, err := strconv.Atoi(s)
+		if err != nil || positions < 1 || positions > maxCoveragePositions {
+			return opts, errors.New("max_positions must be between 1 and " + strconv.Itoa(maxCoveragePositions))
+		}
+		opts.MaxPositions = positions
+	}
+	return opts, nil
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+)
+
+type EngineHandler struct {
+	engineService *services.EngineService
+	evalRepo      *repository.EvalRepository
+}
+
+func NewEngineHandler(engineService *services.EngineService, evalRepo *repository.EvalRepository) *EngineHandler {
+	return &EngineHandler{
+		engineService: engineService,
+		evalRepo:      evalRepo,
+	}
+}
+
+// Analyze runs the server's engine on one position and shares the result
+// through the eval store.
+func (h *EngineHandler) Analyze(c *gin.Context) {
+	if _, err := getUserID(c); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req models.AnalyzeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Waiting for a free engine counts against the timeout too
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	analysis, err := h.engineService.Analyze(ctx, services.EngineQuery{
+		FEN:      req.FEN,
+		Depth:    req.Depth,
+		MoveTime: time.Duration(req.MoveTimeMS) * time.Millisecond,
+		MultiPV:  req.MultiPV,
+	})
+	if err != nil {
+		writeEngineError(c, err)
+		return
+	}
+
+	if eval, ok := services.EngineEval(analysis); ok {
+		if _, err := h.evalRepo.SaveDeepest(ctx, []models.PositionEval{eval}); err != nil {
+			log.Printf("Warning: Failed to store engine eval: %v", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, analysis)
+}
+
+func writeEngineError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrEngineUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "engine analysis is not available"})
+	case errors.Is(err, services.ErrInvalidEngineQuery):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "engine analysis timed out"})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{"error": "engine analysis failed"})
+	}
+}
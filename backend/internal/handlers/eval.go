@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+)
+
+const maxEvalBatch = 200
+
+type EvalHandler struct {
+	evalRepo *repository.EvalRepository
+}
+
+func NewEvalHandler(evalRepo *repository.EvalRepository) *EvalHandler {
+	return &EvalHandler{
+		evalRepo: evalRepo,
+	}
+}
+
+// Get returns the stored evals of the positions given as repeated "fen"
+// parameters, optionally only those at least "min_depth" deep.
+func (h *EvalHandler) Get(c *gin.Context) {
+	if _, err := getUserID(c); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	requested := c.QueryArray("fen")
+	if len(requested) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fen is required"})
+		return
+	}
+	if len(requested) > maxEvalBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at most " + strconv.Itoa(maxEvalBatch) + " positions per request"})
+		return
+	}
+
+	minDepth := 0
+	if s := c.Query("min_depth"); s != "" {
+		depth, err := strconv.Atoi(s)
+		if err != nil || depth < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_depth"})
+			return
+		}
+		minDepth = depth
+	}
+
+	keys := make([]string, len(requested))
+	for i, fen := range requested {
+		key, err := chess.NormalizeFEN(fen)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid fen: " + fen})
+			return
+		}
+		keys[i] = key
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	evals, err := h.evalRepo.FindByFENs(ctx, keys, minDepth)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch evals"})
+		return
+	}
+
+	byKey := make(map[string]models.PositionEval, len(evals))
+	for _, eval := range evals {
+		byKey[eval.FEN] = eval
+	}
+	response := models.EvalsResponse{Evals: map[string]models.PositionEval{}}
+	for i, fen := range requested {
+		if eval, ok := byKey[keys[i]]; ok {
+			response.Evals[fen] = eval
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Put stores evals computed by clients, keeping the deepest eval known for
+// each position. Client evals never replace the server engine's.
+func (h *EvalHandler) Put(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req models.PutEvalsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Evals) > maxEvalBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at most " + strconv.Itoa(maxEvalBatch) + " evals per request"})
+		return
+	}
+
+	for i := range req.Evals {
+		eval := &req.Evals[i]
+		if eval.Depth > services.MaxClientEvalDepth {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "evals[" + strconv.Itoa(i) + "]: depth must be at most " + strconv.Itoa(services.MaxClientEvalDepth)})
+			return
+		}
+		if err := services.NormalizeEval(eval); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "evals[" + strconv.Itoa(i) + "]: " + err.Error()})
+			return
+		}
+		eval.Source = models.EvalSourceClient
+		eval.ContributedBy = userID
+	}
+	evals := services.DeepestEvals(req.Evals)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	stored, err := h.evalRepo.SaveDeepest(ctx, evals)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store evals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PutEvalsResponse{Stored: stored, Kept: len(req.Evals) - stored})
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/services"
+)
+
+type ExplorerHandler struct {
+	explorerService *services.ExplorerService
+}
+
+func NewExplorerHandler(explorerService *services.ExplorerService) *ExplorerHandler {
+	return &ExplorerHandler{
+		explorerService: explorerService,
+	}
+}
+
+// Get returns the Lichess explorer's statistics for a position. "source"
+// picks the lichess (default) or masters database; "ratings" and "speeds"
+// are comma-separated filters for the lichess one.
+func (h *ExplorerHandler) Get(c *gin.Context) {
+	if c.Query("fen") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fen is required"})
+		return
+	}
+
+	ratings, speeds, err := services.ParseExplorerFilters(c.Query("ratings"), c.Query("speeds"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.explorerService.Lookup(c.Request.Context(), services.ExplorerQuery{
+		Source:  c.Query("source"),
+		FEN:     c.Query("fen"),
+		Ratings: ratings,
+		Speeds:  speeds,
+	})
+	if err != nil {
+		writeExplorerError(c, h.explorerService, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// writeExplorerError maps an explorer lookup error to its HTTP response.
+func writeExplorerError(c *gin.Context, explorerService *services.ExplorerService, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidExplorerQuery):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrExplorerRateLimited):
+		seconds := int(math.Ceil(explorerService.RetryAfter().Seconds()))
+		c.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "opening explorer is rate limited, retry later"})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to fetch explorer data"})
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+)
+
+type GameHandler struct {
+	gameRepo       *repository.GameRepository
+	repertoireRepo *repository.RepertoireRepository
+	userRepo       *repository.UserRepository
+}
+
+func NewGameHandler(gameRepo *repository.GameRepository, repertoireRepo *repository.RepertoireRepository, userRepo *repository.UserRepository) *GameHandler {
+	return &GameHandler{
+		gameRepo:       gameRepo,
+		repertoireRepo: repertoireRepo,
+		userRepo:       userRepo,
+	}
+}
+
+// Import stores the user's games from PGN and replays each against the
+// user's repertoires of the side they played. Games already imported are
+// skipped.
+func (h *GameHandler) Import(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	// Accept either {"pgn": "...", "color": "..."} or the raw PGN file as
+	// the request body with ?color=
+	var req models.ImportGamesRequest
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPGNSize)
+	if c.ContentType() == "application/json" {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writePGNBindError(c, err)
+			return
+		}
+	} else {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "PGN too large"})
+			return
+		}
+		req.PGN = string(body)
+		req.Color = c.Query("color")
+	}
+	if req.Color != "" && req.Color != "white" && req.Color != "black" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "color must be white or black"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	user, err := h.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	games, importErrs := services.GamesFromPGN(req.PGN, req.Color, user.Username)
+	if len(games) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, models.ImportGamesResponse{Imported: games, Errors: importErrs})
+		return
+	}
+
+	repertoires, err := h.repertoireRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch repertoires"})
+		return
+	}
+	books := repertoireBooks(repertoires)
+
+	now := time.Now()
+	for i := range games {
+		games[i].UserID = userID
+		games[i].ImportedAt = now
+		services.ReplayGame(books[games[i].UserColor], &games[i])
+	}
+
+	imported, duplicates, err := h.gameRepo.Import(ctx, games)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to import games"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ImportGamesResponse{Imported: imported, Duplicates: duplicates, Errors: importErrs})
+}
+
+func (h *GameHandler) List(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	limit := 50
+	if s := c.Query("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	games, err := h.gameRepo.FindByUserID(ctx, userID, int64(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch games"})
+		return
+	}
+
+	c.JSON(http.StatusOK, games)
+}
+
+func (h *GameHandler) Get(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := parseObjectID(c.Param("gameId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid game ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	game, err := h.gameRepo.FindByIDAndUserID(ctx, id, userID)
+	if err != nil || game == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "game not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, game)
+}
+
+func (h *GameHandler) Delete(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := parseObjectID(c.Param("gameId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid game ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	deleted, err := h.gameRepo.Delete(ctx, id, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete game"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "game not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "game deleted"})
+}
+
+// Reanalyze replays every game of the user against the current
+// repertoires, after the repertoires have changed.
+func (h *GameHandler) Reanalyze(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	games, err := h.gameRepo.FindByUserID(ctx, userID, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch games"})
+		return
+	}
+
+	repertoires, err := h.repertoireRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch repertoires"})
+		return
+	}
+
+	books := repertoireBooks(repertoires)
+	for i := range games {
+		services.ReplayGame(books[games[i].UserColor], &games[i])
+	}
+
+	if err := h.gameRepo.SaveAnalyses(ctx, games); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save game analyses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, services.SummarizeGames(games))
+}
+
+// Report aggregates, per opening, where the user's games left the
+// repertoire and who left it.
+func (h *GameHandler) Report(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	games, err := h.gameRepo.FindByUserID(ctx, userID, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch games"})
+		return
+	}
+
+	c.JSON(http.StatusOK, services.SummarizeGames(games))
+}
+
+func repertoireBooks(repertoires []models.Repertoire) map[string]services.RepertoireBook {
+	return map[string]services.RepertoireBook{
+		"white": services.NewRepertoireBook(repertoires, "white"),
+		"black": services.NewRepertoireBook(repertoires, "black"),
+	}
+}
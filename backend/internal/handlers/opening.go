@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/eco"
+	"github.com/nagara/openings-master/backend/internal/services"
+)
+
+type OpeningHandler struct{}
+
+func NewOpeningHandler() *OpeningHandler {
+	return &OpeningHandler{}
+}
+
+// Classify names a position or a move sequence from the ECO table. With
+// only "fen", the position itself is looked up. With "moves" (SAN or UCI,
+// separated by spaces or commas), the moves are played from "fen", or the
+// standard starting position, and the deepest named position along the way
+// wins.
+func (h *OpeningHandler) Classify(c *gin.Context) {
+	fen := c.Query("fen")
+	moves := strings.FieldsFunc(c.Query("moves"), func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	if fen == "" && len(moves) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fen or moves is required"})
+		return
+	}
+
+	pos, err := services.StartingPosition(fen)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid fen"})
+		return
+	}
+
+	opening, ply, err := eco.ClassifyMoves(pos, moves)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if opening == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no matching opening"})
+		return
+	}
+
+	c.JSON(http.StatusOK, services.Classification(opening, ply))
+}
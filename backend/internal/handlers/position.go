@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PositionHandler struct {
+	positionRepo   *repository.PositionRepository
+	repertoireRepo *repository.RepertoireRepository
+}
+
+func NewPositionHandler(positionRepo *repository.PositionRepository, repertoireRepo *repository.RepertoireRepository) *PositionHandler {
+	return &PositionHandler{
+		positionRepo:   positionRepo,
+		repertoireRepo: repertoireRepo,
+	}
+}
+
+// Lookup returns every opening and node of the user's repertoires where the
+// position occurs, whatever the move order that led there, together with
+// the moves prepared from it.
+func (h *PositionHandler) Lookup(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if c.Query("fen") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fen is required"})
+		return
+	}
+	key, err := chess.NormalizeFEN(c.Query("fen"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid fen"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	versions, err := h.syncIndex(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update position index"})
+		return
+	}
+
+	entries, err := h.positionRepo.FindByFEN(ctx, userID, key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch positions"})
+		return
+	}
+
+	// Skip entries a concurrent sync has not cleaned up yet
+	occurrences := make([]models.PositionEntry, 0, len(entries))
+	for _, entry := range entries {
+		if version, ok := versions[entry.RepertoireID]; ok && version == entry.RepertoireVersion {
+			occurrences = append(occurrences, entry)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.PositionLookupResponse{FEN: key, Occurrences: occurrences})
+}
+
+// syncIndex catches up on repertoires whose index was not rebuilt when they
+// were written, such as those saved before the index existed, and drops
+// the entries of deleted repertoires. It returns the current version of
+// each of the user's repertoires.
+func (h *PositionHandler) syncIndex(ctx context.Context, userID primitive.ObjectID) (map[primitive.ObjectID]int64, error) {
+	indexed, err := h.positionRepo.IndexedVersions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return syncRepertoires(ctx, h.repertoireRepo, userID, indexed,
+		func(repertoire *models.Repertoire) error {
+			entries := services.IndexPositions(repertoire)
+			return h.positionRepo.SyncRepertoire(ctx, repertoire.ID, repertoire.Version, entries)
+		},
+		func(repertoireIDs []primitive.ObjectID) error {
+			return h.positionRepo.DeleteOrphans(ctx, userID, repertoireIDs)
+		})
+}
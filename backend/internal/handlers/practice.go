@@ -2,23 +2,33 @@ package handlers
 
 import (
 	"context"
+	"log"
+	"math/rand/v2"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/chess"
 	"github.com/nagara/openings-master/backend/internal/models"
 	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
 )
 
 type PracticeHandler struct {
 	practiceRepo   *repository.PracticeRepository
 	repertoireRepo *repository.RepertoireRepository
+	reviewRepo     *repository.ReviewRepository
+	evalRepo       *repository.EvalRepository
+	classifier     *services.MoveClassifier
 }
 
-func NewPracticeHandler(practiceRepo *repository.PracticeRepository, repertoireRepo *repository.RepertoireRepository) *PracticeHandler {
+func NewPracticeHandler(practiceRepo *repository.PracticeRepository, repertoireRepo *repository.RepertoireRepository, reviewRepo *repository.ReviewRepository, evalRepo *repository.EvalRepository, classifier *services.MoveClassifier) *PracticeHandler {
 	return &PracticeHandler{
 		practiceRepo:   practiceRepo,
 		repertoireRepo: repertoireRepo,
+		reviewRepo:     reviewRepo,
+		evalRepo:       evalRepo,
+		classifier:     classifier,
 	}
 }
 
@@ -60,18 +70,105 @@ func (h *PracticeHandler) Start(c *gin.Context) {
 		}
 	}
 
+	if req.FEN != "" {
+		if _, err := chess.NormalizeFEN(req.FEN); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid fen"})
+			return
+		}
+	}
+
+	var opening *models.Opening
+	var rootPath []int
+	switch {
+	case req.OpeningID != "":
+		openingID, err := parseObjectID(req.OpeningID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid opening ID"})
+			return
+		}
+		opening = findOpening(repertoire, openingID)
+		if opening == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "opening not found"})
+			return
+		}
+		if req.Node != "" {
+			rootPath, err = services.ResolveNode(opening.Moves, req.Node)
+			if err != nil {
+				writeNodeError(c, err)
+				return
+			}
+		} else if req.FEN != "" {
+			rootPath, err = services.FindPosition(opening, req.FEN)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "position not found in the opening"})
+				return
+			}
+		}
+	case req.Node != "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "opening_id is required to start from a node"})
+		return
+	case req.FEN != "":
+		for i := range repertoire.Openings {
+			if path, err := services.FindPosition(&repertoire.Openings[i], req.FEN); err == nil {
+				opening, rootPath = &repertoire.Openings[i], path
+				break
+			}
+		}
+		if opening == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "position not found in the repertoire"})
+			return
+		}
+	default:
+		opening = randomOpening(repertoire)
+		if opening == nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "repertoire has no openings to practice"})
+			return
+		}
+	}
+
+	start, err := services.StartingPosition(opening.StartingFEN)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "opening has an invalid starting position"})
+		return
+	}
+
 	session := &models.PracticeSession{
 		UserID:       userID,
 		RepertoireID: repertoireID,
+		OpeningID:    opening.ID,
 		Mode:         req.Mode,
 		Color:        repertoire.Color,
+		Line:         []string{},
 		Config:       *config,
 	}
 
-	if req.OpeningID != "" {
-		openingID, err := parseObjectID(req.OpeningID)
-		if err == nil {
-			session.OpeningID = openingID
+	// Drilling a subtree starts at the root node's position, so the session's
+	// line, scoring and MaxMoves only count moves played from there
+	if len(rootPath) > 0 {
+		root := services.NodeAt(opening.Moves, rootPath)
+		start, err = chess.ParseFEN(root.FEN)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "node has an invalid position"})
+			return
+		}
+		session.RootNodeID = root.ID
+		session.RootPath = services.FormatNodePath(rootPath)
+		session.RootLine = services.LineTo(opening.Moves, rootPath)
+	}
+	session.StartingFEN = start.FEN()
+	session.CurrentFEN = start.FEN()
+
+	tree := services.NewOpeningTree(opening)
+	if rootPath != nil && len(tree.Moves(start.Key())) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "no prepared moves from this position"})
+		return
+	}
+
+	// When the repertoire's side does not move first, the opponent opens the line
+	if color, err := chess.ParseColor(repertoire.Color); err == nil && start.Turn != color {
+		if next, reply := h.opponentReply(ctx, session, tree, start); reply != nil {
+			session.CurrentFEN = next.FEN()
+			session.Line = append(session.Line, reply.Move)
 		}
 	}
 
@@ -109,6 +206,10 @@ func (h *PracticeHandler) SubmitMove(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "session already ended"})
 		return
 	}
+	if moveLimitReached(session.Config, len(session.Line)) {
+		c.JSON(http.StatusConflict, gin.H{"error": "the session reached its move limit"})
+		return
+	}
 
 	var req models.SubmitMoveRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -116,24 +217,269 @@ func (h *PracticeHandler) SubmitMove(c *gin.Context) {
 		return
 	}
 
+	repertoire, err := h.repertoireRepo.FindByIDAndUserID(ctx, session.RepertoireID, userID)
+	if err != nil || repertoire == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "repertoire not found"})
+		return
+	}
+
+	// Sessions started before the server tracked the position practice the
+	// whole repertoire and rely on the client's fen_before.
+	var tree services.OpeningTree
+	if session.OpeningID.IsZero() {
+		openings := make([]*models.Opening, len(repertoire.Openings))
+		for i := range repertoire.Openings {
+			openings[i] = &repertoire.Openings[i]
+		}
+		tree = services.NewOpeningTree(openings...)
+	} else {
+		opening := findOpening(repertoire, session.OpeningID)
+		if opening == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "opening not found"})
+			return
+		}
+		tree = services.NewOpeningTree(opening)
+	}
+
+	positionFEN := session.CurrentFEN
+	if positionFEN == "" {
+		positionFEN = req.FENBefore
+	}
+	if positionFEN == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fen_before is required"})
+		return
+	}
+
+	before, err := chess.ParseFEN(positionFEN)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "fen_before: " + err.Error()})
+		return
+	}
+
+	if req.FENBefore != "" && session.CurrentFEN != "" {
+		claimed, err := chess.NormalizeFEN(req.FENBefore)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "fen_before: " + err.Error()})
+			return
+		}
+		if claimed != before.Key() {
+			c.JSON(http.StatusConflict, gin.H{"error": "fen_before does not match the session position", "current_fen": session.CurrentFEN})
+			return
+		}
+	}
+
+	candidates := tree.Moves(before.Key())
+	if len(candidates) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "the line is complete, no prepared move at this position"})
+		return
+	}
+
+	userMove, err := before.ParseMove(req.UserMove)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "user_move: " + err.Error()})
+		return
+	}
+	after, _ := before.Apply(userMove)
+
+	if req.FENAfter != "" {
+		claimed, err := chess.ParseFEN(req.FENAfter)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "fen_after: " + err.Error()})
+			return
+		}
+		if claimed.Key() != after.Key() {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "fen_after does not match the position after user_move"})
+			return
+		}
+	}
+
+	accepted := services.AcceptedMoves(candidates, session.Config.Difficulty)
+	correct := services.ContainsMove(accepted, userMove.UCI())
+
+	expectedMoves := make([]string, len(accepted))
+	for i, node := range accepted {
+		expectedMoves[i] = node.Move
+	}
+
 	move := models.PracticeMove{
-		Ply:           len(session.Moves) + 1,
-		FENBefore:     req.FENBefore,
-		FENAfter:      req.FENAfter,
-		UserMove:      req.UserMove,
-		ExpectedMove:  req.ExpectedMove,
-		Category:      req.Category,
-		EvalBefore:    req.EvalBefore,
-		EvalAfter:     req.EvalAfter,
-		CentipawnLoss: req.CentipawnLoss,
-	}
-
-	if err := h.practiceRepo.AddMove(ctx, sessionID, move); err != nil {
+		Ply:          len(session.Moves) + 1,
+		FENBefore:    before.FEN(),
+		FENAfter:     after.FEN(),
+		UserMove:     before.SAN(userMove),
+		UserMoveUCI:  userMove.UCI(),
+		ExpectedMove: services.MainLineMove(accepted).Move,
+		Category:     models.MoveCategoryMistake,
+	}
+
+	// With engine evals a move off the repertoire is judged by how much it
+	// loses; without them it is simply wrong
+	accuracy := 0.0
+	if correct {
+		move.Category = models.MoveCategoryRepertoire
+		accuracy = 100
+	}
+	if evalBefore, evalAfter, ok := h.engineEvals(ctx, before, after); ok {
+		judgement := h.classifier.Classify(before.Turn, evalBefore, evalAfter)
+		move.EvalBefore = evalBefore
+		move.EvalAfter = evalAfter
+		move.CentipawnLoss = judgement.CentipawnLoss
+		accuracy = judgement.Accuracy
+		if !correct {
+			move.Category = judgement.Category
+		}
+	}
+	move.Accuracy = &accuracy
+
+	response := models.SubmitMoveResponse{
+		ExpectedMoves: expectedMoves,
+		CurrentFEN:    before.FEN(),
+	}
+
+	if correct {
+		played := []string{move.UserMove}
+		current := after
+		if next, reply := h.opponentReply(ctx, session, tree, after); reply != nil {
+			played = append(played, reply.Move)
+			current = next
+			response.OpponentMove = reply
+		}
+
+		if err := h.practiceRepo.AddMoveAndAdvance(ctx, sessionID, move, current.FEN(), played); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record move"})
+			return
+		}
+
+		response.CurrentFEN = current.FEN()
+		response.LineComplete = response.OpponentMove == nil || len(tree.Moves(current.Key())) == 0
+		response.SessionComplete = response.LineComplete || moveLimitReached(session.Config, len(session.Line)+len(played))
+	} else if err := h.practiceRepo.AddMove(ctx, sessionID, move); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record move"})
 		return
 	}
 
-	c.JSON(http.StatusOK, move)
+	// A sound move outside the repertoire is still a failed recall
+	reviewCategory := move.Category
+	if !correct && move.Category != models.MoveCategoryBlunder {
+		reviewCategory = models.MoveCategoryMistake
+	}
+
+	// The move is recorded either way; a scheduling failure only delays the next review
+	if err := h.recordReview(ctx, session, before.Key(), reviewCategory); err != nil {
+		log.Printf("Warning: Failed to update review card: %v", err)
+	}
+
+	response.Move = move
+	response.Correct = correct
+	c.JSON(http.StatusOK, response)
+}
+
+// engineEvals returns the server engine's evals of the positions before and
+// after a move, in centipawns from White's point of view. It returns false
+// unless both are known.
+func (h *PracticeHandler) engineEvals(ctx context.Context, before, after *chess.Position) (int, int, bool) {
+	stored, err := h.evalRepo.FindByFENs(ctx, []string{before.Key(), after.Key()}, 0)
+	if err != nil {
+		log.Printf("Warning: Failed to load evals: %v", err)
+		return 0, 0, false
+	}
+	evals := services.EngineEvals(stored)
+	// Engines have no line to report in a mate or stalemate
+	if eval, ok := services.TerminalEval(after.Key()); ok {
+		evals[after.Key()] = eval
+	}
+
+	evalBefore, okBefore := evals[before.Key()]
+	evalAfter, okAfter := evals[after.Key()]
+	if !okBefore || !okAfter {
+		return 0, 0, false
+	}
+	return services.EvalCentipawns(evalBefore, before), services.EvalCentipawns(evalAfter, after), true
+}
+
+// moveLimitReached reports whether a session whose line is played moves
+// long, counting both sides, can take no more moves.
+func moveLimitReached(config models.PracticeConfig, played int) bool {
+	return config.MaxMoves > 0 && played >= config.MaxMoves
+}
+
+// opponentReply picks the opponent's prepared reply in pos according to the
+// session's policy. It returns nil when the line has no reply prepared.
+func (h *PracticeHandler) opponentReply(ctx context.Context, session *models.PracticeSession, tree services.OpeningTree, pos *chess.Position) (*chess.Position, *models.OpponentMove) {
+	candidates := tree.Moves(pos.Key())
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	policy := services.OpponentPolicy(session.Config)
+	weight := func(models.MoveNode) float64 { return 1 }
+	if policy == services.PolicyWeighted {
+		cards, err := h.reviewRepo.FindByRepertoire(ctx, session.UserID, session.RepertoireID)
+		if err != nil {
+			log.Printf("Warning: Failed to load review cards: %v", err)
+		}
+		byFEN := make(map[string]*models.ReviewCard, len(cards))
+		for i := range cards {
+			byFEN[cards[i].FEN] = &cards[i]
+		}
+		weight = func(node models.MoveNode) float64 {
+			key, err := chess.NormalizeFEN(node.FEN)
+			if err != nil {
+				return 1
+			}
+			return services.ReviewWeight(byFEN[key])
+		}
+	}
+
+	node := services.ChooseOpponentMove(candidates, policy, weight)
+	m, err := pos.ParseUCI(node.UCI)
+	if err != nil {
+		return nil, nil
+	}
+	next, err := pos.Apply(m)
+	if err != nil {
+		return nil, nil
+	}
+	return next, &models.OpponentMove{
+		Move: pos.SAN(m),
+		UCI:  m.UCI(),
+		FEN:  next.FEN(),
+	}
+}
+
+// randomOpening picks one of the repertoire's openings that has moves to practice.
+func randomOpening(repertoire *models.Repertoire) *models.Opening {
+	var playable []*models.Opening
+	for i := range repertoire.Openings {
+		if len(repertoire.Openings[i].Moves) > 0 {
+			playable = append(playable, &repertoire.Openings[i])
+		}
+	}
+	if len(playable) == 0 {
+		return nil
+	}
+	return playable[rand.IntN(len(playable))]
+}
+
+// recordReview feeds a practice result into the spaced-repetition card of
+// the position the user just moved from.
+func (h *PracticeHandler) recordReview(ctx context.Context, session *models.PracticeSession, fen, category string) error {
+	card, err := h.reviewRepo.FindByPosition(ctx, session.UserID, session.RepertoireID, fen)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if card == nil {
+		card = services.NewReviewCard(now)
+		card.UserID = session.UserID
+		card.RepertoireID = session.RepertoireID
+		card.OpeningID = session.OpeningID
+		card.FEN = fen
+		card.Moves = []string{}
+		card.ExpectedMoves = []string{}
+	}
+
+	services.ScheduleReview(card, services.ReviewQuality(category), now)
+	return h.reviewRepo.Save(ctx, card)
 }
 
 func (h *PracticeHandler) End(c *gin.Context) {
@@ -222,29 +568,34 @@ func calculateStats(moves []models.PracticeMove) models.PracticeStats {
 		TotalMoves: len(moves),
 	}
 
+	var accuracy float64
 	for _, move := range moves {
 		switch move.Category {
-		case "repertoire":
-			stats.BookMoves++ // Correct moves (using BookMoves field for backwards compatibility)
-		case "mistake":
-			stats.Mistakes++
-		// Legacy categories for backwards compatibility
-		case "book":
+		case models.MoveCategoryRepertoire, "book":
 			stats.BookMoves++
-		case "best":
+		case models.MoveCategoryBest:
 			stats.BestMoves++
-		case "good":
+		case models.MoveCategoryGood:
 			stats.GoodMoves++
-		case "inaccuracy":
+		case models.MoveCategoryInaccuracy:
 			stats.Inaccuracies++
-		case "blunder":
+		case models.MoveCategoryMistake:
+			stats.Mistakes++
+		case models.MoveCategoryBlunder:
 			stats.Blunders++
 		}
+
+		switch {
+		case move.Accuracy != nil:
+			accuracy += *move.Accuracy
+		case move.Category == models.MoveCategoryRepertoire || move.Category == "book":
+			// Moves recorded before accuracy was computed were right or wrong
+			accuracy += 100
+		}
 	}
 
 	if stats.TotalMoves > 0 {
-		correctMoves := stats.BookMoves + stats.BestMoves + stats.GoodMoves
-		stats.AccuracyPercentage = float64(correctMoves) / float64(stats.TotalMoves) * 100
+		stats.AccuracyPercentage = accuracy / float64(stats.TotalMoves)
 	}
 
 	return stats
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+	"github.com/nagara/openings-master/backend/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestMoveLimitReached(t *testing.T) {
+	tests := []struct {
+		maxMoves, played int
+		want             bool
+	}{
+		{0, 100, false},
+		{10, 9, false},
+		{10, 10, true},
+		{10, 11, true},
+	}
+	for _, tt := range tests {
+		if got := moveLimitReached(models.PracticeConfig{MaxMoves: tt.maxMoves}, tt.played); got != tt.want {
+			t.Errorf("%d moves played of %d: got %v, want %v", tt.played, tt.maxMoves, got, tt.want)
+		}
+	}
+}
+
+func TestSubmitMoveAfterMoveLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("rejected", func(mt *mtest.T) {
+		userID := primitive.NewObjectID()
+		session := models.PracticeSession{
+			ID:           primitive.NewObjectID(),
+			UserID:       userID,
+			RepertoireID: primitive.NewObjectID(),
+			Color:        "white",
+			CurrentFEN:   "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2",
+			Line:         []string{"e4", "e5"},
+			Config:       models.PracticeConfig{MaxMoves: 2},
+		}
+		doc, err := bson.Marshal(session)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found bson.D
+		if err := bson.Unmarshal(doc, &found); err != nil {
+			t.Fatal(err)
+		}
+		// Only the session is looked up: nothing is read or recorded after it
+		mt.AddMockResponses(mtest.CreateCursorResponse(1, mt.DB.Name()+".practice_sessions", mtest.FirstBatch, found))
+
+		database.DB = mt.DB
+		h := NewPracticeHandler(repository.NewPracticeRepository(), repository.NewRepertoireRepository(services.IndexPositions), repository.NewReviewRepository(), repository.NewEvalRepository(), nil)
+		r := gin.New()
+		r.POST("/practice/:sessionId/move", func(c *gin.Context) {
+			c.Set("userID", userID.Hex())
+		}, h.SubmitMove)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/practice/"+session.ID.Hex()+"/move", strings.NewReader(`{"user_move":"Nf3"}`)))
+		if w.Code != http.StatusConflict {
+			t.Errorf("status %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+		}
+	})
+}
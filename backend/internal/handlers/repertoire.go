@@ -2,15 +2,22 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/nagara/openings-master/backend/internal/models"
 	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const maxPGNSize = 5 << 20
+
 type RepertoireHandler struct {
 	repertoireRepo *repository.RepertoireRepository
 }
@@ -67,6 +74,7 @@ func (h *RepertoireHandler) Create(c *gin.Context) {
 		return
 	}
 
+	setETag(c, repertoire.Version)
 	c.JSON(http.StatusCreated, repertoire)
 }
 
@@ -96,6 +104,7 @@ func (h *RepertoireHandler) Get(c *gin.Context) {
 		return
 	}
 
+	setETag(c, repertoire.Version)
 	c.JSON(http.StatusOK, repertoire)
 }
 
@@ -125,6 +134,10 @@ func (h *RepertoireHandler) Update(c *gin.Context) {
 		return
 	}
 
+	if !checkIfMatch(c, repertoire) {
+		return
+	}
+
 	var req models.CreateRepertoireRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -135,10 +148,11 @@ func (h *RepertoireHandler) Update(c *gin.Context) {
 	repertoire.Color = req.Color
 
 	if err := h.repertoireRepo.Update(ctx, repertoire); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update repertoire"})
+		writeRepositoryError(c, err, "failed to update repertoire")
 		return
 	}
 
+	setETag(c, repertoire.Version)
 	c.JSON(http.StatusOK, repertoire)
 }
 
@@ -168,8 +182,12 @@ func (h *RepertoireHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.repertoireRepo.Delete(ctx, id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete repertoire"})
+	if !checkIfMatch(c, repertoire) {
+		return
+	}
+
+	if err := h.repertoireRepo.Delete(ctx, id, repertoire.Version); err != nil {
+		writeRepositoryError(c, err, "failed to delete repertoire")
 		return
 	}
 
@@ -198,6 +216,10 @@ func (h *RepertoireHandler) AddOpening(c *gin.Context) {
 		return
 	}
 
+	if !checkIfMatch(c, repertoire) {
+		return
+	}
+
 	var req models.AddOpeningRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -208,17 +230,179 @@ func (h *RepertoireHandler) AddOpening(c *gin.Context) {
 		Name:        req.Name,
 		ECO:         req.ECO,
 		StartingFEN: req.StartingFEN,
+		Notes:       req.Notes,
 		Moves:       req.Moves,
 	}
 
-	if err := h.repertoireRepo.AddOpening(ctx, id, opening); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add opening"})
+	if err := services.NormalizeOpening(&opening); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	services.ClassifyOpening(&opening)
+	if opening.Name == "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "name is required when the opening has no known ECO line"})
+		return
+	}
+	services.AssignNodeIDs(opening.Moves)
+
+	if err := h.repertoireRepo.AddOpening(ctx, id, repertoire.Version, &opening); err != nil {
+		writeRepositoryError(c, err, "failed to add opening")
 		return
 	}
 
+	setETag(c, repertoire.Version+1)
 	c.JSON(http.StatusCreated, opening)
 }
 
+func (h *RepertoireHandler) ImportOpenings(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := parseObjectID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid repertoire ID"})
+		return
+	}
+
+	// Accept either {"pgn": "..."} or the raw PGN file as the request body
+	var text string
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPGNSize)
+	if c.ContentType() == "application/json" {
+		var req models.ImportPGNRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writePGNBindError(c, err)
+			return
+		}
+		text = req.PGN
+	} else {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "PGN too large"})
+			return
+		}
+		text = string(body)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	repertoire, err := h.repertoireRepo.FindByIDAndUserID(ctx, id, userID)
+	if err != nil || repertoire == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "repertoire not found"})
+		return
+	}
+
+	if !checkIfMatch(c, repertoire) {
+		return
+	}
+
+	openings, importErrs := services.OpeningsFromPGN(text)
+	if len(openings) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, models.ImportPGNResponse{Openings: openings, Errors: importErrs})
+		return
+	}
+
+	if err := h.repertoireRepo.AddOpenings(ctx, id, repertoire.Version, openings); err != nil {
+		writeRepositoryError(c, err, "failed to import openings")
+		return
+	}
+
+	setETag(c, repertoire.Version+1)
+	c.JSON(http.StatusCreated, models.ImportPGNResponse{Openings: openings, Errors: importErrs})
+}
+
+func (h *RepertoireHandler) ExportPGN(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := parseObjectID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid repertoire ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	repertoire, err := h.repertoireRepo.FindByIDAndUserID(ctx, id, userID)
+	if err != nil || repertoire == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "repertoire not found"})
+		return
+	}
+
+	writePGN(c, repertoire.Name, services.RepertoireToPGN(repertoire))
+}
+
+// Analysis reports consistency problems in the repertoire's move trees.
+func (h *RepertoireHandler) Analysis(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := parseObjectID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid repertoire ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	repertoire, err := h.repertoireRepo.FindByIDAndUserID(ctx, id, userID)
+	if err != nil || repertoire == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "repertoire not found"})
+		return
+	}
+
+	setETag(c, repertoire.Version)
+	c.JSON(http.StatusOK, services.AnalyzeRepertoire(repertoire))
+}
+
+func (h *RepertoireHandler) ExportOpeningPGN(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	repertoireID, err := parseObjectID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid repertoire ID"})
+		return
+	}
+
+	openingID, err := parseObjectID(c.Param("openingId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid opening ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	repertoire, err := h.repertoireRepo.FindByIDAndUserID(ctx, repertoireID, userID)
+	if err != nil || repertoire == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "repertoire not found"})
+		return
+	}
+
+	opening := findOpening(repertoire, openingID)
+	if opening == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "opening not found"})
+		return
+	}
+
+	writePGN(c, opening.Name, services.GameFromOpening(repertoire, opening).String())
+}
+
 func (h *RepertoireHandler) UpdateOpening(c *gin.Context) {
 	userID, err := getUserID(c)
 	if err != nil {
@@ -247,6 +431,16 @@ func (h *RepertoireHandler) UpdateOpening(c *gin.Context) {
 		return
 	}
 
+	existing := findOpening(repertoire, openingID)
+	if existing == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "opening not found"})
+		return
+	}
+
+	if !checkIfMatch(c, repertoire) {
+		return
+	}
+
 	var req models.AddOpeningRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -258,14 +452,28 @@ func (h *RepertoireHandler) UpdateOpening(c *gin.Context) {
 		Name:        req.Name,
 		ECO:         req.ECO,
 		StartingFEN: req.StartingFEN,
+		Notes:       req.Notes,
 		Moves:       req.Moves,
 	}
 
-	if err := h.repertoireRepo.UpdateOpening(ctx, repertoireID, openingID, opening); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update opening"})
+	if err := services.NormalizeOpening(&opening); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	services.ClassifyOpening(&opening)
+	if opening.Name == "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "name is required when the opening has no known ECO line"})
+		return
+	}
+	services.CarryNodeIDs(existing.Moves, opening.Moves)
+	services.AssignNodeIDs(opening.Moves)
+
+	if err := h.repertoireRepo.UpdateOpening(ctx, repertoireID, repertoire.Version, openingID, opening); err != nil {
+		writeRepositoryError(c, err, "failed to update opening")
 		return
 	}
 
+	setETag(c, repertoire.Version+1)
 	c.JSON(http.StatusOK, opening)
 }
 
@@ -297,15 +505,91 @@ func (h *RepertoireHandler) DeleteOpening(c *gin.Context) {
 		return
 	}
 
-	if err := h.repertoireRepo.DeleteOpening(ctx, repertoireID, openingID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete opening"})
+	if !checkIfMatch(c, repertoire) {
 		return
 	}
 
+	if err := h.repertoireRepo.DeleteOpening(ctx, repertoireID, repertoire.Version, openingID); err != nil {
+		writeRepositoryError(c, err, "failed to delete opening")
+		return
+	}
+
+	setETag(c, repertoire.Version+1)
+
 	c.JSON(http.StatusOK, gin.H{"message": "opening deleted"})
 }
 
 // Helper functions
+func findOpening(repertoire *models.Repertoire, openingID primitive.ObjectID) *models.Opening {
+	for i := range repertoire.Openings {
+		if repertoire.Openings[i].ID == openingID {
+			return &repertoire.Openings[i]
+		}
+	}
+	return nil
+}
+
+// setETag exposes the repertoire's version as the response ETag, for
+// clients to send back in If-Match.
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", fmt.Sprintf(`"%d"`, version))
+}
+
+// checkIfMatch enforces the request's If-Match header, if any, against the
+// current version of the repertoire. It writes 412 and returns false when
+// the client edited a stale copy.
+func checkIfMatch(c *gin.Context, repertoire *models.Repertoire) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return true
+	}
+	current := fmt.Sprintf(`"%d"`, repertoire.Version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	setETag(c, repertoire.Version)
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": "repertoire was modified, reload and retry"})
+	return false
+}
+
+// writeRepositoryError reports a failed repertoire write, answering 412 when
+// another request changed the repertoire first.
+func writeRepositoryError(c *gin.Context, err error, message string) {
+	if errors.Is(err, repository.ErrVersionConflict) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "repertoire was modified, reload and retry"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+// writePGNBindError rejects a JSON PGN upload, which is read through a
+// MaxBytesReader like the raw one.
+func writePGNBindError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "PGN too large"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+func writePGN(c *gin.Context, name, body string) {
+	filename := strings.Map(func(r rune) rune {
+		if r == '"' || r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	if filename == "" {
+		filename = "repertoire"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pgn"`, filename))
+	c.Data(http.StatusOK, "application/x-chess-pgn; charset=utf-8", []byte(body))
+}
+
 func getUserID(c *gin.Context) (primitive.ObjectID, error) {
 	userIDStr := c.GetString("userID")
 	return parseObjectID(userIDStr)
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Node routes address a node by its ID or by its path in the opening's move
+// tree, e.g. "0.2.1". Each edit is a single versioned update, so an edit
+// based on a stale tree is rejected with 412 instead of landing on the
+// wrong node.
+
+func (h *RepertoireHandler) GetNode(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	repertoire, opening := h.loadOpening(ctx, c, userID)
+	if opening == nil {
+		return
+	}
+
+	path, err := services.ResolveNode(opening.Moves, c.Param("nodeId"))
+	if err != nil {
+		writeNodeError(c, err)
+		return
+	}
+
+	setETag(c, repertoire.Version)
+	c.JSON(http.StatusOK, nodeResponse(opening, path))
+}
+
+func (h *RepertoireHandler) AddNode(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req models.AddNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	repertoire, opening := h.loadOpening(ctx, c, userID)
+	if opening == nil || !checkIfMatch(c, repertoire) {
+		return
+	}
+
+	var parentPath []int
+	var parent *models.MoveNode
+	fen := opening.StartingFEN
+	if req.Parent != "" {
+		parentPath, err = services.ResolveNode(opening.Moves, req.Parent)
+		if err != nil {
+			writeNodeError(c, err)
+			return
+		}
+		parent = services.NodeAt(opening.Moves, parentPath)
+		fen = parent.FEN
+	}
+
+	siblings := services.ChildrenAt(opening, parentPath)
+	node, err := services.NewChildNode(fen, req.Move, siblings)
+	if errors.Is(err, services.ErrDuplicateMove) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "move: " + err.Error()})
+		return
+	}
+	node.Comment = req.Comment
+
+	var parentID primitive.ObjectID
+	if parent != nil {
+		parentID = parent.ID
+	}
+	if err := h.repertoireRepo.AddNode(ctx, repertoire.ID, repertoire.Version, opening.ID, parentPath, parentID, node); err != nil {
+		writeRepositoryError(c, err, "failed to add move")
+		return
+	}
+	if parent != nil {
+		parent.Children = append(parent.Children, node)
+	} else {
+		opening.Moves = append(opening.Moves, node)
+	}
+	path := append(parentPath[:len(parentPath):len(parentPath)], len(siblings))
+
+	setETag(c, repertoire.Version+1)
+	c.JSON(http.StatusCreated, nodeResponse(opening, path))
+}
+
+func (h *RepertoireHandler) DeleteNode(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	repertoire, opening := h.loadOpening(ctx, c, userID)
+	if opening == nil || !checkIfMatch(c, repertoire) {
+		return
+	}
+
+	path, err := services.ResolveNode(opening.Moves, c.Param("nodeId"))
+	if err != nil {
+		writeNodeError(c, err)
+		return
+	}
+
+	parentPath := path[:len(path)-1]
+	var parentID primitive.ObjectID
+	if len(parentPath) > 0 {
+		parentID = services.NodeAt(opening.Moves, parentPath).ID
+	}
+	siblings := services.ChildrenAt(opening, parentPath)
+	current := make([]primitive.ObjectID, len(siblings))
+	for i := range siblings {
+		current[i] = siblings[i].ID
+	}
+	remaining := services.WithoutNode(siblings, path[len(path)-1])
+
+	if err := h.repertoireRepo.DeleteNode(ctx, repertoire.ID, repertoire.Version, opening.ID, parentPath, parentID, current, remaining); err != nil {
+		writeRepositoryError(c, err, "failed to delete move")
+		return
+	}
+	setETag(c, repertoire.Version+1)
+	c.JSON(http.StatusOK, gin.H{"message": "node deleted"})
+}
+
+func (h *RepertoireHandler) PromoteNode(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	repertoire, opening := h.loadOpening(ctx, c, userID)
+	if opening == nil || !checkIfMatch(c, repertoire) {
+		return
+	}
+
+	path, err := services.ResolveNode(opening.Moves, c.Param("nodeId"))
+	if err != nil {
+		writeNodeError(c, err)
+		return
+	}
+	node := services.NodeAt(opening.Moves, path)
+
+	if err := h.repertoireRepo.PromoteNode(ctx, repertoire.ID, repertoire.Version, opening.ID, path, node.ID); err != nil {
+		writeRepositoryError(c, err, "failed to promote move")
+		return
+	}
+	siblings := services.ChildrenAt(opening, path[:len(path)-1])
+	for i := range siblings {
+		siblings[i].IsMainLine = siblings[i].ID == node.ID
+	}
+
+	setETag(c, repertoire.Version+1)
+	c.JSON(http.StatusOK, nodeResponse(opening, path))
+}
+
+func (h *RepertoireHandler) ReorderNode(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req models.ReorderNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		// A negative index is well-formed, only out of range
+		if req.Index != nil && *req.Index < 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "index out of range"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	repertoire, opening := h.loadOpening(ctx, c, userID)
+	if opening == nil || !checkIfMatch(c, repertoire) {
+		return
+	}
+
+	path, err := services.ResolveNode(opening.Moves, c.Param("nodeId"))
+	if err != nil {
+		writeNodeError(c, err)
+		return
+	}
+
+	parentPath := path[:len(path)-1]
+	siblings := services.ChildrenAt(opening, parentPath)
+	index := *req.Index
+	if index < 0 || index >= len(siblings) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "index out of range"})
+		return
+	}
+
+	current := make([]primitive.ObjectID, len(siblings))
+	for i := range siblings {
+		current[i] = siblings[i].ID
+	}
+	from := path[len(path)-1]
+	moved := siblings[from]
+	reordered := make([]models.MoveNode, 0, len(siblings))
+	reordered = append(reordered, siblings[:from]...)
+	reordered = append(reordered, siblings[from+1:]...)
+	reordered = append(reordered[:index], append([]models.MoveNode{moved}, reordered[index:]...)...)
+
+	if err := h.repertoireRepo.ReorderNodes(ctx, repertoire.ID, repertoire.Version, opening.ID, parentPath, current, reordered); err != nil {
+		writeRepositoryError(c, err, "failed to reorder moves")
+		return
+	}
+	copy(siblings, reordered)
+	setETag(c, repertoire.Version+1)
+	c.JSON(http.StatusOK, nodeResponse(opening, append(parentPath[:len(parentPath):len(parentPath)], index)))
+}
+
+func (h *RepertoireHandler) UpdateNodeComment(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req models.UpdateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	repertoire, opening := h.loadOpening(ctx, c, userID)
+	if opening == nil || !checkIfMatch(c, repertoire) {
+		return
+	}
+
+	path, err := services.ResolveNode(opening.Moves, c.Param("nodeId"))
+	if err != nil {
+		writeNodeError(c, err)
+		return
+	}
+	node := services.NodeAt(opening.Moves, path)
+
+	if err := h.repertoireRepo.SetNodeComment(ctx, repertoire.ID, repertoire.Version, opening.ID, path, node.ID, req.Comment); err != nil {
+		writeRepositoryError(c, err, "failed to update comment")
+		return
+	}
+	node.Comment = req.Comment
+	setETag(c, repertoire.Version+1)
+	c.JSON(http.StatusOK, nodeResponse(opening, path))
+}
+
+// loadOpening fetches the opening addressed by the :id and :openingId route
+// parameters. When it cannot, it writes the error response and returns nil.
+func (h *RepertoireHandler) loadOpening(ctx context.Context, c *gin.Context, userID primitive.ObjectID) (*models.Repertoire, *models.Opening) {
+	repertoireID, err := parseObjectID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid repertoire ID"})
+		return nil, nil
+	}
+
+	openingID, err := parseObjectID(c.Param("openingId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid opening ID"})
+		return nil, nil
+	}
+
+	repertoire, err := h.repertoireRepo.FindByIDAndUserID(ctx, repertoireID, userID)
+	if err != nil || repertoire == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "repertoire not found"})
+		return nil, nil
+	}
+
+	opening := findOpening(repertoire, openingID)
+	if opening == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "opening not found"})
+	}
+	return repertoire, opening
+}
+
+func writeNodeError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidNodePath) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid node ID or path"})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+}
+
+func nodeResponse(opening *models.Opening, path []int) models.NodeResponse {
+	return models.NodeResponse{
+		OpeningID: opening.ID,
+		Path:      services.FormatNodePath(path),
+		Line:      services.LineTo(opening.Moves, path),
+		FENBefore: services.FENBefore(opening, path),
+		Node:      *services.NodeAt(opening.Moves, path),
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+	"github.com/nagara/openings-master/backend/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestReorderNodeIndexBounds(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	userID := primitive.NewObjectID()
+	opening := models.Opening{ID: primitive.NewObjectID(), Moves: []models.MoveNode{
+		{Move: "e4", IsMainLine: true},
+		{Move: "d4"},
+	}}
+	if err := services.NormalizeOpening(&opening); err != nil {
+		t.Fatal(err)
+	}
+	services.AssignNodeIDs(opening.Moves)
+	repertoire := models.Repertoire{ID: primitive.NewObjectID(), UserID: userID, Name: "White", Color: "white", Openings: []models.Opening{opening}}
+	doc, err := bson.Marshal(repertoire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bson.D
+	if err := bson.Unmarshal(doc, &found); err != nil {
+		t.Fatal(err)
+	}
+	url := "/repertoires/" + repertoire.ID.Hex() + "/openings/" + opening.ID.Hex() + "/nodes/" + opening.Moves[1].ID.Hex() + "/index"
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		lookup bool // whether the repertoire is read before answering
+	}{
+		{"negative", `{"index":-1}`, http.StatusUnprocessableEntity, false},
+		{"past the last sibling", `{"index":2}`, http.StatusUnprocessableEntity, true},
+		{"missing", `{}`, http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			database.DB = mt.DB
+			if tt.lookup {
+				mt.AddMockResponses(mtest.CreateCursorResponse(1, mt.DB.Name()+".repertoires", mtest.FirstBatch, found))
+			}
+			h := NewRepertoireHandler(repository.NewRepertoireRepository(services.IndexPositions))
+
+			r := gin.New()
+			r.PUT("/repertoires/:id/openings/:openingId/nodes/:nodeId/index", func(c *gin.Context) {
+				c.Set("userID", userID.Hex())
+			}, h.ReorderNode)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, url, strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
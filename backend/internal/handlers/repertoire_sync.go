@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// syncRepertoires brings data derived from the user's repertoires, such as
+// review cards or the position index, up to date. synced gives the
+// repertoire version the data of each repertoire was built from; sync
+// rebuilds it for a repertoire that changed since, and deleteOrphans drops
+// it for repertoires that no longer exist. Only the stale repertoires are
+// loaded whole. It returns the current version of each of the user's
+// repertoires.
+func syncRepertoires(ctx context.Context, repertoireRepo *repository.RepertoireRepository, userID primitive.ObjectID, synced map[primitive.ObjectID]int64, sync func(*models.Repertoire) error, deleteOrphans func([]primitive.ObjectID) error) (map[primitive.ObjectID]int64, error) {
+	versions, err := repertoireRepo.FindVersions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	repertoireIDs := make([]primitive.ObjectID, 0, len(versions))
+	var stale []primitive.ObjectID
+	for id, version := range versions {
+		repertoireIDs = append(repertoireIDs, id)
+		if syncedVersion, ok := synced[id]; !ok || syncedVersion != version {
+			stale = append(stale, id)
+		}
+	}
+
+	if len(stale) > 0 {
+		repertoires, err := repertoireRepo.FindByIDs(ctx, userID, stale)
+		if err != nil {
+			return nil, err
+		}
+		for i := range repertoires {
+			repertoire := &repertoires[i]
+			// It may have changed again since its version was read
+			versions[repertoire.ID] = repertoire.Version
+			if err := sync(repertoire); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := deleteOrphans(repertoireIDs); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
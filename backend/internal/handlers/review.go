@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReviewHandler struct {
+	reviewRepo     *repository.ReviewRepository
+	repertoireRepo *repository.RepertoireRepository
+}
+
+func NewReviewHandler(reviewRepo *repository.ReviewRepository, repertoireRepo *repository.RepertoireRepository) *ReviewHandler {
+	return &ReviewHandler{
+		reviewRepo:     reviewRepo,
+		repertoireRepo: repertoireRepo,
+	}
+}
+
+// Due returns the positions to drill today, most urgent first. Cards of
+// repertoires that changed since their last sync are synced first, so new
+// lines show up immediately and deleted ones disappear.
+func (h *ReviewHandler) Due(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	limit := 50
+	if s := c.Query("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.syncCards(ctx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sync review cards"})
+		return
+	}
+
+	now := time.Now().UTC()
+	endOfDay := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 0, time.UTC)
+
+	cards, err := h.reviewRepo.FindDue(ctx, userID, endOfDay)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch due reviews"})
+		return
+	}
+
+	services.SortByUrgency(cards, now)
+	if len(cards) > limit {
+		cards = cards[:limit]
+	}
+
+	c.JSON(http.StatusOK, cards)
+}
+
+// syncCards rebuilds the cards of every repertoire that changed since its
+// cards were last synced and drops those of deleted repertoires.
+func (h *ReviewHandler) syncCards(ctx context.Context, userID primitive.ObjectID) error {
+	synced, err := h.reviewRepo.SyncedVersions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	_, err = syncRepertoires(ctx, h.repertoireRepo, userID, synced,
+		func(repertoire *models.Repertoire) error {
+			positions := services.ReviewPositions(repertoire)
+			return h.reviewRepo.SyncPositions(ctx, userID, repertoire.ID, repertoire.Version, positions, services.DefaultEaseFactor)
+		},
+		func(repertoireIDs []primitive.ObjectID) error {
+			return h.reviewRepo.DeleteOrphans(ctx, userID, repertoireIDs)
+		})
+	return err
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RevisionHandler struct {
+	revisionRepo   *repository.RevisionRepository
+	repertoireRepo *repository.RepertoireRepository
+}
+
+func NewRevisionHandler(revisionRepo *repository.RevisionRepository, repertoireRepo *repository.RepertoireRepository) *RevisionHandler {
+	return &RevisionHandler{
+		revisionRepo:   revisionRepo,
+		repertoireRepo: repertoireRepo,
+	}
+}
+
+// List returns the repertoire's revisions, newest first, without their
+// move trees.
+func (h *RevisionHandler) List(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := parseObjectID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid repertoire ID"})
+		return
+	}
+
+	limit := 50
+	if s := c.Query("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	repertoire, err := h.repertoireRepo.FindByIDAndUserID(ctx, id, userID)
+	if err != nil || repertoire == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "repertoire not found"})
+		return
+	}
+
+	revisions, err := h.revisionRepo.FindByRepertoire(ctx, id, int64(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+func (h *RevisionHandler) Get(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	_, revision := h.loadRevision(ctx, c, userID, c.Param("revisionId"))
+	if revision == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
+
+// Diff compares the move trees of two revisions. Without "to", the "from"
+// revision is compared with the current repertoire.
+func (h *RevisionHandler) Diff(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if c.Query("from") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	repertoire, from := h.loadRevision(ctx, c, userID, c.Query("from"))
+	if from == nil {
+		return
+	}
+
+	to := models.Revision{Version: repertoire.Version, Snapshot: services.Snapshot(repertoire)}
+	if c.Query("to") != "" {
+		_, revision := h.loadRevision(ctx, c, userID, c.Query("to"))
+		if revision == nil {
+			return
+		}
+		to = *revision
+	}
+
+	c.JSON(http.StatusOK, models.RevisionDiff{
+		FromVersion: from.Version,
+		ToVersion:   to.Version,
+		Fields:      services.SnapshotFields(from.Snapshot, to.Snapshot),
+		Openings:    services.DiffSnapshots(from.Snapshot, to.Snapshot),
+	})
+}
+
+// Restore resets the repertoire's name, color and openings to a revision.
+// The restore is itself a new revision, so it can be undone the same way.
+func (h *RevisionHandler) Restore(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	repertoire, revision := h.loadRevision(ctx, c, userID, c.Param("revisionId"))
+	if revision == nil || !checkIfMatch(c, repertoire) {
+		return
+	}
+
+	repertoire.Name = revision.Snapshot.Name
+	repertoire.Color = revision.Snapshot.Color
+	repertoire.Openings = revision.Snapshot.Openings
+	if repertoire.Openings == nil {
+		repertoire.Openings = []models.Opening{}
+	}
+
+	if err := h.repertoireRepo.Restore(ctx, repertoire); err != nil {
+		writeRepositoryError(c, err, "failed to restore revision")
+		return
+	}
+
+	setETag(c, repertoire.Version)
+	c.JSON(http.StatusOK, repertoire)
+}
+
+// loadRevision fetches the repertoire addressed by the :id route parameter
+// and one of its revisions. When it cannot, it writes the error response
+// and returns a nil revision.
+func (h *RevisionHandler) loadRevision(ctx context.Context, c *gin.Context, userID primitive.ObjectID, revisionRef string) (*models.Repertoire, *models.Revision) {
+	id, err := parseObjectID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid repertoire ID"})
+		return nil, nil
+	}
+
+	revisionID, err := parseObjectID(revisionRef)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision ID"})
+		return nil, nil
+	}
+
+	repertoire, err := h.repertoireRepo.FindByIDAndUserID(ctx, id, userID)
+	if err != nil || repertoire == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "repertoire not found"})
+		return nil, nil
+	}
+
+	revision, err := h.revisionRepo.FindByID(ctx, id, revisionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch revision"})
+		return nil, nil
+	}
+	if revision == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "revision not found"})
+		return nil, nil
+	}
+	return repertoire, revision
+}
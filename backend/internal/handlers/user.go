@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserHandler lets users manage their own account.
+type UserHandler struct {
+	userRepo       *repository.UserRepository
+	sessionRepo    *repository.SessionRepository
+	mailTokenRepo  *repository.MailTokenRepository
+	repertoireRepo *repository.RepertoireRepository
+	reviewRepo     *repository.ReviewRepository
+	practiceRepo   *repository.PracticeRepository
+	gameRepo       *repository.GameRepository
+	authService    *services.AuthService
+	guard          *loginGuard
+	mail           *accountMail
+}
+
+func NewUserHandler(userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository, mailTokenRepo *repository.MailTokenRepository, repertoireRepo *repository.RepertoireRepository, reviewRepo *repository.ReviewRepository, practiceRepo *repository.PracticeRepository, gameRepo *repository.GameRepository, attemptRepo *repository.LoginAttemptRepository, authService *services.AuthService, lockout *services.LoginLockout, accountMailer *services.AccountMailer) *UserHandler {
+	return &UserHandler{
+		userRepo:       userRepo,
+		sessionRepo:    sessionRepo,
+		mailTokenRepo:  mailTokenRepo,
+		repertoireRepo: repertoireRepo,
+		reviewRepo:     reviewRepo,
+		practiceRepo:   practiceRepo,
+		gameRepo:       gameRepo,
+		authService:    authService,
+		guard:          &loginGuard{userRepo: userRepo, authService: authService, attemptRepo: attemptRepo, lockout: lockout},
+		mail:           &accountMail{authService: authService, mailTokenRepo: mailTokenRepo, mailer: accountMailer},
+	}
+}
+
+// Update changes the username and preferences given, leaving the rest.
+func (h *UserHandler) Update(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	user, err := h.userRepo.UpdateProfile(ctx, userID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword sets a new password and signs out the user's other
+// sessions.
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := h.currentUser(ctx, c)
+	if !ok || !h.guard.confirmPassword(ctx, c, user, req.CurrentPassword) {
+		return
+	}
+
+	hash, err := h.authService.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		return
+	}
+	if err := h.userRepo.UpdatePassword(ctx, user.ID, hash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		return
+	}
+
+	// Without a session in the access token, the zero ID keeps none
+	current, _ := parseObjectID(c.GetString("sessionID"))
+	if _, err := h.sessionRepo.RevokeOthers(ctx, user.ID, current, models.RevokedPasswordChange); err != nil {
+		log.Printf("Warning: Failed to revoke sessions after password change: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password changed"})
+}
+
+// ChangeEmail mails a verification link to the new address. The account
+// keeps its current address until the link is opened.
+func (h *UserHandler) ChangeEmail(c *gin.Context) {
+	var req models.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	user, ok := h.currentUser(ctx, c)
+	if !ok || !h.guard.confirmAccountChange(ctx, c, user, req.Password, req.Code) {
+		return
+	}
+	if req.Email == user.Email {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "that is already your email"})
+		return
+	}
+
+	existing, err := h.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "email already registered"})
+		return
+	}
+
+	if err := h.userRepo.SetPendingEmail(ctx, user.ID, req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change email"})
+		return
+	}
+	if err := h.mail.sendVerification(ctx, user, req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "verification email sent to the new address"})
+}
+
+// Delete removes the account and everything the user owns. Evals stay, as
+// they are shared by all users.
+func (h *UserHandler) Delete(c *gin.Context) {
+	var req models.DeleteUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	user, ok := h.currentUser(ctx, c)
+	if !ok || !h.guard.confirmAccountChange(ctx, c, user, req.Password, req.Code) {
+		return
+	}
+
+	// The user goes last, so a failed deletion can be retried
+	cascade := []struct {
+		what   string
+		delete func(context.Context, primitive.ObjectID) error
+	}{
+		{"repertoires", h.repertoireRepo.DeleteByUser},
+		{"review cards", h.reviewRepo.DeleteByUser},
+		{"practice sessions", h.practiceRepo.DeleteByUser},
+		{"games", h.gameRepo.DeleteByUser},
+		{"mail tokens", h.mailTokenRepo.DeleteByUser},
+		{"sessions", h.sessionRepo.DeleteByUser},
+		{"user", h.userRepo.Delete},
+	}
+	for _, step := range cascade {
+		if err := step.delete(ctx, user.ID); err != nil {
+			log.Printf("Warning: Failed to delete %s of user %s: %v", step.what, user.ID.Hex(), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete account"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "account deleted"})
+}
+
+// currentUser loads the signed-in user, writing the error response when it
+// cannot.
+func (h *UserHandler) currentUser(ctx context.Context, c *gin.Context) (*models.User, bool) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return nil, false
+	}
+
+	user, err := h.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+	"github.com/nagara/openings-master/backend/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestAccountChangesRequireSecondFactor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	authService := services.NewAuthService("test-secret", time.Hour, time.Hour)
+	hash, err := authService.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := services.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{
+		ID:               primitive.NewObjectID(),
+		Email:            "player@example.com",
+		PasswordHash:     hash,
+		TwoFactorEnabled: true,
+		TOTP:             &models.TOTPSettings{Secret: secret},
+	}
+	doc, err := bson.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bson.D
+	if err := bson.Unmarshal(doc, &found); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"delete", http.MethodDelete, "/users/me", `{"password":"correct horse"}`},
+		{"change email", http.MethodPost, "/users/me/email", `{"email":"new@example.com","password":"correct horse"}`},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			// The user, then the lockout counters, none of them locked
+			mt.AddMockResponses(
+				mtest.CreateCursorResponse(1, mt.DB.Name()+".users", mtest.FirstBatch, found),
+				mtest.CreateCursorResponse(0, mt.DB.Name()+".login_attempts", mtest.FirstBatch),
+			)
+
+			database.DB = mt.DB
+			h := &UserHandler{
+				userRepo: repository.NewUserRepository(),
+				guard: &loginGuard{
+					userRepo:    repository.NewUserRepository(),
+					authService: authService,
+					attemptRepo: repository.NewLoginAttemptRepository(),
+				},
+			}
+			r := gin.New()
+			auth := func(c *gin.Context) { c.Set("userID", user.ID.Hex()) }
+			r.DELETE("/users/me", auth, h.Delete)
+			r.POST("/users/me/email", auth, h.ChangeEmail)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "two-factor code required") {
+				t.Errorf("status %d, want %d asking for a code: %s", w.Code, http.StatusForbidden, w.Body)
+			}
+		})
+	}
+}
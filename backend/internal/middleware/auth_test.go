@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/repository"
+	"github.com/nagara/openings-master/backend/internal/services"
+	"github.com/nagara/openings-master/backend/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestAuthMiddlewareChecksSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	authService := services.NewAuthService("test-secret", time.Hour, time.Hour)
+
+	userID := primitive.NewObjectID()
+	sessionID := primitive.NewObjectID()
+	accessToken, _, err := authService.GenerateTokenPair(userID.Hex(), "player@example.com", sessionID.Hex(), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedAt := time.Now()
+
+	tests := []struct {
+		name    string
+		session *models.Session
+		status  int
+	}{
+		{"live", &models.Session{ID: sessionID, UserID: userID, ExpiresAt: time.Now().Add(time.Hour)}, http.StatusOK},
+		{"revoked", &models.Session{ID: sessionID, UserID: userID, ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}, http.StatusUnauthorized},
+		{"deleted", nil, http.StatusUnauthorized},
+		{"of another user", &models.Session{ID: sessionID, UserID: primitive.NewObjectID(), ExpiresAt: time.Now().Add(time.Hour)}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			var found []bson.D
+			if tt.session != nil {
+				doc, err := bson.Marshal(tt.session)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var d bson.D
+				if err := bson.Unmarshal(doc, &d); err != nil {
+					t.Fatal(err)
+				}
+				found = append(found, d)
+			}
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+".sessions", mtest.FirstBatch, found...))
+
+			database.DB = mt.DB
+			r := gin.New()
+			r.GET("/me", AuthMiddleware(authService, repository.NewSessionRepository()), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Kinds of AnalysisIssue.
+const (
+	IssueConflictingChoice = "conflicting_choice"
+	IssueDuplicateLine     = "duplicate_line"
+	IssueIllegalMove       = "illegal_move"
+	IssueDanglingNode      = "dangling_node"
+	IssueFENMismatch       = "fen_mismatch"
+	IssueMissingReply      = "missing_reply"
+)
+
+type AnalysisReport struct {
+	RepertoireID primitive.ObjectID `json:"repertoire_id"`
+	Issues       []AnalysisIssue    `json:"issues"`
+	Counts       map[string]int     `json:"counts"` // issues per kind
+}
+
+type AnalysisIssue struct {
+	Kind      string         `json:"kind"`
+	Message   string         `json:"message"`
+	FEN       string         `json:"fen,omitempty"`   // position the issue is about, without move counters
+	Moves     []string       `json:"moves,omitempty"` // conflicting moves, in SAN
+	Locations []NodeLocation `json:"locations"`
+}
+
+// NodeLocation points at a node of one of a repertoire's move trees.
+type NodeLocation struct {
+	OpeningID   primitive.ObjectID `json:"opening_id"`
+	OpeningName string             `json:"opening_name"`
+	NodeID      primitive.ObjectID `json:"node_id"`
+	Path        string             `json:"path"`
+	Line        []string           `json:"line"` // SAN moves up to and including the node
+}
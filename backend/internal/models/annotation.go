@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Annotation job states, for AnnotationJob.Status.
+const (
+	JobQueued   = "queued"
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
+)
+
+// AnnotationJob evaluates every position of a repertoire in the background
+// and judges each prepared move by the evals before and after it. The
+// server instance running it holds a lease it keeps renewing; a job whose
+// lease ran out was abandoned.
+type AnnotationJob struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID            primitive.ObjectID `bson:"user_id" json:"user_id"`
+	RepertoireID      primitive.ObjectID `bson:"repertoire_id" json:"repertoire_id"`
+	RepertoireVersion int64              `bson:"repertoire_version" json:"repertoire_version"` // version the positions were taken from
+	Status            string             `bson:"status" json:"status"`
+	Depth             int                `bson:"depth" json:"depth"`
+	Positions         int                `bson:"positions" json:"positions"` // unique positions to evaluate
+	Evaluated         int                `bson:"evaluated" json:"evaluated"` // positions evaluated so far, stored ones included
+	FromStore         int                `bson:"from_store" json:"from_store"`
+	Annotations       []NodeAnnotation   `bson:"annotations" json:"annotations"` // filled when done
+	Dubious           int                `bson:"dubious" json:"dubious"`
+	Error             string             `bson:"error,omitempty" json:"error,omitempty"`
+	Owner             string             `bson:"owner" json:"-"` // server instance running the job
+	LeaseUntil        time.Time          `bson:"lease_until" json:"-"`
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+	StartedAt         *time.Time         `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt        *time.Time         `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+// NodeAnnotation is the engine's verdict on one prepared move.
+type NodeAnnotation struct {
+	OpeningID     primitive.ObjectID `bson:"opening_id" json:"opening_id"`
+	NodeID        primitive.ObjectID `bson:"node_id" json:"node_id"`
+	Path          string             `bson:"path" json:"path"`
+	Move          string             `bson:"move" json:"move"`
+	FEN           string             `bson:"fen" json:"fen"` // position after the move, without move counters
+	Depth         int                `bson:"depth" json:"depth"`
+	ScoreType     string             `bson:"score_type" json:"score_type"` // "cp" | "mate"
+	Score         int                `bson:"score" json:"score"`           // from White's point of view
+	CentipawnLoss int                `bson:"centipawn_loss" json:"centipawn_loss"`
+	Category      string             `bson:"category" json:"category"` // best, good, inaccuracy, mistake, blunder
+	Dubious       bool               `bson:"dubious" json:"dubious"`   // a mistake or a blunder
+}
+
+type StartAnnotationRequest struct {
+	Depth int `json:"depth" binding:"omitempty,min=1,max=40"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type CoverageReport struct {
+	RepertoireID primitive.ObjectID `json:"repertoire_id"`
+	Source       string             `json:"source"`    // where move frequencies came from
+	MinShare     float64            `json:"min_share"` // replies played less often than this are ignored
+	MinGames     int64              `json:"min_games"`
+	Positions    int                `json:"positions"`  // opponent-to-move positions checked
+	Incomplete   bool               `json:"incomplete"` // the source stopped answering before every position was checked
+	Gaps         []CoverageGap      `json:"gaps"`       // most played first
+}
+
+// CoverageGap is an opponent reply the repertoire has no answer for.
+type CoverageGap struct {
+	FEN           string         `json:"fen"` // position before the reply, without move counters
+	Move          string         `json:"move"`
+	UCI           string         `json:"uci"`
+	Games         int64          `json:"games"`          // games in which the reply was played
+	Share         float64        `json:"share"`          // of the games reaching the position
+	PositionGames int64          `json:"position_games"` // games reaching the position
+	Locations     []NodeLocation `json:"locations"`      // where the position occurs in the repertoire
+}
@@ -0,0 +1,25 @@
+package models
+
+type AnalyzeRequest struct {
+	FEN        string `json:"fen" binding:"required"`
+	Depth      int    `json:"depth" binding:"omitempty,min=1"`
+	MoveTimeMS int    `json:"movetime_ms" binding:"omitempty,min=1"`
+	MultiPV    int    `json:"multipv" binding:"omitempty,min=1"`
+}
+
+// EngineAnalysis is the engine's view of one position.
+type EngineAnalysis struct {
+	FEN      string       `json:"fen"`
+	Depth    int          `json:"depth"` // deepest line reported
+	BestMove string       `json:"best_move,omitempty"`
+	Lines    []EngineLine `json:"lines"` // best first, empty when the game is over
+}
+
+type EngineLine struct {
+	MultiPV   int      `json:"multipv"`
+	Depth     int      `json:"depth"`
+	ScoreType string   `json:"score_type"` // "cp" | "mate"
+	Score     int      `json:"score"`      // from White's point of view, like practice evals
+	PV        []string `json:"pv"`         // UCI
+	SAN       []string `json:"san"`
+}
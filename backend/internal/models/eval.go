@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	EvalSourceEngine = "engine" // the server's engine
+	EvalSourceClient = "client" // submitted by a user's browser
+)
+
+// PositionEval is the deepest engine evaluation known for a position,
+// shared by every user. FEN is the normalized position key, without move
+// counters, so transpositions share one entry. Evals from the server's
+// engine take precedence over submitted ones, whatever their depth.
+type PositionEval struct {
+	FEN           string             `bson:"_id" json:"fen" binding:"required"`
+	Depth         int                `bson:"depth" json:"depth" binding:"min=1,max=245"`
+	ScoreType     string             `bson:"score_type" json:"score_type" binding:"oneof=cp mate"`
+	Score         int                `bson:"score" json:"score"` // from White's point of view, like engine analyses
+	PV            []string           `bson:"pv" json:"pv"`       // UCI
+	Source        string             `bson:"source" json:"source"`
+	ContributedBy primitive.ObjectID `bson:"contributed_by,omitempty" json:"-"` // user who submitted a client eval
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+	AccessedAt    time.Time          `bson:"accessed_at" json:"-"` // eviction drops the least recently read first
+}
+
+type PutEvalsRequest struct {
+	Evals []PositionEval `json:"evals" binding:"required,min=1,dive"`
+}
+
+type PutEvalsResponse struct {
+	Stored int `json:"stored"` // new positions or deeper than the stored eval
+	Kept   int `json:"kept"`   // the stored eval was at least as deep or from the server's engine
+}
+
+type EvalsResponse struct {
+	Evals map[string]PositionEval `json:"evals"` // keyed by the FEN as requested, missing positions are absent
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ExplorerResponse mirrors the Lichess opening explorer's reply, keeping
+// only the move statistics. Field names follow the Lichess API so the
+// frontend can use either source.
+type ExplorerResponse struct {
+	White   int64            `bson:"white" json:"white"`
+	Draws   int64            `bson:"draws" json:"draws"`
+	Black   int64            `bson:"black" json:"black"`
+	Moves   []ExplorerMove   `bson:"moves" json:"moves"`
+	Opening *ExplorerOpening `bson:"opening,omitempty" json:"opening,omitempty"`
+}
+
+type ExplorerMove struct {
+	UCI           string `bson:"uci" json:"uci"`
+	SAN           string `bson:"san" json:"san"`
+	White         int64  `bson:"white" json:"white"`
+	Draws         int64  `bson:"draws" json:"draws"`
+	Black         int64  `bson:"black" json:"black"`
+	AverageRating int    `bson:"average_rating" json:"averageRating"`
+}
+
+type ExplorerOpening struct {
+	ECO  string `bson:"eco" json:"eco"`
+	Name string `bson:"name" json:"name"`
+}
+
+// Games is the number of games played in the position.
+func (r *ExplorerResponse) Games() int64 {
+	return r.White + r.Draws + r.Black
+}
+
+// Games is the number of games in which the move was played.
+func (m *ExplorerMove) Games() int64 {
+	return m.White + m.Draws + m.Black
+}
+
+// ExplorerCacheEntry stores an upstream explorer reply. Mongo removes the
+// entry once ExpiresAt has passed.
+type ExplorerCacheEntry struct {
+	Key       string           `bson:"_id"` // source, position and filters of the query
+	Response  ExplorerResponse `bson:"response"`
+	CreatedAt time.Time        `bson:"created_at"`
+	ExpiresAt time.Time        `bson:"expires_at"`
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Outcomes of replaying a game against the repertoire, for Game.Status.
+const (
+	GameStatusDeviated     = "deviated"      // a move left the prepared lines
+	GameStatusLineEnded    = "line_ended"    // the game went on past the end of the prepared line
+	GameStatusFollowed     = "followed"      // the game ended within the prepared lines
+	GameStatusNoRepertoire = "no_repertoire" // the starting position is not in any repertoire of the user's color
+)
+
+// Game is one of the user's own games, imported from PGN.
+type Game struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Fingerprint string             `bson:"fingerprint" json:"-"` // identifies the same game imported twice
+	White       string             `bson:"white" json:"white"`
+	Black       string             `bson:"black" json:"black"`
+	Event       string             `bson:"event,omitempty" json:"event,omitempty"`
+	Site        string             `bson:"site,omitempty" json:"site,omitempty"`
+	Date        string             `bson:"date,omitempty" json:"date,omitempty"` // as in the PGN, e.g. "2024.05.01"
+	Result      string             `bson:"result" json:"result"`
+	ECO         string             `bson:"eco,omitempty" json:"eco,omitempty"`
+	UserColor   string             `bson:"user_color" json:"user_color"` // side the user played: "white" | "black"
+	StartingFEN string             `bson:"starting_fen" json:"starting_fen"`
+	Moves       []string           `bson:"moves" json:"moves"` // SAN main line
+	Status      string             `bson:"status" json:"status"`
+	Deviation   *GameDeviation     `bson:"deviation,omitempty" json:"deviation,omitempty"`
+	ImportedAt  time.Time          `bson:"imported_at" json:"imported_at"`
+	AnalyzedAt  time.Time          `bson:"analyzed_at" json:"analyzed_at"`
+}
+
+// GameDeviation is where a game left the repertoire: the first move that is
+// not prepared, or the first move after the prepared line ran out.
+type GameDeviation struct {
+	RepertoireID  primitive.ObjectID `bson:"repertoire_id" json:"repertoire_id"`
+	OpeningID     primitive.ObjectID `bson:"opening_id" json:"opening_id"`
+	OpeningName   string             `bson:"opening_name" json:"opening_name"`
+	NodeID        primitive.ObjectID `bson:"node_id" json:"node_id"` // last prepared node reached, zero at the opening's start
+	Path          string             `bson:"path" json:"path"`
+	Ply           int                `bson:"ply" json:"ply"` // 1-based ply of the deviating move
+	FEN           string             `bson:"fen" json:"fen"` // position before it, without move counters
+	Move          string             `bson:"move" json:"move"`
+	DeviatedBy    string             `bson:"deviated_by" json:"deviated_by"` // "user" | "opponent"
+	PreparedMoves []string           `bson:"prepared_moves" json:"prepared_moves"`
+}
+
+type ImportGamesRequest struct {
+	PGN   string `json:"pgn" binding:"required"`
+	Color string `json:"color" binding:"omitempty,oneof=white black"` // side the user played, when the PGN names don't tell
+}
+
+type ImportGamesResponse struct {
+	Imported   []Game            `json:"imported"`
+	Duplicates int               `json:"duplicates"` // games that were already imported
+	Errors     []ImportGameError `json:"errors"`
+}
+
+type GameReport struct {
+	Games        int                `json:"games"`
+	Followed     int                `json:"followed"`
+	NoRepertoire int                `json:"no_repertoire"`
+	Openings     []OpeningDeviation `json:"openings"` // most games first
+}
+
+// OpeningDeviation aggregates the games that left one opening.
+type OpeningDeviation struct {
+	RepertoireID primitive.ObjectID `json:"repertoire_id"`
+	OpeningID    primitive.ObjectID `json:"opening_id"`
+	OpeningName  string             `json:"opening_name"`
+	Games        int                `json:"games"`
+	ByUser       int                `json:"by_user"`
+	ByOpponent   int                `json:"by_opponent"`
+	LineEnded    int                `json:"line_ended"`
+	Deviations   []DeviationCount   `json:"deviations"` // most frequent first
+}
+
+type DeviationCount struct {
+	FEN           string               `json:"fen"`
+	Path          string               `json:"path"`
+	Ply           int                  `json:"ply"`
+	Move          string               `json:"move"`
+	DeviatedBy    string               `json:"deviated_by"`
+	Status        string               `json:"status"` // deviated | line_ended
+	PreparedMoves []string             `json:"prepared_moves"`
+	Count         int                  `json:"count"`
+	GameIDs       []primitive.ObjectID `json:"game_ids"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// LoginAttempt counts the failed logins of one account or one client IP,
+// and locks it out once they pile up.
+type LoginAttempt struct {
+	Key           string     `bson:"_id" json:"key"`   // kind and value, e.g. "ip:203.0.113.7"
+	Kind          string     `bson:"kind" json:"kind"` // "account" | "ip" | "mail_account" | "mail_ip"
+	Failures      int        `bson:"failures" json:"failures"`
+	WindowStart   time.Time  `bson:"window_start" json:"window_start"` // failures count from here
+	Lockouts      int        `bson:"lockouts" json:"lockouts"`         // each one lasts twice the previous
+	LockedUntil   *time.Time `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	LastFailureAt time.Time  `bson:"last_failure_at" json:"last_failure_at"`
+	ExpiresAt     time.Time  `bson:"expires_at" json:"expires_at"` // forgotten from then on
+	Version       int64      `bson:"version" json:"-"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// PositionEntry records one place in a repertoire where a position occurs,
+// either at the start of an opening or after one of its moves. FEN is the
+// normalized position key (no move counters), so transpositions between
+// openings and repertoires share it.
+type PositionEntry struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID            primitive.ObjectID `bson:"user_id" json:"-"`
+	RepertoireID      primitive.ObjectID `bson:"repertoire_id" json:"repertoire_id"`
+	RepertoireVersion int64              `bson:"repertoire_version" json:"repertoire_version"` // version the entry was indexed from
+	RepertoireName    string             `bson:"repertoire_name" json:"repertoire_name"`
+	Color             string             `bson:"color" json:"color"` // repertoire color
+	OpeningID         primitive.ObjectID `bson:"opening_id" json:"opening_id"`
+	OpeningName       string             `bson:"opening_name" json:"opening_name"`
+	NodeID            primitive.ObjectID `bson:"node_id" json:"node_id"` // zero at the opening's start
+	Path              string             `bson:"path" json:"path"`
+	Line              []string           `bson:"line" json:"line"` // SAN moves from the opening start
+	FEN               string             `bson:"fen" json:"fen"`
+	UserToMove        bool               `bson:"user_to_move" json:"user_to_move"`
+	Reply             string             `bson:"reply,omitempty" json:"reply,omitempty"` // main-line continuation, in SAN
+	Replies           []string           `bson:"replies" json:"replies"`                 // every continuation, main line first
+}
+
+type PositionLookupResponse struct {
+	FEN         string          `json:"fen"`
+	Occurrences []PositionEntry `json:"occurrences"`
+}
@@ -6,6 +6,18 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Move categories for PracticeMove.Category. A move from the repertoire is
+// "repertoire"; any other move is judged from the engine evaluations sent
+// with it, or counts as a mistake without them.
+const (
+	MoveCategoryRepertoire = "repertoire"
+	MoveCategoryBest       = "best"
+	MoveCategoryGood       = "good"
+	MoveCategoryInaccuracy = "inaccuracy"
+	MoveCategoryMistake    = "mistake"
+	MoveCategoryBlunder    = "blunder"
+)
+
 type PracticeSession struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
@@ -15,21 +27,29 @@ type PracticeSession struct {
 	Color        string             `bson:"color" json:"color"` // "white" | "black"
 	StartedAt    time.Time          `bson:"started_at" json:"started_at"`
 	EndedAt      *time.Time         `bson:"ended_at,omitempty" json:"ended_at,omitempty"`
+	StartingFEN  string             `bson:"starting_fen" json:"starting_fen"`
+	CurrentFEN   string             `bson:"current_fen" json:"current_fen"` // position the user is to move in
+	Line         []string           `bson:"line" json:"line"`               // SAN of every move played so far, both sides
 	Moves        []PracticeMove     `bson:"moves" json:"moves"`
 	Stats        PracticeStats      `bson:"stats" json:"stats"`
 	Config       PracticeConfig     `bson:"config" json:"config"`
+	RootNodeID   primitive.ObjectID `bson:"root_node_id,omitempty" json:"root_node_id,omitempty"` // node the drill starts after, zero at the opening's start
+	RootPath     string             `bson:"root_path,omitempty" json:"root_path,omitempty"`
+	RootLine     []string           `bson:"root_line,omitempty" json:"root_line,omitempty"` // SAN from the opening's start to the root, not part of Line
 }
 
 type PracticeMove struct {
-	Ply          int    `bson:"ply" json:"ply"`
-	FENBefore    string `bson:"fen_before" json:"fen_before"`
-	FENAfter     string `bson:"fen_after" json:"fen_after"`
-	UserMove     string `bson:"user_move" json:"user_move"`
-	ExpectedMove string `bson:"expected_move,omitempty" json:"expected_move,omitempty"`
-	Category     string `bson:"category" json:"category"` // book, best, good, inaccuracy, mistake, blunder
-	EvalBefore   int    `bson:"eval_before" json:"eval_before"`
-	EvalAfter    int    `bson:"eval_after" json:"eval_after"`
-	CentipawnLoss int   `bson:"centipawn_loss" json:"centipawn_loss"`
+	Ply           int      `bson:"ply" json:"ply"`
+	FENBefore     string   `bson:"fen_before" json:"fen_before"`
+	FENAfter      string   `bson:"fen_after" json:"fen_after"`
+	UserMove      string   `bson:"user_move" json:"user_move"`
+	UserMoveUCI   string   `bson:"user_move_uci,omitempty" json:"user_move_uci,omitempty"`
+	ExpectedMove  string   `bson:"expected_move,omitempty" json:"expected_move,omitempty"`
+	Category      string   `bson:"category" json:"category"` // repertoire, best, good, inaccuracy, mistake, blunder
+	EvalBefore    int      `bson:"eval_before" json:"eval_before"`
+	EvalAfter     int      `bson:"eval_after" json:"eval_after"`
+	CentipawnLoss int      `bson:"centipawn_loss" json:"centipawn_loss"`
+	Accuracy      *float64 `bson:"accuracy,omitempty" json:"accuracy,omitempty"` // 0-100, unset on moves recorded before it was computed
 }
 
 type PracticeStats struct {
@@ -47,22 +67,41 @@ type PracticeConfig struct {
 	MaxMoves        int    `bson:"max_moves" json:"max_moves"`
 	Difficulty      string `bson:"difficulty" json:"difficulty"`
 	AllowVariations bool   `bson:"allow_variations" json:"allow_variations"`
+	OpponentPolicy  string `bson:"opponent_policy,omitempty" json:"opponent_policy,omitempty" binding:"omitempty,oneof=mainline uniform weighted"`
 }
 
 type StartPracticeRequest struct {
 	RepertoireID string          `json:"repertoire_id" binding:"required"`
 	OpeningID    string          `json:"opening_id"` // optional, empty = random
+	Node         string          `json:"node"`       // optional node ID or path in the opening to start after
+	FEN          string          `json:"fen"`        // optional position to start from, searched in the opening or the whole repertoire
 	Mode         string          `json:"mode" binding:"required,oneof=specific random"`
 	Config       *PracticeConfig `json:"config"` // optional, defaults applied server-side
 }
 
 type SubmitMoveRequest struct {
-	FENBefore     string `json:"fen_before" binding:"required"`
-	FENAfter      string `json:"fen_after" binding:"required"`
+	FENBefore     string `json:"fen_before"` // optional, must match the session position
+	FENAfter      string `json:"fen_after"`  // optional, computed from user_move
 	UserMove      string `json:"user_move" binding:"required"`
-	ExpectedMove  string `json:"expected_move"`
-	Category      string `json:"category" binding:"required"`
-	EvalBefore    int    `json:"eval_before"`
-	EvalAfter     int    `json:"eval_after"`
-	CentipawnLoss int    `json:"centipawn_loss"`
+	ExpectedMove  string `json:"expected_move"`  // ignored, the server knows the expected moves
+	Category      string `json:"category"`       // ignored, the server judges the move
+	EvalBefore    *int   `json:"eval_before"`    // ignored, the server judges by its engine's evals
+	EvalAfter     *int   `json:"eval_after"`     // ignored, the server judges by its engine's evals
+	CentipawnLoss int    `json:"centipawn_loss"` // ignored, computed from the evals
+}
+
+type OpponentMove struct {
+	Move string `json:"move"` // SAN notation
+	UCI  string `json:"uci"`
+	FEN  string `json:"fen"`
+}
+
+type SubmitMoveResponse struct {
+	Move            PracticeMove  `json:"move"`
+	Correct         bool          `json:"correct"`
+	ExpectedMoves   []string      `json:"expected_moves"`
+	OpponentMove    *OpponentMove `json:"opponent_move,omitempty"`
+	CurrentFEN      string        `json:"current_fen"`
+	LineComplete    bool          `json:"line_complete"`
+	SessionComplete bool          `json:"session_complete"`
 }
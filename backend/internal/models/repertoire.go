@@ -12,6 +12,7 @@ type Repertoire struct {
 	Name      string             `bson:"name" json:"name"`
 	Color     string             `bson:"color" json:"color"` // "white" | "black"
 	Openings  []Opening          `bson:"openings" json:"openings"`
+	Version   int64              `bson:"version" json:"version"` // incremented by every write, served as the ETag
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
 }
@@ -21,16 +22,19 @@ type Opening struct {
 	Name        string             `bson:"name" json:"name"`
 	ECO         string             `bson:"eco" json:"eco"`
 	StartingFEN string             `bson:"starting_fen" json:"starting_fen"`
+	Notes       string             `bson:"notes,omitempty" json:"notes,omitempty"` // PGN game comment
 	Moves       []MoveNode         `bson:"moves" json:"moves"`
 }
 
 type MoveNode struct {
-	FEN        string     `bson:"fen" json:"fen"`
-	Move       string     `bson:"move" json:"move"`           // SAN notation
-	UCI        string     `bson:"uci" json:"uci"`             // UCI notation
-	Comment    string     `bson:"comment,omitempty" json:"comment,omitempty"`
-	IsMainLine bool       `bson:"is_main_line" json:"is_main_line"`
-	Children   []MoveNode `bson:"children,omitempty" json:"children,omitempty"`
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FEN        string             `bson:"fen" json:"fen"`
+	Move       string             `bson:"move" json:"move"` // SAN notation
+	UCI        string             `bson:"uci" json:"uci"`   // UCI notation
+	Comment    string             `bson:"comment,omitempty" json:"comment,omitempty"`
+	NAGs       []int              `bson:"nags,omitempty" json:"nags,omitempty"` // PGN numeric annotation glyphs
+	IsMainLine bool               `bson:"is_main_line" json:"is_main_line"`
+	Children   []MoveNode         `bson:"children,omitempty" json:"children,omitempty"`
 }
 
 type CreateRepertoireRequest struct {
@@ -39,8 +43,55 @@ type CreateRepertoireRequest struct {
 }
 
 type AddOpeningRequest struct {
-	Name        string     `json:"name" binding:"required"`
+	Name        string     `json:"name"` // filled in from the ECO table when empty
 	ECO         string     `json:"eco"`
 	StartingFEN string     `json:"starting_fen"`
+	Notes       string     `json:"notes"`
 	Moves       []MoveNode `json:"moves"`
 }
+
+type ClassificationResponse struct {
+	ECO  string   `json:"eco"`
+	Name string   `json:"name"`
+	Line []string `json:"line"` // SAN moves of the matched ECO line
+	FEN  string   `json:"fen"`  // position the match was made on, without move counters
+	Ply  int      `json:"ply"`  // moves of the classified line played to reach it
+}
+
+type NodeResponse struct {
+	OpeningID primitive.ObjectID `json:"opening_id"`
+	Path      string             `json:"path"` // child index at each depth, e.g. "0.2.1"
+	Line      []string           `json:"line"` // SAN moves from the opening's start up to and including the node
+	FENBefore string             `json:"fen_before"`
+	Node      MoveNode           `json:"node"`
+}
+
+type AddNodeRequest struct {
+	Parent  string `json:"parent"`                  // node ID or path, empty for a first move
+	Move    string `json:"move" binding:"required"` // SAN or UCI
+	Comment string `json:"comment"`
+}
+
+type ReorderNodeRequest struct {
+	Index *int `json:"index" binding:"required,min=0"` // new position among the node's siblings
+}
+
+type UpdateCommentRequest struct {
+	Comment string `json:"comment"`
+}
+
+type ImportPGNRequest struct {
+	PGN string `json:"pgn" binding:"required"`
+}
+
+type ImportGameError struct {
+	Game    int    `json:"game"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+type ImportPGNResponse struct {
+	Openings []Opening         `json:"openings"`
+	Errors   []ImportGameError `json:"errors"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReviewCard schedules one position of a repertoire where it is the user's
+// turn to play a prepared move. FEN is the normalized position key (no move
+// counters), so a position reached through several openings shares one card.
+type ReviewCard struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID `bson:"user_id" json:"user_id"`
+	RepertoireID   primitive.ObjectID `bson:"repertoire_id" json:"repertoire_id"`
+	SyncedVersion  int64              `bson:"synced_version" json:"-"` // repertoire version last synced from
+	OpeningID      primitive.ObjectID `bson:"opening_id,omitempty" json:"opening_id,omitempty"`
+	FEN            string             `bson:"fen" json:"fen"`
+	Moves          []string           `bson:"moves" json:"moves"`                   // SAN line from the opening start
+	ExpectedMoves  []string           `bson:"expected_moves" json:"expected_moves"` // prepared replies
+	EaseFactor     float64            `bson:"ease_factor" json:"ease_factor"`
+	IntervalDays   int                `bson:"interval_days" json:"interval_days"`
+	Repetitions    int                `bson:"repetitions" json:"repetitions"`
+	Lapses         int                `bson:"lapses" json:"lapses"`
+	DueAt          time.Time          `bson:"due_at" json:"due_at"`
+	LastReviewedAt *time.Time         `bson:"last_reviewed_at,omitempty" json:"last_reviewed_at,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
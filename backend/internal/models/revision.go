@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Revision is a snapshot of a repertoire taken after one of its writes.
+type Revision struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RepertoireID primitive.ObjectID `bson:"repertoire_id" json:"repertoire_id"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Version      int64              `bson:"version" json:"version"`
+	Action       string             `bson:"action" json:"action"` // "create", "update", "add_opening", "restore", ...
+	Snapshot     RepertoireSnapshot `bson:"snapshot" json:"snapshot"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type RepertoireSnapshot struct {
+	Name     string    `bson:"name" json:"name"`
+	Color    string    `bson:"color" json:"color"`
+	Openings []Opening `bson:"openings,omitempty" json:"openings,omitempty"` // left out of revision lists
+}
+
+type RevisionDiff struct {
+	FromVersion int64         `json:"from_version"`
+	ToVersion   int64         `json:"to_version"`
+	Fields      []string      `json:"fields,omitempty"` // repertoire fields that changed: name, color
+	Openings    []OpeningDiff `json:"openings"`
+}
+
+type OpeningDiff struct {
+	OpeningID primitive.ObjectID `json:"opening_id"`
+	Name      string             `json:"name"`
+	Change    string             `json:"change"`           // "added" | "removed" | "changed"
+	Fields    []string           `json:"fields,omitempty"` // opening fields that changed: name, eco, starting_fen, notes
+	Nodes     []NodeDiff         `json:"nodes,omitempty"`
+}
+
+type NodeDiff struct {
+	NodeID        primitive.ObjectID `json:"node_id"`
+	Path          string             `json:"path"` // in the newer tree, or the older one for removed nodes
+	Line          []string           `json:"line"`
+	Change        string             `json:"change"`            // "added" | "removed" | "changed"
+	Fields        []string           `json:"fields,omitempty"`  // node fields that changed: comment, nags, is_main_line
+	Subtree       int                `json:"subtree,omitempty"` // nodes added or removed along with this one
+	CommentBefore string             `json:"comment_before,omitempty"`
+	CommentAfter  string             `json:"comment_after,omitempty"`
+}
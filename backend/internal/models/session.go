@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reasons a session was revoked, for Session.RevokedReason.
+const (
+	RevokedLogout         = "logout"
+	RevokedLogoutAll      = "logout_all"
+	RevokedReuse          = "reuse"
+	RevokedPasswordReset  = "password_reset"
+	RevokedPasswordChange = "password_change"
+)
+
+// Session is a refresh-token family: one sign-in on one device. Each refresh
+// replaces TokenID, so presenting an older token of the family means it was
+// copied, and the whole family is revoked.
+type Session struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"-"`
+	TokenID       string             `bson:"token_id" json:"-"` // jti of the only refresh token still valid
+	UserAgent     string             `bson:"user_agent" json:"user_agent"`
+	IP            string             `bson:"ip" json:"ip"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt    time.Time          `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt     time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt     *time.Time         `bson:"revoked_at,omitempty" json:"-"`
+	RevokedReason string             `bson:"revoked_reason,omitempty" json:"-"`
+}
+
+// SessionInfo is a session as listed to its user.
+type SessionInfo struct {
+	Session
+	Current bool `json:"current"` // the session of the calling access token
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
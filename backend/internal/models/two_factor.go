@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`           // base32, for typing into the app
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI, for a QR code
+}
+
+// RecoveryCodesResponse shows recovery codes the only time they are known.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorCodeRequest confirms an action with an authenticator or
+// recovery code.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"` // authenticator or recovery code
+}
+
+// TwoFactorChallengeResponse answers a correct password when the user has
+// two-factor on; the challenge token and a code then complete the login.
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // authenticator or recovery code
+}
@@ -7,13 +7,26 @@ import (
 )
 
 type User struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Email        string             `bson:"email" json:"email"`
-	PasswordHash string             `bson:"password_hash" json:"-"`
-	Username     string             `bson:"username" json:"username"`
-	Preferences  UserPreferences    `bson:"preferences" json:"preferences"`
-	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Email            string             `bson:"email" json:"email"`
+	EmailVerified    bool               `bson:"email_verified" json:"email_verified"`
+	PendingEmail     string             `bson:"pending_email,omitempty" json:"pending_email,omitempty"` // new address awaiting verification
+	TwoFactorEnabled bool               `bson:"two_factor_enabled" json:"two_factor_enabled"`
+	TOTP             *TOTPSettings      `bson:"totp,omitempty" json:"-"`
+	PasswordHash     string             `bson:"password_hash" json:"-"`
+	Username         string             `bson:"username" json:"username"`
+	Preferences      UserPreferences    `bson:"preferences" json:"preferences"`
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt        time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// TOTPSettings hold a user's authenticator secret, set up first and
+// enabled once a code from the app has been checked.
+type TOTPSettings struct {
+	Secret        string     `bson:"secret"`         // base32
+	LastStep      int64      `bson:"last_step"`      // time step of the last code accepted; codes work once
+	RecoveryCodes []string   `bson:"recovery_codes"` // SHA-256 hashes of the unused codes
+	EnabledAt     *time.Time `bson:"enabled_at,omitempty"`
 }
 
 type UserPreferences struct {
@@ -42,3 +55,31 @@ type AuthResponse struct {
 type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
+
+type UpdateUserRequest struct {
+	Username    *string                   `json:"username" binding:"omitempty,min=3,max=32"`
+	Preferences *UpdatePreferencesRequest `json:"preferences"`
+}
+
+// UpdatePreferencesRequest changes only the preferences it sets.
+type UpdatePreferencesRequest struct {
+	BoardTheme       *string `json:"board_theme" binding:"omitempty,min=1,max=32"`
+	PieceSet         *string `json:"piece_set" binding:"omitempty,min=1,max=32"`
+	BoardOrientation *string `json:"board_orientation" binding:"omitempty,oneof=white black"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code"` // authenticator or recovery code, with two-factor on
+}
+
+type DeleteUserRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code"` // authenticator or recovery code, with two-factor on
+}
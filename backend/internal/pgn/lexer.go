@@ -0,0 +1,185 @@
+package pgn
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokSymbol tokenKind = iota
+	tokString
+	tokComment
+	tokNAG
+	tokPeriod
+	tokTagOpen
+	tokTagClose
+	tokVariationOpen
+	tokVariationClose
+	tokResult
+	tokError
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	line   int
+	column int
+}
+
+// suffixNAGs maps move suffix annotations to their numeric annotation glyphs.
+var suffixNAGs = map[string]int{
+	"!":  1,
+	"?":  2,
+	"!!": 3,
+	"??": 4,
+	"!?": 5,
+	"?!": 6,
+}
+
+type lexer struct {
+	src    string
+	pos    int
+	line   int
+	column int
+}
+
+func isSymbolChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		strings.IndexByte("_+#=:-/", c) >= 0
+}
+
+func (l *lexer) advance() byte {
+	c := l.src[l.pos]
+	l.pos++
+	if c == '\n' {
+		l.line++
+		l.column = 1
+	} else if c&0xC0 != 0x80 {
+		// Count characters, not UTF-8 continuation bytes.
+		l.column++
+	}
+	return c
+}
+
+// tokenize splits PGN text into tokens. Lexical errors become tokError tokens
+// so the parser can report them against the game they occur in; an
+// unterminated comment or string ends the token stream.
+func tokenize(src string) []token {
+	l := &lexer{src: src, line: 1, column: 1}
+	var tokens []token
+
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		line, column := l.line, l.column
+		emit := func(kind tokenKind, text string) {
+			tokens = append(tokens, token{kind: kind, text: text, line: line, column: column})
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			l.advance()
+		case c == '%' && column == 1:
+			// Escape mechanism: the rest of the line is ignored.
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance()
+			}
+		case c == ';':
+			l.advance()
+			start := l.pos
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance()
+			}
+			emit(tokComment, strings.TrimSpace(l.src[start:l.pos]))
+		case c == '{':
+			l.advance()
+			start := l.pos
+			for l.pos < len(l.src) && l.src[l.pos] != '}' {
+				l.advance()
+			}
+			if l.pos >= len(l.src) {
+				emit(tokError, "unterminated comment")
+				return tokens
+			}
+			text := l.src[start:l.pos]
+			l.advance()
+			emit(tokComment, strings.Join(strings.Fields(text), " "))
+		case c == '"':
+			l.advance()
+			var b strings.Builder
+			for {
+				if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+					emit(tokError, "unterminated string")
+					return tokens
+				}
+				ch := l.advance()
+				if ch == '"' {
+					break
+				}
+				if ch == '\\' && l.pos < len(l.src) {
+					ch = l.advance()
+				}
+				b.WriteByte(ch)
+			}
+			emit(tokString, b.String())
+		case c == '$':
+			l.advance()
+			start := l.pos
+			for l.pos < len(l.src) && l.src[l.pos] >= '0' && l.src[l.pos] <= '9' {
+				l.advance()
+			}
+			if start == l.pos {
+				emit(tokError, "expected digits after $")
+				continue
+			}
+			emit(tokNAG, l.src[start:l.pos])
+		case c == '!' || c == '?':
+			start := l.pos
+			for l.pos < len(l.src) && (l.src[l.pos] == '!' || l.src[l.pos] == '?') {
+				l.advance()
+			}
+			suffix := l.src[start:l.pos]
+			nag, ok := suffixNAGs[suffix]
+			if !ok {
+				emit(tokError, fmt.Sprintf("unknown annotation %q", suffix))
+				continue
+			}
+			emit(tokNAG, fmt.Sprint(nag))
+		case c == '.':
+			l.advance()
+			emit(tokPeriod, ".")
+		case c == '[':
+			l.advance()
+			emit(tokTagOpen, "[")
+		case c == ']':
+			l.advance()
+			emit(tokTagClose, "]")
+		case c == '(':
+			l.advance()
+			emit(tokVariationOpen, "(")
+		case c == ')':
+			l.advance()
+			emit(tokVariationClose, ")")
+		case c == '*':
+			l.advance()
+			emit(tokResult, "*")
+		case isSymbolChar(c):
+			start := l.pos
+			for l.pos < len(l.src) && isSymbolChar(l.src[l.pos]) {
+				l.advance()
+			}
+			text := l.src[start:l.pos]
+			switch text {
+			case "1-0", "0-1", "1/2-1/2":
+				emit(tokResult, text)
+			default:
+				emit(tokSymbol, text)
+			}
+		default:
+			l.advance()
+			emit(tokError, fmt.Sprintf("unexpected character %q", c))
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,303 @@
+package pgn
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// Node is one move of a game. Children[0] is the main continuation and any
+// further children are variations (RAVs) branching off after this move.
+type Node struct {
+	SAN           string
+	CommentBefore string
+	Comment       string
+	NAGs          []int
+	Children      []*Node
+	Line          int
+	Column        int
+
+	parent *Node
+}
+
+type Game struct {
+	Number  int // 1-based position in the parsed input
+	Tags    []Tag
+	Comment string
+	Moves   []*Node
+	Result  string
+	Line    int
+	Column  int
+}
+
+// TagValue returns the value of the named tag, or "" if it is absent.
+func (g *Game) TagValue(name string) string {
+	for _, t := range g.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// Error is a syntax error within one game of a PGN file. Game is the
+// 1-based index of the game in the input.
+type Error struct {
+	Game    int    `json:"game"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("game %d, line %d, column %d: %s", e.Game, e.Line, e.Column, e.Message)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse reads every game in a PGN text. A malformed game does not stop the
+// import: it is reported as an Error and parsing resumes with the next game.
+// The returned games and errors are each in input order.
+func Parse(text string) ([]*Game, []*Error) {
+	var games []*Game
+	var errs []*Error
+
+	number := 0
+	for _, tokens := range splitGames(tokenize(strings.TrimPrefix(text, "\ufeff"))) {
+		p := &parser{tokens: tokens}
+		game, err := p.parseGame()
+		if err == nil && len(game.Tags) == 0 && len(game.Moves) == 0 {
+			// Stray comments or a lone result between games.
+			continue
+		}
+		number++
+		if err != nil {
+			err.Game = number
+			errs = append(errs, err)
+			continue
+		}
+		game.Number = number
+		games = append(games, game)
+	}
+	return games, errs
+}
+
+// splitGames cuts the token stream at game boundaries: after a result
+// outside any variation, or where a tag section follows movetext.
+func splitGames(tokens []token) [][]token {
+	var games [][]token
+	start, depth := 0, 0
+	inTag, sawMovetext := false, false
+
+	for i, t := range tokens {
+		switch {
+		case t.kind == tokTagOpen && !inTag:
+			if sawMovetext {
+				games = append(games, tokens[start:i])
+				start = i
+				depth = 0
+				sawMovetext = false
+			}
+			inTag = true
+		case t.kind == tokTagClose:
+			inTag = false
+		case inTag:
+		case t.kind == tokResult && depth == 0:
+			games = append(games, tokens[start:i+1])
+			start = i + 1
+			sawMovetext = false
+		case t.kind == tokVariationOpen:
+			depth++
+			sawMovetext = true
+		case t.kind == tokVariationClose:
+			// An unmatched ) is the parser's to report
+			if depth > 0 {
+				depth--
+			}
+			sawMovetext = true
+		default:
+			sawMovetext = true
+		}
+	}
+	if start < len(tokens) {
+		games = append(games, tokens[start:])
+	}
+	return games
+}
+
+func (p *parser) peek() *token {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *parser) next() *token {
+	t := p.peek()
+	if t != nil {
+		p.pos++
+	}
+	return t
+}
+
+func errorAt(t *token, format string, args ...any) *Error {
+	return &Error{Line: t.line, Column: t.column, Message: fmt.Sprintf(format, args...)}
+}
+
+// expected reports that got is not the wanted token. A nil got means the
+// game ended right after prev.
+func expected(got, prev *token, want string) *Error {
+	switch {
+	case got == nil:
+		return errorAt(prev, "expected %s after %q, found end of game", want, prev.text)
+	case got.kind == tokError:
+		return errorAt(got, "%s", got.text)
+	}
+	return errorAt(got, "expected %s, found %q", want, got.text)
+}
+
+func (p *parser) parseGame() (*Game, *Error) {
+	first := p.peek()
+	game := &Game{Line: first.line, Column: first.column}
+
+	for t := p.peek(); t != nil && t.kind == tokTagOpen; t = p.peek() {
+		tag, err := p.parseTag()
+		if err != nil {
+			return nil, err
+		}
+		game.Tags = append(game.Tags, tag)
+	}
+
+	if err := p.parseMovetext(game); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+func (p *parser) parseTag() (Tag, *Error) {
+	open := p.next()
+	name := p.next()
+	if name == nil || name.kind != tokSymbol {
+		return Tag{}, expected(name, open, "tag name")
+	}
+	value := p.next()
+	if value == nil || value.kind != tokString {
+		return Tag{}, expected(value, name, "quoted tag value")
+	}
+	if t := p.next(); t == nil || t.kind != tokTagClose {
+		return Tag{}, expected(t, value, "]")
+	}
+	return Tag{Name: name.text, Value: value.text}, nil
+}
+
+func (p *parser) parseMovetext(game *Game) *Error {
+	root := &Node{}
+	cur := root
+	lineStart := true
+	pendingComment := ""
+	var stack []*Node
+
+	for t := p.next(); t != nil; t = p.next() {
+		switch t.kind {
+		case tokError:
+			return errorAt(t, "%s", t.text)
+		case tokResult:
+			if len(stack) > 0 {
+				return errorAt(t, "result %s inside a variation", t.text)
+			}
+			game.Result = t.text
+		case tokPeriod:
+		case tokComment:
+			switch {
+			case !lineStart:
+				cur.Comment = joinComment(cur.Comment, t.text)
+			case cur == root && len(stack) == 0 && root.Children == nil:
+				game.Comment = joinComment(game.Comment, t.text)
+			default:
+				pendingComment = joinComment(pendingComment, t.text)
+			}
+		case tokNAG:
+			if lineStart {
+				return errorAt(t, "annotation before any move")
+			}
+			nag, err := strconv.Atoi(t.text)
+			if err != nil || nag > 255 {
+				return errorAt(t, "invalid annotation $%s", t.text)
+			}
+			cur.NAGs = append(cur.NAGs, nag)
+		case tokVariationOpen:
+			if cur == root || lineStart {
+				return errorAt(t, "variation without a preceding move")
+			}
+			stack = append(stack, cur)
+			cur = cur.parent
+			lineStart = true
+		case tokVariationClose:
+			if len(stack) == 0 {
+				return errorAt(t, "unmatched )")
+			}
+			if lineStart {
+				return errorAt(t, "empty variation")
+			}
+			cur = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			lineStart = false
+			pendingComment = ""
+		case tokSymbol:
+			if isMoveNumber(t.text) {
+				continue
+			}
+			node := &Node{SAN: t.text, CommentBefore: pendingComment, Line: t.line, Column: t.column, parent: cur}
+			cur.Children = append(cur.Children, node)
+			cur = node
+			lineStart = false
+			pendingComment = ""
+		case tokTagOpen:
+			return errorAt(t, "tag pair after movetext")
+		default:
+			return errorAt(t, "unexpected %q in movetext", t.text)
+		}
+	}
+
+	if len(stack) > 0 {
+		return errorAt(&p.tokens[len(p.tokens)-1], "unterminated variation")
+	}
+	game.Moves = detach(root.Children)
+	return nil
+}
+
+// detach clears the parent pointers of top-level nodes so they no longer
+// reference the parser's synthetic root.
+func detach(nodes []*Node) []*Node {
+	for _, n := range nodes {
+		n.parent = nil
+	}
+	return nodes
+}
+
+func isMoveNumber(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func joinComment(existing, text string) string {
+	if existing == "" {
+		return text
+	}
+	if text == "" {
+		return existing
+	}
+	return existing + " " + text
+}
@@ -0,0 +1,227 @@
+package pgn
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// render writes a move tree back out compactly: comments in braces, NAGs
+// as $n and each variation in parentheses after the move it replaces.
+func render(nodes []*Node) string {
+	var parts []string
+	for len(nodes) > 0 {
+		parts = append(parts, renderMove(nodes[0]))
+		for _, variation := range nodes[1:] {
+			parts = append(parts, "("+render([]*Node{variation})+")")
+		}
+		nodes = nodes[0].Children
+	}
+	return strings.Join(parts, " ")
+}
+
+func renderMove(n *Node) string {
+	s := n.SAN
+	if n.CommentBefore != "" {
+		s = "{" + n.CommentBefore + "} " + s
+	}
+	for _, nag := range n.NAGs {
+		s += fmt.Sprintf(" $%d", nag)
+	}
+	if n.Comment != "" {
+		s += " {" + n.Comment + "}"
+	}
+	return s
+}
+
+func TestParseMovetext(t *testing.T) {
+	tests := []struct {
+		name    string
+		pgn     string
+		moves   string
+		comment string
+		result  string
+	}{
+		{"main line", "1. e4 e5 2. Nf3 Nc6 *", "e4 e5 Nf3 Nc6", "", "*"},
+		{"variation", "1. e4 e5 (1... c5) 2. Nf3 *", "e4 e5 (c5) Nf3", "", "*"},
+		{"nested variations", "1. e4 e5 (1... c5 2. Nf3 (2. Nc3) d6) 2. Nf3 1-0", "e4 e5 (c5 Nf3 (Nc3) d6) Nf3", "", "1-0"},
+		{"sibling variations", "1. e4 (1. d4) (1. c4 e5) 1... e5 0-1", "e4 (d4) (c4 e5) e5", "", "0-1"},
+		{"variation of the first move", "1. e4 (1. d4 d5) e5 1/2-1/2", "e4 (d4 d5) e5", "", "1/2-1/2"},
+		{"game comment", "{Intro} 1. e4 *", "e4", "Intro", "*"},
+		{"comments", "1. e4 {best by test} e5 (1... {Sicilian:} c5 {sharp}) {after the variation} 2. Nf3 *",
+			"e4 {best by test} e5 {after the variation} ({Sicilian:} c5 {sharp}) Nf3", "", "*"},
+		{"line comment", "1. e4 ; the king's pawn\ne5 *", "e4 {the king's pawn} e5", "", "*"},
+		{"suffix annotations", "1. e4! e5? 2. Nf3!! Nc6?? 3. Bb5!? a6?! *", "e4 $1 e5 $2 Nf3 $3 Nc6 $4 Bb5 $5 a6 $6", "", "*"},
+		{"numeric annotations", "1. e4 $1 $14 e5 $6 *", "e4 $1 $14 e5 $6", "", "*"},
+		{"no result", "1. e4 e5", "e4 e5", "", ""},
+		{"escaped line", "% exported by a tool\n1. e4 *", "e4", "", "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games, errs := Parse(tt.pgn)
+			if len(errs) > 0 {
+				t.Fatal(errs[0])
+			}
+			if len(games) != 1 {
+				t.Fatalf("got %d games, want 1", len(games))
+			}
+			g := games[0]
+			if got := render(g.Moves); got != tt.moves {
+				t.Errorf("moves = %s, want %s", got, tt.moves)
+			}
+			if g.Comment != tt.comment {
+				t.Errorf("comment = %q, want %q", g.Comment, tt.comment)
+			}
+			if g.Result != tt.result {
+				t.Errorf("result = %q, want %q", g.Result, tt.result)
+			}
+		})
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	const text = `[Event "Training"]
+[SetUp "1"]
+[FEN "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"]
+[Annotator "A \"quoted\" name"]
+
+1... c5 *`
+	games, errs := Parse(text)
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+	g := games[0]
+	tags := map[string]string{
+		"Event":     "Training",
+		"SetUp":     "1",
+		"FEN":       "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1",
+		"Annotator": `A "quoted" name`,
+		"Round":     "",
+	}
+	for name, want := range tags {
+		if got := g.TagValue(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if got := render(g.Moves); got != "c5" {
+		t.Errorf("moves = %s, want c5", got)
+	}
+
+	games, errs = Parse("[SetUp \"0\"]\n[FEN \"8/8/8/8/8/8/8/8 w - - 0 1\"]\n\n1. e4 *")
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+	if g := games[0]; g.TagValue("SetUp") != "0" || g.TagValue("FEN") == "" {
+		t.Errorf("tags = %v, want SetUp 0 with the FEN kept", g.Tags)
+	}
+}
+
+func TestParseMultipleGames(t *testing.T) {
+	const text = `[Event "One"]
+
+1. e4 e5 1-0
+
+[Event "Two"]
+
+1. d4 d5 *
+
+1. c4 e5 0-1
+[Event "Four"]
+1. Nf3`
+	games, errs := Parse(text)
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+	want := []struct {
+		event, moves, result string
+		line                 int
+	}{
+		{"One", "e4 e5", "1-0", 1},
+		{"Two", "d4 d5", "*", 5},
+		{"", "c4 e5", "0-1", 9},
+		{"Four", "Nf3", "", 10},
+	}
+	if len(games) != len(want) {
+		t.Fatalf("got %d games, want %d", len(games), len(want))
+	}
+	for i, g := range games {
+		w := want[i]
+		if g.Number != i+1 || g.TagValue("Event") != w.event || render(g.Moves) != w.moves || g.Result != w.result || g.Line != w.line {
+			t.Errorf("game %d: number %d, event %q, moves %s, result %q at line %d; want %+v",
+				i+1, g.Number, g.TagValue("Event"), render(g.Moves), g.Result, g.Line, w)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		pgn     string
+		line    int
+		column  int
+		message string
+	}{
+		{"unmatched close", "1. e4 ) e5 *", 1, 7, "unmatched )"},
+		{"variation before a move", "( 1. d4 ) 1. e4 *", 1, 1, "variation without a preceding move"},
+		{"empty variation", "1. e4 ( ) e5 *", 1, 9, "empty variation"},
+		{"unterminated variation", "1. e4 e5 (1... c5\n2. Nf3", 2, 4, "unterminated variation"},
+		{"result in a variation", "1. e4 (1. d4 1-0) *", 1, 14, "result 1-0 inside a variation"},
+		{"annotation first", "$1 1. e4 *", 1, 1, "annotation before any move"},
+		{"annotation out of range", "1. e4 $256 *", 1, 7, "invalid annotation $256"},
+		{"unknown suffix", "1. e4 ?!? *", 1, 7, `unknown annotation "?!?"`},
+		{"unterminated comment", "1. e4 {never closed", 1, 7, "unterminated comment"},
+		{"tag without value", "[Event]\n1. e4 *", 1, 7, `expected quoted tag value, found "]"`},
+		{"unterminated tag", "[Event \"x\"", 1, 8, `expected ] after "x", found end of game`},
+		{"unexpected character", "1. e4 & e5 *", 1, 7, `unexpected character '&'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games, errs := Parse(tt.pgn)
+			if len(games) != 0 || len(errs) != 1 {
+				t.Fatalf("got %d games and %d errors, want one error", len(games), len(errs))
+			}
+			err := errs[0]
+			if err.Game != 1 || err.Line != tt.line || err.Column != tt.column || err.Message != tt.message {
+				t.Errorf("got %v, want line %d, column %d: %s", err, tt.line, tt.column, tt.message)
+			}
+		})
+	}
+}
+
+func TestParseSkipsMalformedGame(t *testing.T) {
+	const text = `[Event "Good"]
+
+1. e4 e5 *
+
+[Event "Bad"]
+
+1. d4 d5
+2. c4 ) e6 *
+
+[Event "Also good"]
+
+1. c4 *`
+	games, errs := Parse(text)
+	if len(errs) != 1 {
+		t.Fatalf("got %d errors, want 1", len(errs))
+	}
+	if err := errs[0]; err.Game != 2 || err.Line != 8 || err.Column != 7 {
+		t.Errorf("got %v, want game 2, line 8, column 7", err)
+	}
+	if len(games) != 2 {
+		t.Fatalf("got %d games, want the 2 good ones", len(games))
+	}
+	if games[0].Number != 1 || games[1].Number != 3 || games[1].TagValue("Event") != "Also good" {
+		t.Errorf("imported games %d and %d (%q), want 1 and 3", games[0].Number, games[1].Number, games[1].TagValue("Event"))
+	}
+}
+
+func TestParseResultEndsGameOnlyOutsideVariations(t *testing.T) {
+	games, errs := Parse("1. e4 (1. d4 *) e5 *\n1. e4 ) *\n1. c4 *")
+	if len(errs) != 2 || errs[0].Game != 1 || errs[1].Game != 2 {
+		t.Fatalf("got errors %v, want one for each of the first two games", errs)
+	}
+	if len(games) != 1 || games[0].Number != 3 || render(games[0].Moves) != "c4" {
+		t.Errorf("got %d games, want only the third", len(games))
+	}
+}
@@ -0,0 +1,166 @@
+package pgn
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+)
+
+const maxLineLength = 79
+
+// sevenTagRoster lists the tags every exported game carries, in the order
+// the PGN standard requires them.
+var sevenTagRoster = []Tag{
+	{Name: "Event", Value: "?"},
+	{Name: "Site", Value: "?"},
+	{Name: "Date", Value: "????.??.??"},
+	{Name: "Round", Value: "?"},
+	{Name: "White", Value: "?"},
+	{Name: "Black", Value: "?"},
+	{Name: "Result", Value: "*"},
+}
+
+// String renders the game in PGN export format: the seven tag roster first,
+// then the remaining tags, then the movetext wrapped at 79 characters.
+func (g *Game) String() string {
+	var b strings.Builder
+
+	result := g.Result
+	if result == "" {
+		result = "*"
+	}
+
+	for _, tag := range sevenTagRoster {
+		value := g.TagValue(tag.Name)
+		if tag.Name == "Result" {
+			value = result
+		}
+		if value == "" {
+			value = tag.Value
+		}
+		writeTag(&b, tag.Name, value)
+	}
+	for _, tag := range g.Tags {
+		if !isRosterTag(tag.Name) {
+			writeTag(&b, tag.Name, tag.Value)
+		}
+	}
+	b.WriteByte('\n')
+
+	var tokens []string
+	if g.Comment != "" {
+		tokens = append(tokens, formatComment(g.Comment))
+	}
+
+	ply := 0
+	if fen := g.TagValue("FEN"); fen != "" {
+		if pos, err := chess.ParseFEN(fen); err == nil {
+			ply = (pos.FullmoveNumber - 1) * 2
+			if pos.Turn == chess.Black {
+				ply++
+			}
+		}
+	}
+	tokens = appendLine(tokens, g.Moves, ply, true)
+	tokens = append(tokens, result)
+
+	b.WriteString(wrap(tokens))
+	b.WriteString("\n\n")
+	return b.String()
+}
+
+func isRosterTag(name string) bool {
+	for _, tag := range sevenTagRoster {
+		if tag.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func writeTag(b *strings.Builder, name, value string) {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	fmt.Fprintf(b, "[%s \"%s\"]\n", name, value)
+}
+
+// appendLine writes the first of the alternatives nodes, followed by the
+// other alternatives as parenthesised variations, then continues with the
+// main continuation. ply counts half-moves from the initial position.
+func appendLine(tokens []string, nodes []*Node, ply int, needNumber bool) []string {
+	for len(nodes) > 0 {
+		main := nodes[0]
+		tokens = appendMove(tokens, main, ply, needNumber)
+
+		for _, variation := range nodes[1:] {
+			tokens = append(tokens, "(")
+			tokens = appendMove(tokens, variation, ply, true)
+			tokens = appendLine(tokens, variation.Children, ply+1, variation.Comment != "")
+			tokens = append(tokens, ")")
+		}
+
+		needNumber = len(nodes) > 1 || main.Comment != ""
+		nodes = main.Children
+		ply++
+	}
+	return tokens
+}
+
+func appendMove(tokens []string, node *Node, ply int, needNumber bool) []string {
+	if node.CommentBefore != "" {
+		tokens = append(tokens, formatComment(node.CommentBefore))
+		needNumber = true
+	}
+
+	moveNumber := ply/2 + 1
+	switch {
+	case ply%2 == 0:
+		tokens = append(tokens, fmt.Sprintf("%d.", moveNumber))
+	case needNumber:
+		tokens = append(tokens, fmt.Sprintf("%d...", moveNumber))
+	}
+
+	tokens = append(tokens, node.SAN)
+	for _, nag := range node.NAGs {
+		tokens = append(tokens, fmt.Sprintf("$%d", nag))
+	}
+	if node.Comment != "" {
+		tokens = append(tokens, formatComment(node.Comment))
+	}
+	return tokens
+}
+
+// formatComment wraps text in braces. A closing brace would end the comment
+// early, so it is dropped.
+func formatComment(text string) string {
+	return "{" + strings.ReplaceAll(text, "}", "") + "}"
+}
+
+// wrap joins tokens with spaces, breaking lines before they exceed
+// maxLineLength. Long comments are broken between words; a closing
+// parenthesis always stays on the line of the move it follows.
+func wrap(tokens []string) string {
+	var b strings.Builder
+	lineLength := 0
+	last := ""
+	for _, token := range tokens {
+		for _, word := range strings.Fields(token) {
+			// No space inside the parentheses of a variation.
+			sep := " "
+			if lineLength == 0 || word == ")" || last == "(" {
+				sep = ""
+			}
+			if lineLength > 0 && word != ")" && lineLength+len(sep)+len(word) > maxLineLength {
+				b.WriteByte('\n')
+				lineLength = 0
+				sep = ""
+			}
+			b.WriteString(sep)
+			b.WriteString(word)
+			lineLength += len(sep) + len(word)
+			last = word
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AnnotationJobLease is how long a job stays its runner's without being
+// renewed.
+const AnnotationJobLease = 2 * time.Minute
+
+var activeJobStatuses = []string{models.JobQueued, models.JobRunning}
+
+type AnnotationJobRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAnnotationJobRepository() *AnnotationJobRepository {
+	return &AnnotationJobRepository{
+		collection: database.GetCollection("annotation_jobs"),
+	}
+}
+
+// Create queues the job, leased to job.Owner.
+func (r *AnnotationJobRepository) Create(ctx context.Context, job *models.AnnotationJob) error {
+	job.ID = primitive.NewObjectID()
+	job.Status = models.JobQueued
+	job.CreatedAt = time.Now()
+	job.LeaseUntil = job.CreatedAt.Add(AnnotationJobLease)
+	if job.Annotations == nil {
+		job.Annotations = []models.NodeAnnotation{}
+	}
+
+	_, err := r.collection.InsertOne(ctx, job)
+	return err
+}
+
+func (r *AnnotationJobRepository) FindByIDAndUserID(ctx context.Context, id, userID primitive.ObjectID) (*models.AnnotationJob, error) {
+	return r.findOne(ctx, bson.M{"_id": id, "user_id": userID}, nil)
+}
+
+// FindActive returns the queued or running job of the repertoire, if any.
+// A job whose lease ran out no longer counts.
+func (r *AnnotationJobRepository) FindActive(ctx context.Context, repertoireID primitive.ObjectID) (*models.AnnotationJob, error) {
+	return r.findOne(ctx, bson.M{
+		"repertoire_id": repertoireID,
+		"status":        bson.M{"$in": activeJobStatuses},
+		"lease_until":   bson.M{"$gte": time.Now()},
+	}, nil)
+}
+
+// FindLatest returns the most recently started job of the repertoire.
+func (r *AnnotationJobRepository) FindLatest(ctx context.Context, repertoireID, userID primitive.ObjectID) (*models.AnnotationJob, error) {
+	return r.findOne(ctx, bson.M{"repertoire_id": repertoireID, "user_id": userID},
+		options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+}
+
+func (r *AnnotationJobRepository) findOne(ctx context.Context, filter bson.M, opts *options.FindOneOptions) (*models.AnnotationJob, error) {
+	var job models.AnnotationJob
+	err := r.collection.FindOne(ctx, filter, opts).Decode(&job)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// owned matches the job while owner is still running it.
+func owned(id primitive.ObjectID, owner string) bson.M {
+	return bson.M{"_id": id, "owner": owner, "status": bson.M{"$in": activeJobStatuses}}
+}
+
+func (r *AnnotationJobRepository) Start(ctx context.Context, id primitive.ObjectID, owner string, positions int) error {
+	now := time.Now()
+	_, err := r.collection.UpdateOne(ctx, owned(id, owner), bson.M{
+		"$set": bson.M{"status": models.JobRunning, "started_at": now, "positions": positions},
+	})
+	return err
+}
+
+// Renew extends owner's lease on the job. It returns false when the job is
+// no longer owner's to run, because it was canceled or given up on.
+func (r *AnnotationJobRepository) Renew(ctx context.Context, id primitive.ObjectID, owner string) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx, owned(id, owner), bson.M{
+		"$set": bson.M{"lease_until": time.Now().Add(AnnotationJobLease)},
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *AnnotationJobRepository) UpdateProgress(ctx context.Context, id primitive.ObjectID, owner string, evaluated, fromStore int) error {
+	_, err := r.collection.UpdateOne(ctx, owned(id, owner), bson.M{
+		"$set": bson.M{"evaluated": evaluated, "from_store": fromStore},
+	})
+	return err
+}
+
+// Finish records the outcome of the job, unless it is no longer its
+// owner's, so a cancellation is not overwritten by the runner finishing.
+func (r *AnnotationJobRepository) Finish(ctx context.Context, job *models.AnnotationJob) error {
+	now := time.Now()
+	job.FinishedAt = &now
+	_, err := r.collection.UpdateOne(ctx, owned(job.ID, job.Owner), bson.M{
+		"$set": bson.M{
+			"status":      job.Status,
+			"evaluated":   job.Evaluated,
+			"from_store":  job.FromStore,
+			"annotations": job.Annotations,
+			"dubious":     job.Dubious,
+			"error":       job.Error,
+			"finished_at": now,
+		},
+	})
+	return err
+}
+
+// Cancel marks a queued or running job canceled. Its runner notices when
+// it next renews the lease. It returns false when the job had already
+// ended.
+func (r *AnnotationJobRepository) Cancel(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": bson.M{"$in": activeJobStatuses}},
+		bson.M{"$set": bson.M{"status": models.JobCanceled, "finished_at": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// FailInterrupted marks as failed the unfinished jobs whose lease ran out,
+// left behind by a server instance that stopped. It returns how many there
+// were.
+func (r *AnnotationJobRepository) FailInterrupted(ctx context.Context) (int64, error) {
+	now := time.Now()
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{
+			"status": bson.M{"$in": activeJobStatuses},
+			// Jobs from before leases have none
+			"$or": bson.A{
+				bson.M{"lease_until": bson.M{"$lt": now}},
+				bson.M{"lease_until": bson.M{"$exists": false}},
+			},
+		},
+		bson.M{"$set": bson.M{"status": models.JobFailed, "error": "interrupted: the server running it stopped", "finished_at": now}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *AnnotationJobRepository) DeleteByRepertoire(ctx context.Context, repertoireID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"repertoire_id": repertoireID})
+	return err
+}
+
+func (r *AnnotationJobRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+func (r *AnnotationJobRepository) CreateIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "repertoire_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}}},
+	}, options.CreateIndexes())
+	return err
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type EvalRepository struct {
+	collection *mongo.Collection
+}
+
+func NewEvalRepository() *EvalRepository {
+	return &EvalRepository{
+		collection: database.GetCollection("evals"),
+	}
+}
+
+// FindByFENs returns the stored evals of the positions at least minDepth
+// deep, and marks them as read for eviction.
+func (r *EvalRepository) FindByFENs(ctx context.Context, fens []string, minDepth int) ([]models.PositionEval, error) {
+	filter := bson.M{"_id": bson.M{"$in": fens}}
+	if minDepth > 0 {
+		filter["depth"] = bson.M{"$gte": minDepth}
+	}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var evals []models.PositionEval
+	if err := cursor.All(ctx, &evals); err != nil {
+		return nil, err
+	}
+
+	if len(evals) > 0 {
+		found := make([]string, len(evals))
+		for i := range evals {
+			found[i] = evals[i].FEN
+		}
+		_, err = r.collection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": found}}, bson.M{"$set": bson.M{"accessed_at": time.Now()}})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if evals == nil {
+		evals = []models.PositionEval{}
+	}
+	return evals, nil
+}
+
+// SaveDeepest stores each eval unless the position already has one at least
+// as deep from the same or a more trusted source: an engine eval replaces
+// any client eval, and a client eval never replaces an engine one. Evals
+// must have distinct FENs. It returns how many were stored.
+func (r *EvalRepository) SaveDeepest(ctx context.Context, evals []models.PositionEval) (int, error) {
+	if len(evals) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(evals))
+	for _, eval := range evals {
+		// A stored eval that wins makes the filter miss, and the upsert
+		// then fails on the duplicate _id instead of overwriting it
+		filter := bson.M{"_id": eval.FEN, "depth": bson.M{"$lt": eval.Depth}}
+		if eval.Source == models.EvalSourceEngine {
+			delete(filter, "depth")
+			filter["$or"] = bson.A{
+				bson.M{"depth": bson.M{"$lt": eval.Depth}},
+				bson.M{"source": bson.M{"$ne": models.EvalSourceEngine}},
+			}
+		} else {
+			filter["source"] = bson.M{"$ne": models.EvalSourceEngine}
+		}
+
+		set := bson.M{
+			"depth":       eval.Depth,
+			"score_type":  eval.ScoreType,
+			"score":       eval.Score,
+			"pv":          eval.PV,
+			"source":      eval.Source,
+			"updated_at":  now,
+			"accessed_at": now,
+		}
+		update := bson.M{"$set": set}
+		if eval.ContributedBy.IsZero() {
+			update["$unset"] = bson.M{"contributed_by": ""}
+		} else {
+			set["contributed_by"] = eval.ContributedBy
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(update).
+			SetUpsert(true))
+	}
+
+	result, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		var bulkErr mongo.BulkWriteException
+		if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+			return 0, err
+		}
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeErr) {
+				return 0, err
+			}
+		}
+	}
+	if result == nil {
+		return 0, nil
+	}
+	return int(result.UpsertedCount + result.ModifiedCount), nil
+}
+
+// Prune evicts the least recently read evals beyond maxEntries and returns
+// how many it removed.
+func (r *EvalRepository) Prune(ctx context.Context, maxEntries int64) (int64, error) {
+	count, err := r.collection.EstimatedDocumentCount(ctx)
+	if err != nil || count <= maxEntries {
+		return 0, err
+	}
+
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().
+		SetSort(bson.D{{Key: "accessed_at", Value: 1}}).
+		SetLimit(count-maxEntries).
+		SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var stale []struct {
+		FEN string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &stale); err != nil {
+		return 0, err
+	}
+	fens := make([]string, len(stale))
+	for i := range stale {
+		fens[i] = stale[i].FEN
+	}
+
+	result, err := r.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": fens}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (r *EvalRepository) CreateIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "accessed_at", Value: 1}}},
+	}, options.CreateIndexes())
+	return err
+}
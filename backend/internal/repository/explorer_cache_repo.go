@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ExplorerCacheRepository struct {
+	collection *mongo.Collection
+}
+
+func NewExplorerCacheRepository() *ExplorerCacheRepository {
+	return &ExplorerCacheRepository{
+		collection: database.GetCollection("explorer_cache"),
+	}
+}
+
+// Get returns the cached reply for key, or nil if there is none or it has
+// expired. Mongo only sweeps expired entries once a minute, so expiry is
+// checked here as well.
+func (r *ExplorerCacheRepository) Get(ctx context.Context, key string) (*models.ExplorerResponse, error) {
+	var entry models.ExplorerCacheEntry
+	err := r.collection.FindOne(ctx, bson.M{"_id": key, "expires_at": bson.M{"$gt": time.Now()}}).Decode(&entry)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry.Response, nil
+}
+
+func (r *ExplorerCacheRepository) Set(ctx context.Context, key string, response *models.ExplorerResponse, ttl time.Duration) error {
+	now := time.Now()
+	entry := models.ExplorerCacheEntry{
+		Key:       key,
+		Response:  *response,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": key}, entry, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *ExplorerCacheRepository) CreateIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"expires_at": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}, options.CreateIndexes())
+	return err
+}
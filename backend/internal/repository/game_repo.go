@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type GameRepository struct {
+	collection *mongo.Collection
+}
+
+func NewGameRepository() *GameRepository {
+	return &GameRepository{
+		collection: database.GetCollection("games"),
+	}
+}
+
+// Import stores the games the user has not imported before, assigning them
+// IDs in place. It returns the stored games and how many were skipped as
+// duplicates.
+func (r *GameRepository) Import(ctx context.Context, games []models.Game) ([]models.Game, int, error) {
+	if len(games) == 0 {
+		return []models.Game{}, 0, nil
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(games))
+	for i := range games {
+		games[i].ID = primitive.NewObjectID()
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"user_id": games[i].UserID, "fingerprint": games[i].Fingerprint}).
+			SetUpdate(bson.M{"$setOnInsert": games[i]}).
+			SetUpsert(true))
+	}
+
+	result, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	imported := make([]models.Game, 0, len(result.UpsertedIDs))
+	for i := range games {
+		if _, ok := result.UpsertedIDs[int64(i)]; ok {
+			imported = append(imported, games[i])
+		}
+	}
+	return imported, len(games) - len(imported), nil
+}
+
+func (r *GameRepository) FindByUserID(ctx context.Context, userID primitive.ObjectID, limit int64) ([]models.Game, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "imported_at", Value: -1}, {Key: "_id", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var games []models.Game
+	if err := cursor.All(ctx, &games); err != nil {
+		return nil, err
+	}
+
+	if games == nil {
+		games = []models.Game{}
+	}
+	return games, nil
+}
+
+func (r *GameRepository) FindByIDAndUserID(ctx context.Context, id, userID primitive.ObjectID) (*models.Game, error) {
+	var game models.Game
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "user_id": userID}).Decode(&game)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &game, nil
+}
+
+// SaveAnalyses stores the replay results of the games.
+func (r *GameRepository) SaveAnalyses(ctx context.Context, games []models.Game) error {
+	if len(games) == 0 {
+		return nil
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(games))
+	for i := range games {
+		set := bson.M{"status": games[i].Status, "analyzed_at": games[i].AnalyzedAt}
+		update := bson.M{"$set": set}
+		if games[i].Deviation != nil {
+			set["deviation"] = games[i].Deviation
+		} else {
+			update["$unset"] = bson.M{"deviation": ""}
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": games[i].ID}).
+			SetUpdate(update))
+	}
+
+	_, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (r *GameRepository) Delete(ctx context.Context, id, userID primitive.ObjectID) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "user_id": userID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *GameRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+func (r *GameRepository) CreateIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "fingerprint", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "imported_at", Value: -1}}},
+	}, options.CreateIndexes())
+	return err
+}
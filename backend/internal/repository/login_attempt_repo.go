@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+func NewLoginAttemptRepository() *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		collection: database.GetCollection("login_attempts"),
+	}
+}
+
+func (r *LoginAttemptRepository) Find(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := r.collection.FindOne(ctx, bson.M{"_id": key, "expires_at": bson.M{"$gt": time.Now()}}).Decode(&attempt)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// FindLocked returns the counters among keys that are locked out now.
+func (r *LoginAttemptRepository) FindLocked(ctx context.Context, keys []string) ([]models.LoginAttempt, error) {
+	return r.find(ctx, bson.M{"_id": bson.M{"$in": keys}, "locked_until": bson.M{"$gt": time.Now()}}, nil)
+}
+
+// List returns the live counters, the locked ones only if lockedOnly, most
+// recent failure first.
+func (r *LoginAttemptRepository) List(ctx context.Context, lockedOnly bool, limit int64) ([]models.LoginAttempt, error) {
+	now := time.Now()
+	filter := bson.M{"expires_at": bson.M{"$gt": now}}
+	if lockedOnly {
+		filter["locked_until"] = bson.M{"$gt": now}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "last_failure_at", Value: -1}}).SetLimit(limit)
+	return r.find(ctx, filter, opts)
+}
+
+func (r *LoginAttemptRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.LoginAttempt, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	attempts := []models.LoginAttempt{}
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// Save writes the counter if no one else has since it was read, and bumps
+// its version. It returns false when it lost that race; a counter with
+// version 0 is new, and also loses to a concurrent insert.
+func (r *LoginAttemptRepository) Save(ctx context.Context, attempt *models.LoginAttempt) (bool, error) {
+	version := attempt.Version
+	attempt.Version++
+
+	if version == 0 {
+		_, err := r.collection.InsertOne(ctx, attempt)
+		if mongo.IsDuplicateKeyError(err) {
+			// An expired counter not swept yet is replaced
+			result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": attempt.Key, "expires_at": bson.M{"$lte": time.Now()}}, attempt)
+			if err != nil {
+				return false, err
+			}
+			return result.MatchedCount > 0, nil
+		}
+		return err == nil, err
+	}
+
+	result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": attempt.Key, "version": version}, attempt)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// Delete clears the counter. It returns false when there was none.
+func (r *LoginAttemptRepository) Delete(ctx context.Context, key string) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *LoginAttemptRepository) CreateIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"expires_at": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.M{"last_failure_at": -1}},
+	}, options.CreateIndexes())
+	return err
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MailTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMailTokenRepository() *MailTokenRepository {
+	return &MailTokenRepository{
+		collection: database.GetCollection("mail_tokens"),
+	}
+}
+
+// Create records a new token, retiring the user's unused tokens of the same
+// purpose so only the latest mail works.
+func (r *MailTokenRepository) Create(ctx context.Context, token *models.MailToken) error {
+	now := time.Now()
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"user_id": token.UserID, "purpose": token.Purpose, "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+
+	token.CreatedAt = now
+	_, err = r.collection.InsertOne(ctx, token)
+	return err
+}
+
+// Consume marks the token used. It returns false when the token is
+// unknown, already used or expired.
+func (r *MailTokenRepository) Consume(ctx context.Context, id, purpose string) (bool, error) {
+	now := time.Now()
+	result, err := r.collection.UpdateOne(ctx, bson.M{
+		"_id":        id,
+		"purpose":    purpose,
+		"used_at":    bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": now},
+	}, bson.M{"$set": bson.M{"used_at": now}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *MailTokenRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+func (r *MailTokenRepository) CreateIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}}},
+		{Keys: bson.M{"expires_at": 1}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}, options.CreateIndexes())
+	return err
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PositionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewPositionRepository() *PositionRepository {
+	return &PositionRepository{
+		collection: database.GetCollection("positions"),
+	}
+}
+
+// IndexedVersions returns, per repertoire of the user, the repertoire
+// version its position entries were built from.
+func (r *PositionRepository) IndexedVersions(ctx context.Context, userID primitive.ObjectID) (map[primitive.ObjectID]int64, error) {
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID}}},
+		{{Key: "$group", Value: bson.M{"_id": "$repertoire_id", "version": bson.M{"$max": "$repertoire_version"}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		RepertoireID primitive.ObjectID `bson:"_id"`
+		Version      int64              `bson:"version"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	versions := make(map[primitive.ObjectID]int64, len(results))
+	for _, result := range results {
+		versions[result.RepertoireID] = result.Version
+	}
+	return versions, nil
+}
+
+// SyncRepertoire replaces the repertoire's entries with those built from
+// the given version. Entries are upserted by node, so concurrent syncs of
+// the same version converge, and entries left from any other version are
+// removed afterwards.
+func (r *PositionRepository) SyncRepertoire(ctx context.Context, repertoireID primitive.ObjectID, version int64, entries []models.PositionEntry) error {
+	writes := make([]mongo.WriteModel, 0, len(entries))
+	for _, entry := range entries {
+		entry.ID = primitive.ObjectID{}
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{
+				"repertoire_id":      repertoireID,
+				"repertoire_version": version,
+				"opening_id":         entry.OpeningID,
+				"path":               entry.Path,
+			}).
+			SetReplacement(entry).
+			SetUpsert(true))
+	}
+
+	if len(writes) > 0 {
+		if _, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{
+		"repertoire_id":      repertoireID,
+		"repertoire_version": bson.M{"$ne": version},
+	})
+	return err
+}
+
+// DeleteOrphans removes the user's entries that belong to none of the given repertoires.
+func (r *PositionRepository) DeleteOrphans(ctx context.Context, userID primitive.ObjectID, repertoireIDs []primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{
+		"user_id":       userID,
+		"repertoire_id": bson.M{"$nin": repertoireIDs},
+	})
+	return err
+}
+
+// FindByFEN lists every place in the user's repertoires where the position
+// with the given key occurs.
+func (r *PositionRepository) FindByFEN(ctx context.Context, userID primitive.ObjectID, fen string) ([]models.PositionEntry, error) {
+	opts := options.Find().SetSort(bson.D{
+		{Key: "repertoire_name", Value: 1},
+		{Key: "opening_name", Value: 1},
+		{Key: "path", Value: 1},
+	})
+
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID, "fen": fen}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []models.PositionEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	if entries == nil {
+		entries = []models.PositionEntry{}
+	}
+	return entries, nil
+}
+
+func (r *PositionRepository) DeleteByRepertoire(ctx context.Context, repertoireID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"repertoire_id": repertoireID})
+	return err
+}
+
+func (r *PositionRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+func (r *PositionRepository) CreateIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "fen", Value: 1}}},
+		{
+			Keys: bson.D{
+				{Key: "repertoire_id", Value: 1},
+				{Key: "repertoire_version", Value: 1},
+				{Key: "opening_id", Value: 1},
+				{Key: "path", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+	}, options.CreateIndexes())
+	return err
+}
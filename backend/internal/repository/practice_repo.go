@@ -28,6 +28,9 @@ func (r *PracticeRepository) Create(ctx context.Context, session *models.Practic
 	session.StartedAt = time.Now()
 	session.Moves = []models.PracticeMove{}
 	session.Stats = models.PracticeStats{}
+	if session.Line == nil {
+		session.Line = []string{}
+	}
 
 	_, err := r.collection.InsertOne(ctx, session)
 	return err
@@ -88,6 +91,23 @@ func (r *PracticeRepository) AddMove(ctx context.Context, sessionID primitive.Ob
 	return err
 }
 
+// AddMoveAndAdvance records a move and moves the session to a new position,
+// appending the moves played to reach it (the user's and the opponent's).
+func (r *PracticeRepository) AddMoveAndAdvance(ctx context.Context, sessionID primitive.ObjectID, move models.PracticeMove, currentFEN string, played []string) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": sessionID},
+		bson.M{
+			"$push": bson.M{
+				"moves": move,
+				"line":  bson.M{"$each": played},
+			},
+			"$set": bson.M{"current_fen": currentFEN},
+		},
+	)
+	return err
+}
+
 func (r *PracticeRepository) EndSession(ctx context.Context, sessionID primitive.ObjectID, stats models.PracticeStats) error {
 	now := time.Now()
 	_, err := r.collection.UpdateOne(
@@ -101,6 +121,11 @@ func (r *PracticeRepository) EndSession(ctx context.Context, sessionID primitive
 	return err
 }
 
+func (r *PracticeRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
 func (r *PracticeRepository) CreateIndexes(ctx context.Context) error {
 	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "started_at", Value: -1}}},
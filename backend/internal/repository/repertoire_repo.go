@@ -3,6 +3,9 @@ package repository
 import (
 	"context"
 	"errors"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/nagara/openings-master/backend/internal/models"
@@ -13,13 +16,30 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrVersionConflict is returned by writes made against a version of the
+// repertoire that is no longer current.
+var ErrVersionConflict = errors.New("repertoire was modified concurrently")
+
+// PositionIndexer lists the position index entries of a repertoire.
+type PositionIndexer func(repertoire *models.Repertoire) []models.PositionEntry
+
 type RepertoireRepository struct {
 	collection *mongo.Collection
+	revisions  *RevisionRepository
+	positions  *PositionRepository
+	jobs       *AnnotationJobRepository
+	indexer    PositionIndexer
 }
 
-func NewRepertoireRepository() *RepertoireRepository {
+// NewRepertoireRepository returns the repository, which keeps the position
+// index up to date with every write using indexer.
+func NewRepertoireRepository(indexer PositionIndexer) *RepertoireRepository {
 	return &RepertoireRepository{
 		collection: database.GetCollection("repertoires"),
+		revisions:  NewRevisionRepository(),
+		positions:  NewPositionRepository(),
+		jobs:       NewAnnotationJobRepository(),
+		indexer:    indexer,
 	}
 }
 
@@ -27,12 +47,17 @@ func (r *RepertoireRepository) Create(ctx context.Context, repertoire *models.Re
 	repertoire.ID = primitive.NewObjectID()
 	repertoire.CreatedAt = time.Now()
 	repertoire.UpdatedAt = time.Now()
+	repertoire.Version = 0
 	if repertoire.Openings == nil {
 		repertoire.Openings = []models.Opening{}
 	}
 
-	_, err := r.collection.InsertOne(ctx, repertoire)
-	return err
+	if _, err := r.collection.InsertOne(ctx, repertoire); err != nil {
+		return err
+	}
+	r.recordRevision(ctx, repertoire, "create")
+	r.indexPositions(ctx, repertoire)
+	return nil
 }
 
 func (r *RepertoireRepository) FindByUserID(ctx context.Context, userID primitive.ObjectID) ([]models.Repertoire, error) {
@@ -53,6 +78,46 @@ func (r *RepertoireRepository) FindByUserID(ctx context.Context, userID primitiv
 	return repertoires, nil
 }
 
+// FindVersions returns the current version of each of the user's
+// repertoires, without loading their openings.
+func (r *RepertoireRepository) FindVersions(ctx context.Context, userID primitive.ObjectID) (map[primitive.ObjectID]int64, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "version": 1})
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		ID      primitive.ObjectID `bson:"_id"`
+		Version int64              `bson:"version"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	versions := make(map[primitive.ObjectID]int64, len(results))
+	for _, result := range results {
+		versions[result.ID] = result.Version
+	}
+	return versions, nil
+}
+
+// FindByIDs returns the user's repertoires among ids.
+func (r *RepertoireRepository) FindByIDs(ctx context.Context, userID primitive.ObjectID, ids []primitive.ObjectID) ([]models.Repertoire, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}, "user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var repertoires []models.Repertoire
+	if err := cursor.All(ctx, &repertoires); err != nil {
+		return nil, err
+	}
+	return repertoires, nil
+}
+
 func (r *RepertoireRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Repertoire, error) {
 	var repertoire models.Repertoire
 	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&repertoire)
@@ -77,58 +142,295 @@ func (r *RepertoireRepository) FindByIDAndUserID(ctx context.Context, id, userID
 	return &repertoire, nil
 }
 
+// Update saves the repertoire's name, color and openings if it is still at
+// repertoire.Version, which is then incremented.
 func (r *RepertoireRepository) Update(ctx context.Context, repertoire *models.Repertoire) error {
-	repertoire.UpdatedAt = time.Now()
-	_, err := r.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": repertoire.ID},
-		bson.M{"$set": repertoire},
-	)
-	return err
+	return r.save(ctx, repertoire, "update")
 }
 
-func (r *RepertoireRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
-	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
-	return err
+// Restore saves a repertoire whose content was reset from a revision.
+func (r *RepertoireRepository) Restore(ctx context.Context, repertoire *models.Repertoire) error {
+	return r.save(ctx, repertoire, "restore")
 }
 
-func (r *RepertoireRepository) AddOpening(ctx context.Context, repertoireID primitive.ObjectID, opening models.Opening) error {
-	opening.ID = primitive.NewObjectID()
-	_, err := r.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": repertoireID},
-		bson.M{
-			"$push": bson.M{"openings": opening},
-			"$set":  bson.M{"updated_at": time.Now()},
+func (r *RepertoireRepository) save(ctx context.Context, repertoire *models.Repertoire, action string) error {
+	now := time.Now()
+	err := r.write(ctx, action, bson.M{"_id": repertoire.ID}, repertoire.Version, bson.M{
+		"$set": bson.M{
+			"name":       repertoire.Name,
+			"color":      repertoire.Color,
+			"openings":   repertoire.Openings,
+			"updated_at": now,
 		},
-	)
-	return err
+	})
+	if err != nil {
+		return err
+	}
+	repertoire.Version++
+	repertoire.UpdatedAt = now
+	return nil
 }
 
-func (r *RepertoireRepository) UpdateOpening(ctx context.Context, repertoireID, openingID primitive.ObjectID, opening models.Opening) error {
-	_, err := r.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": repertoireID, "openings._id": openingID},
-		bson.M{
-			"$set": bson.M{
-				"openings.$":  opening,
-				"updated_at": time.Now(),
-			},
+func (r *RepertoireRepository) Delete(ctx context.Context, id primitive.ObjectID, version int64) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "version": version})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrVersionConflict
+	}
+	if err := r.positions.DeleteByRepertoire(ctx, id); err != nil {
+		return err
+	}
+	if err := r.jobs.DeleteByRepertoire(ctx, id); err != nil {
+		return err
+	}
+	return r.revisions.DeleteByRepertoire(ctx, id)
+}
+
+// DeleteByUser removes all of the user's repertoires along with their
+// revisions, positions and annotation jobs.
+func (r *RepertoireRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	if _, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	if err := r.positions.DeleteByUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := r.jobs.DeleteByUser(ctx, userID); err != nil {
+		return err
+	}
+	return r.revisions.DeleteByUser(ctx, userID)
+}
+
+// AddOpening appends the opening, assigning it a new ID in place.
+func (r *RepertoireRepository) AddOpening(ctx context.Context, repertoireID primitive.ObjectID, version int64, opening *models.Opening) error {
+	opening.ID = primitive.NewObjectID()
+	return r.write(ctx, "add_opening", bson.M{"_id": repertoireID}, version, bson.M{
+		"$push": bson.M{"openings": opening},
+	})
+}
+
+// AddOpenings appends several openings in a single update, assigning each
+// of them a new ID in place.
+func (r *RepertoireRepository) AddOpenings(ctx context.Context, repertoireID primitive.ObjectID, version int64, openings []models.Opening) error {
+	for i := range openings {
+		openings[i].ID = primitive.NewObjectID()
+	}
+	return r.write(ctx, "import_openings", bson.M{"_id": repertoireID}, version, bson.M{
+		"$push": bson.M{"openings": bson.M{"$each": openings}},
+	})
+}
+
+func (r *RepertoireRepository) UpdateOpening(ctx context.Context, repertoireID primitive.ObjectID, version int64, openingID primitive.ObjectID, opening models.Opening) error {
+	return r.write(ctx, "update_opening", bson.M{"_id": repertoireID, "openings._id": openingID}, version, bson.M{
+		"$set": bson.M{"openings.$": opening},
+	})
+}
+
+func (r *RepertoireRepository) DeleteOpening(ctx context.Context, repertoireID primitive.ObjectID, version int64, openingID primitive.ObjectID) error {
+	return r.write(ctx, "delete_opening", bson.M{"_id": repertoireID}, version, bson.M{
+		"$pull": bson.M{"openings": bson.M{"_id": openingID}},
+	})
+}
+
+// The node editing methods below address a node by its path inside the
+// opening. Besides the version, every update is guarded with the IDs found
+// along that path, so it can never land on the wrong node.
+
+// AddNode appends node to the children of the node at parentPath, or to the
+// opening's first moves when parentPath is empty.
+func (r *RepertoireRepository) AddNode(ctx context.Context, repertoireID primitive.ObjectID, version int64, openingID primitive.ObjectID, parentPath []int, parentID primitive.ObjectID, node models.MoveNode) error {
+	children := childrenField(parentPath)
+	guard := bson.M{"_id": openingID, children + ".uci": bson.M{"$ne": node.UCI}}
+	if len(parentPath) > 0 {
+		guard[nodeField(parentPath)+"._id"] = parentID
+	}
+	return r.updateOpening(ctx, "add_node", repertoireID, version, openingID, guard, bson.M{
+		"$push": bson.M{"openings.$[o]." + children: node},
+	})
+}
+
+// DeleteNode replaces the children of the node at parentPath with
+// remaining: the same nodes minus the deleted one and its subtree, possibly
+// with a new main line. current lists the IDs of the children in their
+// stored order; the update only applies if they are still stored that way.
+func (r *RepertoireRepository) DeleteNode(ctx context.Context, repertoireID primitive.ObjectID, version int64, openingID primitive.ObjectID, parentPath []int, parentID primitive.ObjectID, current []primitive.ObjectID, remaining []models.MoveNode) error {
+	children := childrenField(parentPath)
+	guard := bson.M{"_id": openingID, children: bson.M{"$size": len(current)}}
+	for i, id := range current {
+		guard[children+"."+strconv.Itoa(i)+"._id"] = id
+	}
+	if len(parentPath) > 0 {
+		guard[nodeField(parentPath)+"._id"] = parentID
+	}
+	return r.updateOpening(ctx, "delete_node", repertoireID, version, openingID, guard, bson.M{
+		"$set": bson.M{"openings.$[o]." + children: remaining},
+	})
+}
+
+// PromoteNode makes the node at path the main line among its siblings.
+func (r *RepertoireRepository) PromoteNode(ctx context.Context, repertoireID primitive.ObjectID, version int64, openingID primitive.ObjectID, path []int, nodeID primitive.ObjectID) error {
+	children := "openings.$[o]." + childrenField(path[:len(path)-1])
+	guard := bson.M{"_id": openingID, nodeField(path) + "._id": nodeID}
+	return r.updateOpening(ctx, "promote_node", repertoireID, version, openingID, guard, bson.M{
+		"$set": bson.M{
+			children + ".$[promoted].is_main_line": true,
+			children + ".$[sibling].is_main_line":  false,
 		},
-	)
-	return err
+	}, bson.M{"promoted._id": nodeID}, bson.M{"sibling._id": bson.M{"$ne": nodeID}})
+}
+
+// ReorderNodes replaces the children of the node at parentPath with the
+// same nodes in a new order. current lists the IDs of the children in their
+// stored order; the update only applies if they are still stored that way.
+func (r *RepertoireRepository) ReorderNodes(ctx context.Context, repertoireID primitive.ObjectID, version int64, openingID primitive.ObjectID, parentPath []int, current []primitive.ObjectID, reordered []models.MoveNode) error {
+	children := childrenField(parentPath)
+	guard := bson.M{"_id": openingID, children: bson.M{"$size": len(current)}}
+	for i, id := range current {
+		guard[children+"."+strconv.Itoa(i)+"._id"] = id
+	}
+	return r.updateOpening(ctx, "reorder_nodes", repertoireID, version, openingID, guard, bson.M{
+		"$set": bson.M{"openings.$[o]." + children: reordered},
+	})
+}
+
+// SetNodeComment replaces the comment of the node at path.
+func (r *RepertoireRepository) SetNodeComment(ctx context.Context, repertoireID primitive.ObjectID, version int64, openingID primitive.ObjectID, path []int, nodeID primitive.ObjectID, comment string) error {
+	field := nodeField(path)
+	guard := bson.M{"_id": openingID, field + "._id": nodeID}
+	return r.updateOpening(ctx, "comment_node", repertoireID, version, openingID, guard, bson.M{
+		"$set": bson.M{"openings.$[o]." + field + ".comment": comment},
+	})
 }
 
-func (r *RepertoireRepository) DeleteOpening(ctx context.Context, repertoireID, openingID primitive.ObjectID) error {
-	_, err := r.collection.UpdateOne(
+// updateOpening applies update to the opening matching guard, addressed in
+// the update as "openings.$[o]".
+func (r *RepertoireRepository) updateOpening(ctx context.Context, action string, repertoireID primitive.ObjectID, version int64, openingID primitive.ObjectID, guard, update bson.M, arrayFilters ...interface{}) error {
+	filter := bson.M{"_id": repertoireID, "openings": bson.M{"$elemMatch": guard}}
+	arrayFilters = append([]interface{}{bson.M{"o._id": openingID}}, arrayFilters...)
+	return r.write(ctx, action, filter, version, update, arrayFilters...)
+}
+
+// write applies update to the repertoire matching filter if it is still at
+// version, incrementing the version and touching updated_at, then records a
+// revision of the result under action. It returns ErrVersionConflict when
+// nothing matched.
+func (r *RepertoireRepository) write(ctx context.Context, action string, filter bson.M, version int64, update bson.M, arrayFilters ...interface{}) error {
+	filter["version"] = version
+
+	set, _ := update["$set"].(bson.M)
+	if set == nil {
+		set = bson.M{}
+		update["$set"] = set
+	}
+	if _, ok := set["updated_at"]; !ok {
+		set["updated_at"] = time.Now()
+	}
+	update["$inc"] = bson.M{"version": 1}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if len(arrayFilters) > 0 {
+		opts.SetArrayFilters(options.ArrayFilters{Filters: arrayFilters})
+	}
+
+	var updated models.Repertoire
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrVersionConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	r.recordRevision(ctx, &updated, action)
+	r.indexPositions(ctx, &updated)
+	return nil
+}
+
+// recordRevision snapshots the repertoire after a write. The write itself
+// already succeeded, so a failure only leaves a gap in the history.
+func (r *RepertoireRepository) recordRevision(ctx context.Context, repertoire *models.Repertoire, action string) {
+	if err := r.revisions.Record(ctx, repertoire, action); err != nil {
+		log.Printf("Warning: Failed to record revision %d of repertoire %s: %v", repertoire.Version, repertoire.ID.Hex(), err)
+	}
+}
+
+// indexPositions rebuilds the position index of the repertoire after a
+// write. On failure the index stays at an older version, which position
+// lookups notice and rebuild.
+func (r *RepertoireRepository) indexPositions(ctx context.Context, repertoire *models.Repertoire) {
+	if r.indexer == nil {
+		return
+	}
+	if err := r.positions.SyncRepertoire(ctx, repertoire.ID, repertoire.Version, r.indexer(repertoire)); err != nil {
+		log.Printf("Warning: Failed to index positions of repertoire %s at version %d: %v", repertoire.ID.Hex(), repertoire.Version, err)
+	}
+}
+
+// nodeField returns the field path of a node relative to its opening, e.g.
+// "moves.0.children.2" for path [0 2].
+func nodeField(path []int) string {
+	var b strings.Builder
+	b.WriteString("moves")
+	for i, idx := range path {
+		if i > 0 {
+			b.WriteString(".children")
+		}
+		b.WriteByte('.')
+		b.WriteString(strconv.Itoa(idx))
+	}
+	return b.String()
+}
+
+func childrenField(parentPath []int) string {
+	if len(parentPath) == 0 {
+		return "moves"
+	}
+	return nodeField(parentPath) + ".children"
+}
+
+// FindMissingNodeIDs returns the repertoires holding move nodes saved before
+// nodes had IDs. Nodes are always written with their whole subtree, so a
+// tree missing IDs always lacks them on its first moves.
+func (r *RepertoireRepository) FindMissingNodeIDs(ctx context.Context) ([]models.Repertoire, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"openings": bson.M{"$elemMatch": bson.M{
+			"moves": bson.M{"$elemMatch": bson.M{"_id": bson.M{"$exists": false}}},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var repertoires []models.Repertoire
+	if err := cursor.All(ctx, &repertoires); err != nil {
+		return nil, err
+	}
+	return repertoires, nil
+}
+
+// BackfillOpenings stores openings rewritten by a migration, unless the
+// repertoire changed since it was read.
+func (r *RepertoireRepository) BackfillOpenings(ctx context.Context, repertoire *models.Repertoire) error {
+	return r.write(ctx, "migration", bson.M{"_id": repertoire.ID}, repertoire.Version, bson.M{
+		"$set": bson.M{"openings": repertoire.Openings, "updated_at": repertoire.UpdatedAt},
+	})
+}
+
+// BackfillVersions starts the version of repertoires saved before
+// repertoires were versioned at 0.
+func (r *RepertoireRepository) BackfillVersions(ctx context.Context) (int64, error) {
+	result, err := r.collection.UpdateMany(
 		ctx,
-		bson.M{"_id": repertoireID},
-		bson.M{
-			"$pull": bson.M{"openings": bson.M{"_id": openingID}},
-			"$set":  bson.M{"updated_at": time.Now()},
-		},
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": 0}},
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
 }
 
 func (r *RepertoireRepository) CreateIndexes(ctx context.Context) error {
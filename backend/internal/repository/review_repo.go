@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReviewRepository struct {
+	collection *mongo.Collection
+}
+
+func NewReviewRepository() *ReviewRepository {
+	return &ReviewRepository{
+		collection: database.GetCollection("review_cards"),
+	}
+}
+
+// SyncedVersions returns, per repertoire of the user, the oldest repertoire
+// version among its cards. A sync that failed halfway leaves some cards
+// behind, so the repertoire still reads as out of date.
+func (r *ReviewRepository) SyncedVersions(ctx context.Context, userID primitive.ObjectID) (map[primitive.ObjectID]int64, error) {
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID}}},
+		{{Key: "$group", Value: bson.M{"_id": "$repertoire_id", "version": bson.M{"$min": "$synced_version"}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		RepertoireID primitive.ObjectID `bson:"_id"`
+		Version      int64              `bson:"version"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	versions := make(map[primitive.ObjectID]int64, len(results))
+	for _, result := range results {
+		versions[result.RepertoireID] = result.Version
+	}
+	return versions, nil
+}
+
+// SyncPositions makes the repertoire's cards match its positions at the
+// given version: new positions get a fresh card due now, existing cards
+// keep their schedule but have their position details refreshed, and cards
+// for positions that were removed from the repertoire are deleted.
+func (r *ReviewRepository) SyncPositions(ctx context.Context, userID, repertoireID primitive.ObjectID, version int64, positions []models.ReviewCard, defaultEase float64) error {
+	now := time.Now()
+	keys := make([]string, 0, len(positions))
+	writes := make([]mongo.WriteModel, 0, len(positions))
+
+	for _, p := range positions {
+		keys = append(keys, p.FEN)
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"user_id": userID, "repertoire_id": repertoireID, "fen": p.FEN}).
+			SetUpdate(bson.M{
+				"$set": bson.M{
+					"synced_version": version,
+					"opening_id":     p.OpeningID,
+					"moves":          p.Moves,
+					"expected_moves": p.ExpectedMoves,
+				},
+				"$setOnInsert": bson.M{
+					"ease_factor":   defaultEase,
+					"interval_days": 0,
+					"repetitions":   0,
+					"lapses":        0,
+					"due_at":        now,
+					"created_at":    now,
+					"updated_at":    now,
+				},
+			}).
+			SetUpsert(true))
+	}
+
+	if len(writes) > 0 {
+		if _, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{
+		"user_id":       userID,
+		"repertoire_id": repertoireID,
+		"fen":           bson.M{"$nin": keys},
+	})
+	return err
+}
+
+// DeleteOrphans removes the user's cards that belong to none of the given repertoires.
+func (r *ReviewRepository) DeleteOrphans(ctx context.Context, userID primitive.ObjectID, repertoireIDs []primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{
+		"user_id":       userID,
+		"repertoire_id": bson.M{"$nin": repertoireIDs},
+	})
+	return err
+}
+
+func (r *ReviewRepository) FindByPosition(ctx context.Context, userID, repertoireID primitive.ObjectID, fen string) (*models.ReviewCard, error) {
+	var card models.ReviewCard
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID, "repertoire_id": repertoireID, "fen": fen}).Decode(&card)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &card, nil
+}
+
+func (r *ReviewRepository) FindByRepertoire(ctx context.Context, userID, repertoireID primitive.ObjectID) ([]models.ReviewCard, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID, "repertoire_id": repertoireID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var cards []models.ReviewCard
+	if err := cursor.All(ctx, &cards); err != nil {
+		return nil, err
+	}
+
+	if cards == nil {
+		cards = []models.ReviewCard{}
+	}
+	return cards, nil
+}
+
+func (r *ReviewRepository) FindDue(ctx context.Context, userID primitive.ObjectID, before time.Time) ([]models.ReviewCard, error) {
+	opts := options.Find().SetSort(bson.M{"due_at": 1})
+
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID, "due_at": bson.M{"$lte": before}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var cards []models.ReviewCard
+	if err := cursor.All(ctx, &cards); err != nil {
+		return nil, err
+	}
+
+	if cards == nil {
+		cards = []models.ReviewCard{}
+	}
+	return cards, nil
+}
+
+// Save stores the card's schedule, creating it if the position has no card yet.
+func (r *ReviewRepository) Save(ctx context.Context, card *models.ReviewCard) error {
+	card.UpdatedAt = time.Now()
+	if card.ID.IsZero() {
+		card.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.ReplaceOne(
+		ctx,
+		bson.M{"user_id": card.UserID, "repertoire_id": card.RepertoireID, "fen": card.FEN},
+		card,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *ReviewRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+func (r *ReviewRepository) CreateIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "repertoire_id", Value: 1}, {Key: "fen", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "due_at", Value: 1}}},
+	}, options.CreateIndexes())
+	return err
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxRevisions is how many revisions are kept per repertoire. Every write
+// stores a full snapshot, so older ones are pruned as new ones come in.
+const maxRevisions = 100
+
+type RevisionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewRevisionRepository() *RevisionRepository {
+	return &RevisionRepository{
+		collection: database.GetCollection("repertoire_revisions"),
+	}
+}
+
+// Record stores a snapshot of the repertoire as it is after a write, and
+// prunes the repertoire's revisions beyond the newest maxRevisions.
+func (r *RevisionRepository) Record(ctx context.Context, repertoire *models.Repertoire, action string) error {
+	revision := models.Revision{
+		ID:           primitive.NewObjectID(),
+		RepertoireID: repertoire.ID,
+		UserID:       repertoire.UserID,
+		Version:      repertoire.Version,
+		Action:       action,
+		Snapshot: models.RepertoireSnapshot{
+			Name:     repertoire.Name,
+			Color:    repertoire.Color,
+			Openings: repertoire.Openings,
+		},
+		CreatedAt: time.Now(),
+	}
+	if _, err := r.collection.InsertOne(ctx, revision); err != nil {
+		return err
+	}
+	return r.prune(ctx, repertoire.ID)
+}
+
+func (r *RevisionRepository) prune(ctx context.Context, repertoireID primitive.ObjectID) error {
+	opts := options.FindOne().
+		SetSort(bson.D{{Key: "version", Value: -1}}).
+		SetSkip(maxRevisions - 1).
+		SetProjection(bson.M{"version": 1})
+
+	var oldest models.Revision
+	err := r.collection.FindOne(ctx, bson.M{"repertoire_id": repertoireID}, opts).Decode(&oldest)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collection.DeleteMany(ctx, bson.M{"repertoire_id": repertoireID, "version": bson.M{"$lt": oldest.Version}})
+	return err
+}
+
+// FindByRepertoire lists the repertoire's revisions, newest first, without
+// their openings.
+func (r *RevisionRepository) FindByRepertoire(ctx context.Context, repertoireID primitive.ObjectID, limit int64) ([]models.Revision, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "version", Value: -1}, {Key: "created_at", Value: -1}}).
+		SetProjection(bson.M{"snapshot.openings": 0}).
+		SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, bson.M{"repertoire_id": repertoireID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var revisions []models.Revision
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+
+	if revisions == nil {
+		revisions = []models.Revision{}
+	}
+	return revisions, nil
+}
+
+func (r *RevisionRepository) FindByID(ctx context.Context, repertoireID, id primitive.ObjectID) (*models.Revision, error) {
+	var revision models.Revision
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "repertoire_id": repertoireID}).Decode(&revision)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &revision, nil
+}
+
+func (r *RevisionRepository) DeleteByRepertoire(ctx context.Context, repertoireID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"repertoire_id": repertoireID})
+	return err
+}
+
+func (r *RevisionRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+func (r *RevisionRepository) CreateIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "repertoire_id", Value: 1}, {Key: "version", Value: -1}}},
+	}, options.CreateIndexes())
+	return err
+}
@@ -71,6 +71,169 @@ func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 	return err
 }
 
+// MarkEmailVerified confirms the user's address, provided it is still
+// email. It returns false when the address has changed.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id primitive.ObjectID, email string) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "email": email},
+		bson.M{"$set": bson.M{"email_verified": true, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// ConfirmPendingEmail makes the pending address the user's, verified. It
+// returns false when email is not the pending address.
+func (r *UserRepository) ConfirmPendingEmail(ctx context.Context, id primitive.ObjectID, email string) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "pending_email": email},
+		bson.M{
+			"$set":   bson.M{"email": email, "email_verified": true, "updated_at": time.Now()},
+			"$unset": bson.M{"pending_email": ""},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *UserRepository) SetPendingEmail(ctx context.Context, id primitive.ObjectID, email string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"pending_email": email, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+// UpdateProfile sets the fields given and returns the updated user, or nil
+// if there is no such user.
+func (r *UserRepository) UpdateProfile(ctx context.Context, id primitive.ObjectID, req *models.UpdateUserRequest) (*models.User, error) {
+	set := bson.M{"updated_at": time.Now()}
+	if req.Username != nil {
+		set["username"] = *req.Username
+	}
+	if prefs := req.Preferences; prefs != nil {
+		if prefs.BoardTheme != nil {
+			set["preferences.board_theme"] = *prefs.BoardTheme
+		}
+		if prefs.PieceSet != nil {
+			set["preferences.piece_set"] = *prefs.PieceSet
+		}
+		if prefs.BoardOrientation != nil {
+			set["preferences.board_orientation"] = *prefs.BoardOrientation
+		}
+	}
+
+	var user models.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": set}, opts).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id primitive.ObjectID, hash string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"password_hash": hash, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+// SetTOTPSecret starts two-factor setup with a new secret, replacing any
+// unfinished one. It returns false when two-factor is already enabled.
+func (r *UserRepository) SetTOTPSecret(ctx context.Context, id primitive.ObjectID, secret string) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "two_factor_enabled": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{
+			"totp":       models.TOTPSettings{Secret: secret, RecoveryCodes: []string{}},
+			"updated_at": time.Now(),
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// EnableTOTP turns two-factor on with the secret set up, recording the step
+// of the code that confirmed it. It returns false when the secret is no
+// longer the one set up, or two-factor is already on.
+func (r *UserRepository) EnableTOTP(ctx context.Context, id primitive.ObjectID, secret string, step int64, recoveryHashes []string) (bool, error) {
+	now := time.Now()
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "totp.secret": secret, "two_factor_enabled": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{
+			"two_factor_enabled":  true,
+			"totp.last_step":      step,
+			"totp.recovery_codes": recoveryHashes,
+			"totp.enabled_at":     now,
+			"updated_at":          now,
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// UseTOTPStep records that the code of step was used, unless a code of that
+// step or a later one already was. It returns whether it recorded it.
+func (r *UserRepository) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "totp.last_step": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"totp.last_step": step}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// UseRecoveryCode strikes the recovery code with the given hash. It returns
+// false when the user has no such unused code.
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "two_factor_enabled": true, "totp.recovery_codes": hash},
+		bson.M{"$pull": bson.M{"totp.recovery_codes": hash}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *UserRepository) SetRecoveryCodes(ctx context.Context, id primitive.ObjectID, hashes []string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "two_factor_enabled": true},
+		bson.M{"$set": bson.M{"totp.recovery_codes": hashes, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+func (r *UserRepository) DisableTOTP(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{
+			"$set":   bson.M{"two_factor_enabled": false, "updated_at": time.Now()},
+			"$unset": bson.M{"totp": ""},
+		},
+	)
+	return err
+}
+
+func (r *UserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
 func (r *UserRepository) CreateIndexes(ctx context.Context) error {
 	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys:    bson.M{"email": 1},
@@ -1,6 +1,8 @@
 package router
 
 import (
+	"log"
+
 	"github.com/gin-gonic/gin"
 	"github.com/nagara/openings-master/backend/internal/handlers"
 	"github.com/nagara/openings-master/backend/internal/middleware"
@@ -8,22 +10,46 @@ import (
 	"github.com/nagara/openings-master/backend/internal/services"
 )
 
-func Setup(authService *services.AuthService, openaiService *services.OpenAIService) *gin.Engine {
+func Setup(authService *services.AuthService, openaiService *services.OpenAIService, explorerService *services.ExplorerService, moveClassifier *services.MoveClassifier, engineService *services.EngineService, loginLockout *services.LoginLockout, accountMailer *services.AccountMailer, adminEmails []string, trustedProxies []string) *gin.Engine {
 	r := gin.Default()
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
 
 	// Middleware
 	r.Use(middleware.CORSMiddleware())
 
 	// Repositories
 	userRepo := repository.NewUserRepository()
-	repertoireRepo := repository.NewRepertoireRepository()
+	sessionRepo := repository.NewSessionRepository()
+	mailTokenRepo := repository.NewMailTokenRepository()
+	attemptRepo := repository.NewLoginAttemptRepository()
+	repertoireRepo := repository.NewRepertoireRepository(services.IndexPositions)
 	practiceRepo := repository.NewPracticeRepository()
+	reviewRepo := repository.NewReviewRepository()
+	revisionRepo := repository.NewRevisionRepository()
+	positionRepo := repository.NewPositionRepository()
+	gameRepo := repository.NewGameRepository()
+	evalRepo := repository.NewEvalRepository()
+	annotationJobRepo := repository.NewAnnotationJobRepository()
 
 	// Handlers
-	authHandler := handlers.NewAuthHandler(userRepo, authService)
+	authHandler := handlers.NewAuthHandler(userRepo, sessionRepo, mailTokenRepo, attemptRepo, authService, loginLockout, accountMailer)
+	userHandler := handlers.NewUserHandler(userRepo, sessionRepo, mailTokenRepo, repertoireRepo, reviewRepo, practiceRepo, gameRepo, attemptRepo, authService, loginLockout, accountMailer)
 	repertoireHandler := handlers.NewRepertoireHandler(repertoireRepo)
-	practiceHandler := handlers.NewPracticeHandler(practiceRepo, repertoireRepo)
+	practiceHandler := handlers.NewPracticeHandler(practiceRepo, repertoireRepo, reviewRepo, evalRepo, moveClassifier)
+	reviewHandler := handlers.NewReviewHandler(reviewRepo, repertoireRepo)
+	revisionHandler := handlers.NewRevisionHandler(revisionRepo, repertoireRepo)
+	positionHandler := handlers.NewPositionHandler(positionRepo, repertoireRepo)
+	openingHandler := handlers.NewOpeningHandler()
+	explorerHandler := handlers.NewExplorerHandler(explorerService)
+	coverageHandler := handlers.NewCoverageHandler(repertoireRepo, gameRepo, explorerService)
+	gameHandler := handlers.NewGameHandler(gameRepo, repertoireRepo, userRepo)
+	engineHandler := handlers.NewEngineHandler(engineService, evalRepo)
+	evalHandler := handlers.NewEvalHandler(evalRepo)
+	annotationHandler := handlers.NewAnnotationHandler(annotationJobRepo, repertoireRepo, evalRepo, engineService, moveClassifier)
 	teachingHandler := handlers.NewTeachingHandler(openaiService)
+	adminHandler := handlers.NewAdminHandler(attemptRepo)
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
@@ -38,17 +64,40 @@ func Setup(authService *services.AuthService, openaiService *services.OpenAIServ
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/login/2fa", authHandler.LoginTwoFactor)
 			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/logout", authHandler.Logout)
+			auth.POST("/verify-email", authHandler.VerifyEmail)
+			auth.POST("/forgot-password", authHandler.ForgotPassword)
+			auth.POST("/reset-password", authHandler.ResetPassword)
+
+			// Account routes, for the signed-in user
+			account := auth.Group("")
+			account.Use(middleware.AuthMiddleware(authService, sessionRepo))
+			{
+				account.POST("/logout-all", authHandler.LogoutAll)
+				account.GET("/sessions", authHandler.Sessions)
+				account.DELETE("/sessions/:sessionId", authHandler.RevokeSession)
+				account.POST("/verify-email/resend", authHandler.ResendVerification)
+				account.POST("/2fa/setup", authHandler.SetupTwoFactor)
+				account.POST("/2fa/enable", authHandler.EnableTwoFactor)
+				account.POST("/2fa/disable", authHandler.DisableTwoFactor)
+				account.POST("/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+			}
 		}
 
 		// Protected routes
 		protected := api.Group("")
-		protected.Use(middleware.AuthMiddleware(authService))
+		protected.Use(middleware.AuthMiddleware(authService, sessionRepo))
 		{
 			// User routes
 			users := protected.Group("/users")
 			{
 				users.GET("/me", authHandler.GetMe)
+				users.PATCH("/me", userHandler.Update)
+				users.DELETE("/me", userHandler.Delete)
+				users.POST("/me/password", userHandler.ChangePassword)
+				users.POST("/me/email", userHandler.ChangeEmail)
 			}
 
 			// Repertoire routes
@@ -59,9 +108,57 @@ func Setup(authService *services.AuthService, openaiService *services.OpenAIServ
 				repertoires.GET("/:id", repertoireHandler.Get)
 				repertoires.PUT("/:id", repertoireHandler.Update)
 				repertoires.DELETE("/:id", repertoireHandler.Delete)
+				repertoires.GET("/:id/export.pgn", repertoireHandler.ExportPGN)
+				repertoires.GET("/:id/analysis", repertoireHandler.Analysis)
+				repertoires.GET("/:id/coverage", coverageHandler.Coverage)
+				repertoires.POST("/:id/annotate", annotationHandler.Start)
+				repertoires.GET("/:id/annotations", annotationHandler.Latest)
 				repertoires.POST("/:id/openings", repertoireHandler.AddOpening)
+				repertoires.POST("/:id/openings/import", repertoireHandler.ImportOpenings)
 				repertoires.PUT("/:id/openings/:openingId", repertoireHandler.UpdateOpening)
 				repertoires.DELETE("/:id/openings/:openingId", repertoireHandler.DeleteOpening)
+				repertoires.GET("/:id/openings/:openingId/export.pgn", repertoireHandler.ExportOpeningPGN)
+				repertoires.POST("/:id/openings/:openingId/nodes", repertoireHandler.AddNode)
+				repertoires.GET("/:id/openings/:openingId/nodes/:nodeId", repertoireHandler.GetNode)
+				repertoires.DELETE("/:id/openings/:openingId/nodes/:nodeId", repertoireHandler.DeleteNode)
+				repertoires.POST("/:id/openings/:openingId/nodes/:nodeId/promote", repertoireHandler.PromoteNode)
+				repertoires.PUT("/:id/openings/:openingId/nodes/:nodeId/index", repertoireHandler.ReorderNode)
+				repertoires.PUT("/:id/openings/:openingId/nodes/:nodeId/comment", repertoireHandler.UpdateNodeComment)
+				repertoires.GET("/:id/revisions", revisionHandler.List)
+				repertoires.GET("/:id/revisions/diff", revisionHandler.Diff)
+				repertoires.GET("/:id/revisions/:revisionId", revisionHandler.Get)
+				repertoires.POST("/:id/revisions/:revisionId/restore", revisionHandler.Restore)
+			}
+
+			// Opening routes
+			protected.GET("/openings/classify", openingHandler.Classify)
+
+			// Explorer routes
+			protected.GET("/explorer", explorerHandler.Get)
+
+			// Position routes
+			protected.GET("/positions", positionHandler.Lookup)
+
+			// Engine routes
+			protected.POST("/engine/analyze", engineHandler.Analyze)
+
+			// Eval routes
+			protected.GET("/evals", evalHandler.Get)
+			protected.PUT("/evals", evalHandler.Put)
+
+			// Annotation job routes
+			protected.GET("/jobs/:jobId", annotationHandler.Get)
+			protected.POST("/jobs/:jobId/cancel", annotationHandler.Cancel)
+
+			// Game routes
+			games := protected.Group("/games")
+			{
+				games.GET("", gameHandler.List)
+				games.POST("/import", gameHandler.Import)
+				games.GET("/report", gameHandler.Report)
+				games.POST("/reanalyze", gameHandler.Reanalyze)
+				games.GET("/:gameId", gameHandler.Get)
+				games.DELETE("/:gameId", gameHandler.Delete)
 			}
 
 			// Practice routes
@@ -74,6 +171,12 @@ func Setup(authService *services.AuthService, openaiService *services.OpenAIServ
 				practice.GET("/:sessionId", practiceHandler.GetSession)
 			}
 
+			// Review routes
+			review := protected.Group("/review")
+			{
+				review.GET("/due", reviewHandler.Due)
+			}
+
 			// Teaching routes
 			teaching := protected.Group("/teaching")
 			{
@@ -81,6 +184,14 @@ func Setup(authService *services.AuthService, openaiService *services.OpenAIServ
 				teaching.POST("/suggest-plan", teachingHandler.SuggestPlan)
 				teaching.POST("/analyze-mistake", teachingHandler.AnalyzeMistake)
 			}
+
+			// Admin routes
+			admin := protected.Group("/admin")
+			admin.Use(middleware.AdminMiddleware(userRepo, adminEmails))
+			{
+				admin.GET("/lockouts", adminHandler.Lockouts)
+				admin.DELETE("/lockouts", adminHandler.ClearLockout)
+			}
 		}
 	}
 
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AccountMailer writes the emails of the account flows, linking back to the
+// frontend at appURL.
+type AccountMailer struct {
+	mailer Mailer
+	appURL string
+}
+
+func NewAccountMailer(mailer Mailer, appURL string) *AccountMailer {
+	return &AccountMailer{
+		mailer: mailer,
+		appURL: strings.TrimRight(appURL, "/"),
+	}
+}
+
+func (m *AccountMailer) SendVerification(ctx context.Context, to, username, token string, expiresAt time.Time) error {
+	link := m.appURL + "/verify-email?token=" + url.QueryEscape(token)
+	return m.mailer.Send(ctx, Mail{
+		To:      to,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm this email address for your Openings Master account by opening:\n\n%s\n\nThe link expires on %s.\n",
+			username, link, expiresAt.UTC().Format("2 Jan 2006 15:04 MST")),
+	})
+}
+
+func (m *AccountMailer) SendPasswordReset(ctx context.Context, to, username, token string, expiresAt time.Time) error {
+	link := m.appURL + "/reset-password?token=" + url.QueryEscape(token)
+	return m.mailer.Send(ctx, Mail{
+		To:      to,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your Openings Master account. To choose a new one, open:\n\n%s\n\nThe link works once and expires on %s. If you did not ask for this, ignore this email.\n",
+			username, link, expiresAt.UTC().Format("2 Jan 2006 15:04 MST")),
+	})
+}
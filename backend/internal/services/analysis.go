@@ -0,0 +1,299 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+// AnalyzeRepertoire checks a repertoire for structural problems: moves that
+// cannot be played, stored FENs that disagree with the moves, the same move
+// prepared twice, and, on the repertoire's side, positions answered with
+// different moves or not answered at all. Positions are compared without
+// move counters, so transpositions between openings are taken into account.
+func AnalyzeRepertoire(repertoire *models.Repertoire) models.AnalysisReport {
+	a := &analyzer{
+		choices:    map[string]*ownChoice{},
+		answered:   map[string]bool{},
+		unanswered: map[string]*models.AnalysisIssue{},
+	}
+	if color, err := chess.ParseColor(repertoire.Color); err == nil {
+		a.color = color
+		a.hasColor = true
+	}
+
+	for i := range repertoire.Openings {
+		opening := &repertoire.Openings[i]
+		pos, err := StartingPosition(opening.StartingFEN)
+		if err != nil {
+			a.issues = append(a.issues, models.AnalysisIssue{
+				Kind:      models.IssueFENMismatch,
+				Message:   "invalid starting position: " + err.Error(),
+				Locations: []models.NodeLocation{{OpeningID: opening.ID, OpeningName: opening.Name, Line: []string{}}},
+			})
+			continue
+		}
+		a.walk(opening, pos, opening.Moves, nil, nil)
+	}
+
+	for _, key := range a.choiceOrder {
+		choice := a.choices[key]
+		if len(choice.moves) > 1 {
+			a.issues = append(a.issues, models.AnalysisIssue{
+				Kind:      models.IssueConflictingChoice,
+				Message:   fmt.Sprintf("%d different moves prepared in the same position: %s", len(choice.moves), strings.Join(choice.moves, ", ")),
+				FEN:       key,
+				Moves:     choice.moves,
+				Locations: choice.locations,
+			})
+		}
+	}
+	for _, key := range a.unansweredOrder {
+		if !a.answered[key] {
+			a.issues = append(a.issues, *a.unanswered[key])
+		}
+	}
+	a.issues = append(a.issues, duplicateOpeningLines(repertoire)...)
+
+	counts := map[string]int{}
+	for _, issue := range a.issues {
+		counts[issue.Kind]++
+	}
+	if a.issues == nil {
+		a.issues = []models.AnalysisIssue{}
+	}
+	return models.AnalysisReport{RepertoireID: repertoire.ID, Issues: a.issues, Counts: counts}
+}
+
+type ownChoice struct {
+	ucis      []string
+	moves     []string
+	locations []models.NodeLocation
+}
+
+type analyzer struct {
+	color    chess.Color
+	hasColor bool
+	issues   []models.AnalysisIssue
+
+	// Moves prepared on the repertoire's side, by position key
+	choices     map[string]*ownChoice
+	choiceOrder []string
+	answered    map[string]bool
+
+	// Lines ending on an opponent move, by position key; reported unless the
+	// position is answered elsewhere in the repertoire
+	unanswered      map[string]*models.AnalysisIssue
+	unansweredOrder []string
+}
+
+func (a *analyzer) walk(opening *models.Opening, pos *chess.Position, nodes []models.MoveNode, path []int, line []string) {
+	seen := map[string]int{}
+	for i := range nodes {
+		node := &nodes[i]
+		nodePath := append(path[:len(path):len(path)], i)
+		loc := func(move string) models.NodeLocation {
+			return models.NodeLocation{
+				OpeningID:   opening.ID,
+				OpeningName: opening.Name,
+				NodeID:      node.ID,
+				Path:        FormatNodePath(nodePath),
+				Line:        append(line[:len(line):len(line)], move),
+			}
+		}
+
+		var next *chess.Position
+		m, err := resolveNodeMove(pos, node)
+		if err == nil {
+			next, err = pos.Apply(m)
+		}
+		if err != nil {
+			move := node.Move
+			if move == "" {
+				move = node.UCI
+			}
+			a.issues = append(a.issues, models.AnalysisIssue{
+				Kind:      models.IssueIllegalMove,
+				Message:   err.Error(),
+				FEN:       pos.Key(),
+				Locations: []models.NodeLocation{loc(move)},
+			})
+			if n := countNodes(node.Children); n > 0 {
+				a.issues = append(a.issues, models.AnalysisIssue{
+					Kind:      models.IssueDanglingNode,
+					Message:   fmt.Sprintf("%d nodes below an illegal move can never be reached", n),
+					Locations: []models.NodeLocation{loc(move)},
+				})
+			}
+			continue
+		}
+
+		san := pos.SAN(m)
+		here := loc(san)
+
+		if j, dup := seen[m.UCI()]; dup {
+			a.issues = append(a.issues, models.AnalysisIssue{
+				Kind:    models.IssueDuplicateLine,
+				Message: fmt.Sprintf("%s is prepared twice in the same position", san),
+				FEN:     pos.Key(),
+				Locations: []models.NodeLocation{
+					{OpeningID: opening.ID, OpeningName: opening.Name, NodeID: nodes[j].ID, Path: FormatNodePath(append(path[:len(path):len(path)], j)), Line: here.Line},
+					here,
+				},
+			})
+		} else {
+			seen[m.UCI()] = i
+		}
+
+		if node.FEN != "" {
+			if stored, err := chess.NormalizeFEN(node.FEN); err != nil {
+				a.issues = append(a.issues, models.AnalysisIssue{
+					Kind:      models.IssueFENMismatch,
+					Message:   "stored fen is invalid: " + err.Error(),
+					FEN:       next.Key(),
+					Locations: []models.NodeLocation{here},
+				})
+			} else if stored != next.Key() {
+				a.issues = append(a.issues, models.AnalysisIssue{
+					Kind:      models.IssueFENMismatch,
+					Message:   "stored fen " + node.FEN + " is not the position after the move",
+					FEN:       next.Key(),
+					Locations: []models.NodeLocation{here},
+				})
+			}
+		}
+
+		if a.hasColor && pos.Turn == a.color {
+			a.addChoice(pos.Key(), m.UCI(), san, here)
+		}
+		if a.hasColor && next.Turn == a.color && len(node.Children) == 0 && len(next.LegalMoves()) > 0 {
+			a.addUnanswered(next.Key(), here)
+		}
+
+		a.walk(opening, next, node.Children, nodePath, here.Line)
+	}
+}
+
+func (a *analyzer) addChoice(key, uci, san string, loc models.NodeLocation) {
+	a.answered[key] = true
+	choice, ok := a.choices[key]
+	if !ok {
+		choice = &ownChoice{}
+		a.choices[key] = choice
+		a.choiceOrder = append(a.choiceOrder, key)
+	}
+	if !containsString(choice.ucis, uci) {
+		choice.ucis = append(choice.ucis, uci)
+		choice.moves = append(choice.moves, san)
+	}
+	choice.locations = append(choice.locations, loc)
+}
+
+func (a *analyzer) addUnanswered(key string, loc models.NodeLocation) {
+	issue, ok := a.unanswered[key]
+	if !ok {
+		issue = &models.AnalysisIssue{
+			Kind:    models.IssueMissingReply,
+			Message: "the line ends on the opponent's move without a prepared reply",
+			FEN:     key,
+		}
+		a.unanswered[key] = issue
+		a.unansweredOrder = append(a.unansweredOrder, key)
+	}
+	issue.Locations = append(issue.Locations, loc)
+}
+
+// duplicateOpeningLines reports complete lines of an opening that another
+// opening of the repertoire already contains move for move.
+func duplicateOpeningLines(repertoire *models.Repertoire) []models.AnalysisIssue {
+	var issues []models.AnalysisIssue
+	openings := repertoire.Openings
+	for i := range openings {
+		for j := range openings {
+			if i == j || openings[i].StartingFEN != openings[j].StartingFEN {
+				continue
+			}
+			for _, leaf := range leafPaths(openings[i].Moves, nil) {
+				other := findLine(openings[j].Moves, openings[i].Moves, leaf)
+				// A line that ends at the same move in both openings is
+				// reported once, for the earlier opening
+				if other == nil || (i > j && isLeaf(openings[j].Moves, other)) {
+					continue
+				}
+				line := LineTo(openings[i].Moves, leaf)
+				issues = append(issues, models.AnalysisIssue{
+					Kind:    models.IssueDuplicateLine,
+					Message: fmt.Sprintf("line %s of %q is already part of %q", strings.Join(line, " "), openings[i].Name, openings[j].Name),
+					Locations: []models.NodeLocation{
+						nodeLocation(&openings[i], leaf),
+						nodeLocation(&openings[j], other),
+					},
+				})
+			}
+		}
+	}
+	return issues
+}
+
+// leafPaths lists the paths of every node without children.
+func leafPaths(nodes []models.MoveNode, path []int) [][]int {
+	var leaves [][]int
+	for i := range nodes {
+		nodePath := append(path[:len(path):len(path)], i)
+		if len(nodes[i].Children) == 0 {
+			leaves = append(leaves, nodePath)
+			continue
+		}
+		leaves = append(leaves, leafPaths(nodes[i].Children, nodePath)...)
+	}
+	return leaves
+}
+
+// findLine follows the moves along path in the tree source inside the tree
+// target and returns the matching path in target, or nil.
+func findLine(target, source []models.MoveNode, path []int) []int {
+	found := make([]int, 0, len(path))
+	for _, idx := range path {
+		uci := source[idx].UCI
+		next := -1
+		for k := range target {
+			if uci != "" && target[k].UCI == uci {
+				next = k
+				break
+			}
+		}
+		if next < 0 {
+			return nil
+		}
+		found = append(found, next)
+		source = source[idx].Children
+		target = target[next].Children
+	}
+	return found
+}
+
+func isLeaf(nodes []models.MoveNode, path []int) bool {
+	node := NodeAt(nodes, path)
+	return node != nil && len(node.Children) == 0
+}
+
+func nodeLocation(opening *models.Opening, path []int) models.NodeLocation {
+	return models.NodeLocation{
+		OpeningID:   opening.ID,
+		OpeningName: opening.Name,
+		NodeID:      NodeAt(opening.Moves, path).ID,
+		Path:        FormatNodePath(path),
+		Line:        LineTo(opening.Moves, path),
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, existing := range list {
+		if existing == s {
+			return true
+		}
+	}
+	return false
+}
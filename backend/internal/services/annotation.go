@@ -0,0 +1,123 @@
+package services
+
+import (
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+// mateScore stands in for a forced mate when comparing evals in centipawns.
+const mateScore = 100000
+
+// AnnotationPositions lists the unique positions annotating the repertoire
+// needs evals of: the start of each opening and the position after every
+// node, as keys.
+func AnnotationPositions(repertoire *models.Repertoire) []string {
+	keys := []string{}
+	seen := map[string]bool{}
+	add := func(fen string) {
+		key, err := chess.NormalizeFEN(fen)
+		if err != nil || seen[key] {
+			return
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+
+	var walk func(nodes []models.MoveNode)
+	walk = func(nodes []models.MoveNode) {
+		for i := range nodes {
+			add(nodes[i].FEN)
+			walk(nodes[i].Children)
+		}
+	}
+	for i := range repertoire.Openings {
+		opening := &repertoire.Openings[i]
+		start, err := StartingPosition(opening.StartingFEN)
+		if err != nil {
+			continue
+		}
+		add(start.FEN())
+		walk(opening.Moves)
+	}
+	return keys
+}
+
+// TerminalEval returns the eval of a checkmate or stalemate, which engines
+// have no line for. It returns false while the position has legal moves.
+func TerminalEval(key string) (models.PositionEval, bool) {
+	pos, err := chess.ParseFEN(key)
+	if err != nil || len(pos.LegalMoves()) > 0 {
+		return models.PositionEval{}, false
+	}
+	eval := models.PositionEval{FEN: pos.Key(), ScoreType: "cp", PV: []string{}}
+	if pos.InCheck() {
+		eval.ScoreType = "mate"
+	}
+	return eval, true
+}
+
+// AnnotateRepertoire judges every prepared move by the evals of the
+// positions before and after it. Moves lacking either eval are skipped.
+func AnnotateRepertoire(repertoire *models.Repertoire, evals map[string]models.PositionEval, classifier *MoveClassifier) []models.NodeAnnotation {
+	annotations := []models.NodeAnnotation{}
+
+	var walk func(opening *models.Opening, before *chess.Position, nodes []models.MoveNode, path []int)
+	walk = func(opening *models.Opening, before *chess.Position, nodes []models.MoveNode, path []int) {
+		evalBefore, haveBefore := evals[before.Key()]
+		for i := range nodes {
+			node := &nodes[i]
+			after, err := chess.ParseFEN(node.FEN)
+			if err != nil {
+				continue
+			}
+			nodePath := append(path[:len(path):len(path)], i)
+
+			if evalAfter, ok := evals[after.Key()]; ok && haveBefore {
+				judgement := classifier.Classify(before.Turn, EvalCentipawns(evalBefore, before), EvalCentipawns(evalAfter, after))
+				annotations = append(annotations, models.NodeAnnotation{
+					OpeningID:     opening.ID,
+					NodeID:        node.ID,
+					Path:          FormatNodePath(nodePath),
+					Move:          node.Move,
+					FEN:           after.Key(),
+					Depth:         evalAfter.Depth,
+					ScoreType:     evalAfter.ScoreType,
+					Score:         evalAfter.Score,
+					CentipawnLoss: judgement.CentipawnLoss,
+					Category:      judgement.Category,
+					Dubious:       judgement.Category == models.MoveCategoryMistake || judgement.Category == models.MoveCategoryBlunder,
+				})
+			}
+			walk(opening, after, node.Children, nodePath)
+		}
+	}
+
+	for i := range repertoire.Openings {
+		opening := &repertoire.Openings[i]
+		start, err := StartingPosition(opening.StartingFEN)
+		if err != nil {
+			continue
+		}
+		walk(opening, start, opening.Moves, nil)
+	}
+	return annotations
+}
+
+// EvalCentipawns expresses an eval of pos in centipawns from White's point
+// of view, counting a forced mate as a decisive advantage.
+func EvalCentipawns(eval models.PositionEval, pos *chess.Position) int {
+	if eval.ScoreType != "mate" {
+		return eval.Score
+	}
+	switch {
+	case eval.Score > 0:
+		return mateScore
+	case eval.Score < 0:
+		return -mateScore
+	case pos.Turn == chess.White:
+		// Mate on the board: the side to move is mated
+		return -mateScore
+	default:
+		return mateScore
+	}
+}
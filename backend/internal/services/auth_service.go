@@ -1,6 +1,8 @@
 package services
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -8,24 +10,43 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Purposes of the single-use tokens mailed to users, carried in Claims.Type.
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenPasswordReset = "password_reset"
+)
+
+// TokenTwoFactorChallenge marks the token a password login returns when the
+// user must still enter a second factor.
+const TokenTwoFactorChallenge = "2fa_challenge"
+
+// challengeExpiry is how long the user has to enter the second factor.
+const challengeExpiry = 5 * time.Minute
+
 type AuthService struct {
 	jwtSecret     []byte
 	accessExpiry  time.Duration
 	refreshExpiry time.Duration
+	verifyExpiry  time.Duration
+	resetExpiry   time.Duration
 }
 
 type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
 	Type   string `json:"type,omitempty"`
+	// SessionID names the refresh-token family the token was issued in
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func NewAuthService(secret string) *AuthService {
+func NewAuthService(secret string, verifyExpiry, resetExpiry time.Duration) *AuthService {
 	return &AuthService{
 		jwtSecret:     []byte(secret),
 		accessExpiry:  15 * time.Minute,
 		refreshExpiry: 7 * 24 * time.Hour,
+		verifyExpiry:  verifyExpiry,
+		resetExpiry:   resetExpiry,
 	}
 }
 
@@ -42,13 +63,30 @@ func (s *AuthService) VerifyPassword(hash, password string) bool {
 	return err == nil
 }
 
-func (s *AuthService) GenerateTokenPair(userID, email string) (accessToken, refreshToken string, err error) {
+// RefreshExpiry is how long a session lasts without being refreshed.
+func (s *AuthService) RefreshExpiry() time.Duration {
+	return s.refreshExpiry
+}
+
+// NewTokenID returns a random ID for a refresh token.
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GenerateTokenPair issues the tokens of a session. The refresh token
+// carries tokenID, which the session must still hold for it to be accepted.
+func (s *AuthService) GenerateTokenPair(userID, email, sessionID, tokenID string) (accessToken, refreshToken string, err error) {
 	now := time.Now()
 
 	// Access token
 	accessClaims := Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessExpiry)),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -63,9 +101,11 @@ func (s *AuthService) GenerateTokenPair(userID, email string) (accessToken, refr
 
 	// Refresh token
 	refreshClaims := Claims{
-		UserID: userID,
-		Type:   "refresh",
+		UserID:    userID,
+		Type:      "refresh",
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.refreshExpiry)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			Subject:   userID,
@@ -113,6 +153,91 @@ func (s *AuthService) ValidateRefreshToken(tokenString string) (*Claims, error)
 	if claims.Type != "refresh" {
 		return nil, errors.New("not a refresh token")
 	}
+	if claims.SessionID == "" || claims.ID == "" {
+		// Issued before sessions were tracked
+		return nil, errors.New("refresh token has no session")
+	}
+
+	return claims, nil
+}
+
+// GenerateMailToken issues a token for one of the mailed flows. It is bound
+// to email, so it stops working if the user's address changes, and carries
+// tokenID so it can be used only once.
+func (s *AuthService) GenerateMailToken(purpose, userID, email, tokenID string) (token string, expiresAt time.Time, err error) {
+	var expiry time.Duration
+	switch purpose {
+	case TokenVerifyEmail:
+		expiry = s.verifyExpiry
+	case TokenPasswordReset:
+		expiry = s.resetExpiry
+	default:
+		return "", time.Time{}, errors.New("unknown token purpose")
+	}
+
+	now := time.Now()
+	expiresAt = now.Add(expiry)
+	claims := Claims{
+		UserID: userID,
+		Email:  email,
+		Type:   purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   userID,
+		},
+	}
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.jwtSecret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+func (s *AuthService) ValidateMailToken(tokenString, purpose string) (*Claims, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Type != purpose || claims.ID == "" {
+		return nil, errors.New("wrong token purpose")
+	}
+
+	return claims, nil
+}
+
+// GenerateChallengeToken issues the token that stands for a password
+// already checked, to be traded with a second factor for a token pair.
+func (s *AuthService) GenerateChallengeToken(userID string) (token string, expiresAt time.Time, err error) {
+	now := time.Now()
+	expiresAt = now.Add(challengeExpiry)
+	claims := Claims{
+		UserID: userID,
+		Type:   TokenTwoFactorChallenge,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   userID,
+		},
+	}
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.jwtSecret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+func (s *AuthService) ValidateChallengeToken(tokenString string) (*Claims, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Type != TokenTwoFactorChallenge {
+		return nil, errors.New("not a challenge token")
+	}
 
 	return claims, nil
 }
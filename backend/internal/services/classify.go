@@ -0,0 +1,51 @@
+package services
+
+import (
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/eco"
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+// ClassifyOpening fills in the opening's ECO code and name from the deepest
+// position of its main line found in the ECO table. Fields the user already
+// set are left alone. The opening must have been normalized.
+func ClassifyOpening(opening *models.Opening) {
+	if opening.ECO != "" && opening.Name != "" {
+		return
+	}
+
+	var best *eco.Opening
+	if pos, err := StartingPosition(opening.StartingFEN); err == nil {
+		best, _ = eco.Lookup(pos.Key())
+	}
+	for nodes := opening.Moves; len(nodes) > 0; {
+		node := MainLineMove(nodes)
+		if key, err := chess.NormalizeFEN(node.FEN); err == nil {
+			if match, ok := eco.Lookup(key); ok {
+				best = match
+			}
+		}
+		nodes = node.Children
+	}
+
+	if best == nil {
+		return
+	}
+	if opening.ECO == "" {
+		opening.ECO = best.ECO
+	}
+	if opening.Name == "" {
+		opening.Name = best.Name
+	}
+}
+
+// Classification converts a table entry into its API form.
+func Classification(opening *eco.Opening, ply int) models.ClassificationResponse {
+	return models.ClassificationResponse{
+		ECO:  opening.ECO,
+		Name: opening.Name,
+		Line: opening.Moves,
+		FEN:  opening.FEN,
+		Ply:  ply,
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"slices"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+// Defaults for CoverageOptions.
+const (
+	DefaultCoverageMinShare     = 0.05
+	DefaultCoverageMaxDepth     = 20
+	DefaultCoverageMaxPositions = 150
+)
+
+// MoveStatsFunc returns how often each move was played in a position, as
+// the opening explorer reports it.
+type MoveStatsFunc func(ctx context.Context, fen string) (*models.ExplorerResponse, error)
+
+type CoverageOptions struct {
+	MinShare     float64 // ignore replies played in a smaller share of the position's games
+	MinGames     int64   // ignore replies played in fewer games
+	MaxDepth     int     // plies from the opening's start
+	MaxPositions int     // positions looked up, shallowest first
+}
+
+type coveragePosition struct {
+	pos       *chess.Position
+	depth     int
+	locations []models.NodeLocation
+}
+
+// RepertoireCoverage lists the opponent replies the repertoire does not
+// answer, most played first. Every position where the opponent is to move
+// is looked up once, however many lines reach it, and a reply counts as
+// answered when any line of the repertoire continues from it. Positions are
+// looked up shallowest first; when the source fails part way, the report
+// covers the positions checked so far and is marked incomplete.
+func RepertoireCoverage(ctx context.Context, repertoire *models.Repertoire, stats MoveStatsFunc, opts CoverageOptions) (models.CoverageReport, error) {
+	report := models.CoverageReport{
+		RepertoireID: repertoire.ID,
+		MinShare:     opts.MinShare,
+		MinGames:     opts.MinGames,
+		Gaps:         []models.CoverageGap{},
+	}
+
+	color, err := chess.ParseColor(repertoire.Color)
+	if err != nil {
+		return report, err
+	}
+
+	openings := make([]*models.Opening, len(repertoire.Openings))
+	for i := range repertoire.Openings {
+		openings[i] = &repertoire.Openings[i]
+	}
+	tree := NewOpeningTree(openings...)
+
+	positions := map[string]*coveragePosition{}
+	var keys []string
+
+	var walk func(opening *models.Opening, pos *chess.Position, node *models.MoveNode, path []int, line []string)
+	walk = func(opening *models.Opening, pos *chess.Position, node *models.MoveNode, path []int, line []string) {
+		depth := len(path)
+		if depth > opts.MaxDepth {
+			return
+		}
+
+		if pos.Turn != color && len(pos.LegalMoves()) > 0 {
+			location := models.NodeLocation{
+				OpeningID:   opening.ID,
+				OpeningName: opening.Name,
+				Path:        FormatNodePath(path),
+				Line:        line,
+			}
+			if node != nil {
+				location.NodeID = node.ID
+			}
+
+			key := pos.Key()
+			p, ok := positions[key]
+			if !ok {
+				p = &coveragePosition{pos: pos, depth: depth}
+				positions[key] = p
+				keys = append(keys, key)
+			}
+			p.depth = min(p.depth, depth)
+			p.locations = append(p.locations, location)
+		}
+
+		var children []models.MoveNode
+		if node != nil {
+			children = node.Children
+		} else {
+			children = opening.Moves
+		}
+		for i := range children {
+			child := &children[i]
+			next, err := chess.ParseFEN(child.FEN)
+			if err != nil {
+				continue
+			}
+			walk(opening, next, child, append(path[:len(path):len(path)], i), append(line[:len(line):len(line)], child.Move))
+		}
+	}
+
+	for _, opening := range openings {
+		if start, err := StartingPosition(opening.StartingFEN); err == nil {
+			walk(opening, start, nil, nil, []string{})
+		}
+	}
+
+	slices.SortStableFunc(keys, func(a, b string) int {
+		return positions[a].depth - positions[b].depth
+	})
+
+	for _, key := range keys {
+		if opts.MaxPositions > 0 && report.Positions >= opts.MaxPositions {
+			report.Incomplete = true
+			break
+		}
+
+		p := positions[key]
+		response, err := stats(ctx, p.pos.FEN())
+		if err != nil {
+			if report.Positions == 0 {
+				return report, err
+			}
+			report.Incomplete = true
+			break
+		}
+		report.Positions++
+
+		total := response.Games()
+		if total == 0 {
+			continue
+		}
+		prepared := tree.Moves(key)
+		for i := range response.Moves {
+			stat := &response.Moves[i]
+			games := stat.Games()
+			share := float64(games) / float64(total)
+			if games < opts.MinGames || share < opts.MinShare {
+				continue
+			}
+
+			m, err := p.pos.ParseMove(stat.SAN)
+			if err != nil {
+				if m, err = p.pos.ParseUCI(stat.UCI); err != nil {
+					continue
+				}
+			}
+			if containsMove(prepared, m.UCI()) {
+				continue
+			}
+			// A reply transposing into a prepared line is answered there
+			if next, err := p.pos.Apply(m); err == nil && len(tree.Moves(next.Key())) > 0 {
+				continue
+			}
+
+			report.Gaps = append(report.Gaps, models.CoverageGap{
+				FEN:           key,
+				Move:          p.pos.SAN(m),
+				UCI:           m.UCI(),
+				Games:         games,
+				Share:         share,
+				PositionGames: total,
+				Locations:     p.locations,
+			})
+		}
+	}
+
+	slices.SortStableFunc(report.Gaps, func(a, b models.CoverageGap) int {
+		switch {
+		case a.Games > b.Games:
+			return -1
+		case a.Games < b.Games:
+			return 1
+		}
+		return 0
+	})
+	return report, nil
+}
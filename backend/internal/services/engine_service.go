@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/uci"
+)
+
+const (
+	DefaultEngineDepth = 18
+	MaxEngineMultiPV   = 5
+)
+
+var (
+	ErrEngineUnavailable   = errors.New("engine not configured")
+	ErrInvalidEngineQuery  = errors.New("invalid engine query")
+	ErrEngineSearchFailure = errors.New("engine search failed")
+)
+
+// EngineQuery is one analysis request. Zero Depth and MoveTime analyze to
+// DefaultEngineDepth; both are capped by the service's limits.
+type EngineQuery struct {
+	FEN      string
+	Depth    int
+	MoveTime time.Duration
+	MultiPV  int
+}
+
+// EngineService runs analyses on a pool of UCI engine processes started
+// from the configured binary. Engines are started on demand up to the pool
+// size and reused; one that fails or misses a stop is replaced.
+type EngineService struct {
+	path        string
+	options     map[string]string
+	maxDepth    int
+	maxMoveTime time.Duration
+
+	slots  chan struct{}
+	mu     sync.Mutex
+	idle   []*uci.Engine
+	closed bool
+}
+
+// NewEngineService returns a service for the engine binary at path, or a
+// disabled one when path is empty.
+func NewEngineService(path string, poolSize, maxDepth int, maxMoveTime time.Duration, options map[string]string) *EngineService {
+	return &EngineService{
+		path:        path,
+		options:     options,
+		maxDepth:    maxDepth,
+		maxMoveTime: maxMoveTime,
+		slots:       make(chan struct{}, max(poolSize, 1)),
+	}
+}
+
+func (s *EngineService) Enabled() bool {
+	return s.path != ""
+}
+
+// Analyze searches the position, waiting for a free engine when all are
+// busy. Scores are converted to White's point of view and PVs to SAN.
+func (s *EngineService) Analyze(ctx context.Context, query EngineQuery) (*models.EngineAnalysis, error) {
+	if !s.Enabled() {
+		return nil, ErrEngineUnavailable
+	}
+
+	pos, err := StartingPosition(query.FEN)
+	if err != nil {
+		return nil, fmt.Errorf("%w: fen: %v", ErrInvalidEngineQuery, err)
+	}
+	if query.Depth < 0 || query.MoveTime < 0 {
+		return nil, fmt.Errorf("%w: negative limit", ErrInvalidEngineQuery)
+	}
+	if query.MultiPV > MaxEngineMultiPV {
+		return nil, fmt.Errorf("%w: multipv must be at most %d", ErrInvalidEngineQuery, MaxEngineMultiPV)
+	}
+
+	analysis := &models.EngineAnalysis{FEN: pos.FEN(), Lines: []models.EngineLine{}}
+	legal := len(pos.LegalMoves())
+	if legal == 0 {
+		return analysis, nil
+	}
+
+	search := uci.Search{
+		FEN:      pos.FEN(),
+		Depth:    query.Depth,
+		MoveTime: query.MoveTime,
+		MultiPV:  min(max(query.MultiPV, 1), legal),
+	}
+	if search.Depth == 0 && search.MoveTime == 0 {
+		search.Depth = DefaultEngineDepth
+	}
+	if s.maxDepth > 0 && (search.Depth == 0 || search.Depth > s.maxDepth) {
+		search.Depth = s.maxDepth
+	}
+	if s.maxMoveTime > 0 && (search.MoveTime == 0 || search.MoveTime > s.maxMoveTime) {
+		search.MoveTime = s.maxMoveTime
+	}
+
+	engine, err := s.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := engine.Analyze(ctx, search)
+	s.release(engine)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("%w: %v", ErrEngineSearchFailure, err)
+	}
+
+	analysis.BestMove = result.BestMove
+	for _, info := range result.Lines {
+		line := models.EngineLine{
+			MultiPV:   info.MultiPV,
+			Depth:     info.Depth,
+			ScoreType: info.ScoreType,
+			Score:     info.Score,
+			PV:        info.PV,
+			SAN:       sanLine(pos, info.PV),
+		}
+		if pos.Turn == chess.Black {
+			line.Score = -line.Score
+		}
+		analysis.Depth = max(analysis.Depth, line.Depth)
+		analysis.Lines = append(analysis.Lines, line)
+	}
+	return analysis, nil
+}
+
+// sanLine converts a PV to SAN, stopping at the first move that is not
+// legal so a confused engine cannot produce a wrong line.
+func sanLine(pos *chess.Position, pv []string) []string {
+	san := make([]string, 0, len(pv))
+	for _, move := range pv {
+		m, err := pos.ParseUCI(move)
+		if err != nil {
+			break
+		}
+		san = append(san, pos.SAN(m))
+		pos, _ = pos.Apply(m)
+	}
+	return san
+}
+
+func (s *EngineService) acquire(ctx context.Context) (*uci.Engine, error) {
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	s.mu.Lock()
+	if n := len(s.idle); n > 0 {
+		engine := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mu.Unlock()
+		return engine, nil
+	}
+	s.mu.Unlock()
+
+	engine, err := uci.Start(ctx, s.path, s.options)
+	if err != nil {
+		<-s.slots
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("%w: %v", ErrEngineSearchFailure, err)
+	}
+	return engine, nil
+}
+
+func (s *EngineService) release(engine *uci.Engine) {
+	defer func() { <-s.slots }()
+
+	s.mu.Lock()
+	if engine.Healthy() && !s.closed {
+		s.idle = append(s.idle, engine)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	if err := engine.Close(); err != nil {
+		log.Printf("Warning: Engine exited with error: %v", err)
+	}
+}
+
+// Close stops the idle engines. Engines busy with a search are stopped
+// when they are released.
+func (s *EngineService) Close() {
+	s.mu.Lock()
+	idle := s.idle
+	s.idle = nil
+	s.closed = true
+	s.mu.Unlock()
+
+	for _, engine := range idle {
+		engine.Close()
+	}
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var fakeUCI string
+
+// TestMain builds cmd/fakeuci, the stand-in engine the engine service
+// tests drive.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "fakeuci")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fakeUCI = filepath.Join(dir, "fakeuci")
+	build := exec.Command("go", "build", "-o", fakeUCI, "github.com/nagara/openings-master/backend/cmd/fakeuci")
+	if out, err := build.CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "building fakeuci: %v\n%s", err, out)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func newEngineService(t *testing.T, poolSize, maxDepth int, maxMoveTime time.Duration, options map[string]string) *EngineService {
+	t.Helper()
+	s := NewEngineService(fakeUCI, poolSize, maxDepth, maxMoveTime, options)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestEngineAnalyze(t *testing.T) {
+	s := newEngineService(t, 1, 0, 0, nil)
+
+	// Black to move wins the rook on h1; the score is reported for White
+	const fen = "4k2r/8/8/8/8/8/8/4K2R b - - 0 1"
+	analysis, err := s.Analyze(context.Background(), EngineQuery{FEN: fen, Depth: 3, MultiPV: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.BestMove != "h8h1" || analysis.Depth != 3 {
+		t.Errorf("best move %q at depth %d, want h8h1 at depth 3", analysis.BestMove, analysis.Depth)
+	}
+	if len(analysis.Lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(analysis.Lines))
+	}
+	if line := analysis.Lines[0]; line.Score != -500 || len(line.SAN) != 1 || line.SAN[0] != "Rxh1+" {
+		t.Errorf("first line %+v, want Rxh1+ scored -500", line)
+	}
+}
+
+func TestEngineAnalyzeLimits(t *testing.T) {
+	ctx := context.Background()
+
+	s := newEngineService(t, 1, 0, 0, nil)
+	analysis, err := s.Analyze(ctx, EngineQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.Depth != DefaultEngineDepth {
+		t.Errorf("searched to depth %d, want the default %d", analysis.Depth, DefaultEngineDepth)
+	}
+
+	// Kxg2 is the only legal move, so MultiPV is cut to one line
+	analysis, err = s.Analyze(ctx, EngineQuery{FEN: "7k/8/8/8/8/8/6q1/7K w - - 0 1", Depth: 1, MultiPV: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(analysis.Lines) != 1 {
+		t.Errorf("got %d lines, want 1", len(analysis.Lines))
+	}
+
+	capped := newEngineService(t, 1, 4, 200*time.Millisecond, nil)
+	analysis, err = capped.Analyze(ctx, EngineQuery{Depth: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.Depth != 4 {
+		t.Errorf("searched to depth %d, want it capped at 4", analysis.Depth)
+	}
+
+	timed := newEngineService(t, 1, 0, 200*time.Millisecond, nil)
+	started := time.Now()
+	if _, err := timed.Analyze(ctx, EngineQuery{MoveTime: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("search took %v, want it capped at 200ms", elapsed)
+	}
+
+	invalid := []EngineQuery{
+		{FEN: "not a fen"},
+		{Depth: -1},
+		{MoveTime: -time.Second},
+		{MultiPV: MaxEngineMultiPV + 1},
+	}
+	for _, query := range invalid {
+		if _, err := s.Analyze(ctx, query); !errors.Is(err, ErrInvalidEngineQuery) {
+			t.Errorf("%+v: got %v, want ErrInvalidEngineQuery", query, err)
+		}
+	}
+}
+
+func TestEngineAnalyzeCancel(t *testing.T) {
+	s := newEngineService(t, 1, 0, 0, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := s.Analyze(ctx, EngineQuery{MoveTime: time.Minute}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if len(s.idle) != 1 {
+		t.Fatalf("%d idle engines, want the stopped one back in the pool", len(s.idle))
+	}
+	stopped := s.idle[0]
+
+	if _, err := s.Analyze(context.Background(), EngineQuery{Depth: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.idle) != 1 || s.idle[0] != stopped {
+		t.Error("the stopped engine was not reused")
+	}
+}
+
+func TestEngineReplacesExitedEngine(t *testing.T) {
+	s := newEngineService(t, 1, 0, 0, map[string]string{"CrashDepth": "5"})
+	ctx := context.Background()
+
+	if _, err := s.Analyze(ctx, EngineQuery{Depth: 8}); !errors.Is(err, ErrEngineSearchFailure) {
+		t.Fatalf("got %v, want ErrEngineSearchFailure", err)
+	}
+	if len(s.idle) != 0 {
+		t.Fatalf("%d idle engines, want the exited one dropped", len(s.idle))
+	}
+
+	analysis, err := s.Analyze(ctx, EngineQuery{Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.Depth != 3 || len(s.idle) != 1 {
+		t.Errorf("replacement engine searched to depth %d with %d idle", analysis.Depth, len(s.idle))
+	}
+}
+
+func TestEnginePoolWaits(t *testing.T) {
+	s := newEngineService(t, 2, 0, 0, nil)
+
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Analyze(context.Background(), EngineQuery{MoveTime: 50 * time.Millisecond}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(s.idle) > 2 {
+		t.Errorf("%d idle engines, want at most the pool size", len(s.idle))
+	}
+}
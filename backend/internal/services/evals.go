@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+const (
+	MaxEvalPV = 32 // bounds the PV stored with an eval
+
+	// MaxClientEvalDepth bounds the depth a client may claim for an eval,
+	// so a made-up score cannot outrank every honest one
+	MaxClientEvalDepth = 60
+)
+
+// NormalizeEval keys an eval by its normalized position and checks that its
+// PV is legal there, cutting it to MaxEvalPV moves.
+func NormalizeEval(eval *models.PositionEval) error {
+	pos, err := chess.ParseFEN(eval.FEN)
+	if err != nil {
+		return fmt.Errorf("fen: %w", err)
+	}
+	eval.FEN = pos.Key()
+
+	if len(eval.PV) > MaxEvalPV {
+		eval.PV = eval.PV[:MaxEvalPV]
+	}
+	for _, move := range eval.PV {
+		m, err := pos.ParseUCI(move)
+		if err != nil {
+			return fmt.Errorf("pv: %w", err)
+		}
+		pos, _ = pos.Apply(m)
+	}
+	if eval.PV == nil {
+		eval.PV = []string{}
+	}
+	return nil
+}
+
+// DeepestEvals keeps the deepest eval of each position, in first-seen order.
+func DeepestEvals(evals []models.PositionEval) []models.PositionEval {
+	deepest := make([]models.PositionEval, 0, len(evals))
+	index := map[string]int{}
+	for _, eval := range evals {
+		i, ok := index[eval.FEN]
+		if !ok {
+			index[eval.FEN] = len(deepest)
+			deepest = append(deepest, eval)
+			continue
+		}
+		if eval.Depth > deepest[i].Depth {
+			deepest[i] = eval
+		}
+	}
+	return deepest
+}
+
+// EngineEval is the eval of an analysis' best line, keyed for the eval
+// store. It returns false when the analysis has no line.
+func EngineEval(analysis *models.EngineAnalysis) (models.PositionEval, bool) {
+	key, err := chess.NormalizeFEN(analysis.FEN)
+	if err != nil || len(analysis.Lines) == 0 {
+		return models.PositionEval{}, false
+	}
+	best := analysis.Lines[0]
+	return models.PositionEval{
+		FEN:       key,
+		Depth:     best.Depth,
+		ScoreType: best.ScoreType,
+		Score:     best.Score,
+		PV:        best.PV,
+		Source:    models.EvalSourceEngine,
+	}, true
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+const (
+	ExplorerSourceLichess = "lichess"
+	ExplorerSourceMasters = "masters"
+)
+
+var (
+	ErrInvalidExplorerQuery = errors.New("invalid explorer query")
+	ErrExplorerRateLimited  = errors.New("explorer is rate limited")
+	ErrExplorerUnavailable  = errors.New("explorer is unavailable")
+)
+
+var (
+	explorerRatings = []int{0, 1000, 1200, 1400, 1600, 1800, 2000, 2200, 2500}
+	explorerSpeeds  = []string{"ultraBullet", "bullet", "blitz", "rapid", "classical", "correspondence"}
+)
+
+const (
+	explorerTimeout    = 10 * time.Second
+	explorerMinBackoff = time.Minute // Lichess asks clients to wait a minute after a 429
+	explorerMaxBackoff = 15 * time.Minute
+)
+
+// ExplorerCache stores explorer replies between requests.
+type ExplorerCache interface {
+	Get(ctx context.Context, key string) (*models.ExplorerResponse, error)
+	Set(ctx context.Context, key string, response *models.ExplorerResponse, ttl time.Duration) error
+}
+
+// ExplorerQuery selects the games the explorer counts. Ratings and Speeds
+// only apply to the lichess source; empty means all of them.
+type ExplorerQuery struct {
+	Source  string
+	FEN     string
+	Ratings []int
+	Speeds  []string
+}
+
+// ExplorerService proxies the Lichess opening explorer. Replies are cached,
+// concurrent lookups of the same query share one upstream request, and
+// after a 429 no request is sent upstream until the backoff has passed.
+type ExplorerService struct {
+	baseURL string
+	token   string
+	client  *http.Client
+	cache   ExplorerCache
+	ttl     time.Duration
+
+	mu           sync.Mutex
+	calls        map[string]*explorerCall
+	backoff      time.Duration
+	backoffUntil time.Time
+}
+
+type explorerCall struct {
+	done     chan struct{}
+	response *models.ExplorerResponse
+	err      error
+}
+
+func NewExplorerService(baseURL, token string, cache ExplorerCache, ttl time.Duration) *ExplorerService {
+	return &ExplorerService{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: explorerTimeout},
+		cache:   cache,
+		ttl:     ttl,
+		calls:   map[string]*explorerCall{},
+	}
+}
+
+// ParseExplorerFilters reads the comma-separated ratings and speeds query
+// parameters.
+func ParseExplorerFilters(ratings, speeds string) ([]int, []string, error) {
+	var parsedRatings []int
+	for _, s := range strings.Split(ratings, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		rating, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: rating %q", ErrInvalidExplorerQuery, s)
+		}
+		parsedRatings = append(parsedRatings, rating)
+	}
+
+	var parsedSpeeds []string
+	for _, s := range strings.Split(speeds, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			parsedSpeeds = append(parsedSpeeds, s)
+		}
+	}
+	return parsedRatings, parsedSpeeds, nil
+}
+
+// RetryAfter reports how long the upstream backoff still lasts.
+func (s *ExplorerService) RetryAfter() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if wait := time.Until(s.backoffUntil); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Lookup returns the explorer's move statistics for the query.
+func (s *ExplorerService) Lookup(ctx context.Context, query ExplorerQuery) (*models.ExplorerResponse, error) {
+	query, err := normalizeExplorerQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	key := query.cacheKey()
+
+	if cached, err := s.cache.Get(ctx, key); err != nil {
+		log.Printf("Warning: Failed to read explorer cache: %v", err)
+	} else if cached != nil {
+		return cached, nil
+	}
+
+	s.mu.Lock()
+	call, ok := s.calls[key]
+	if !ok {
+		if time.Now().Before(s.backoffUntil) {
+			s.mu.Unlock()
+			return nil, ErrExplorerRateLimited
+		}
+		call = &explorerCall{done: make(chan struct{})}
+		s.calls[key] = call
+		// The request outlives the caller that started it, since other
+		// callers may be waiting on it
+		go s.run(context.WithoutCancel(ctx), key, query, call)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.response, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *ExplorerService) run(ctx context.Context, key string, query ExplorerQuery, call *explorerCall) {
+	ctx, cancel := context.WithTimeout(ctx, explorerTimeout)
+	defer cancel()
+
+	call.response, call.err = s.fetch(ctx, query)
+	if call.err == nil {
+		if err := s.cache.Set(ctx, key, call.response, s.ttl); err != nil {
+			log.Printf("Warning: Failed to write explorer cache: %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.calls, key)
+	s.mu.Unlock()
+	close(call.done)
+}
+
+func (s *ExplorerService) fetch(ctx context.Context, query ExplorerQuery) (*models.ExplorerResponse, error) {
+	params := url.Values{}
+	params.Set("fen", query.FEN)
+	params.Set("topGames", "0")
+	params.Set("recentGames", "0")
+	if len(query.Ratings) > 0 {
+		ratings := make([]string, len(query.Ratings))
+		for i, rating := range query.Ratings {
+			ratings[i] = strconv.Itoa(rating)
+		}
+		params.Set("ratings", strings.Join(ratings, ","))
+	}
+	if len(query.Speeds) > 0 {
+		params.Set("speeds", strings.Join(query.Speeds, ","))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/"+query.Source+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExplorerUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		s.backOff(resp.Header.Get("Retry-After"))
+		return nil, ErrExplorerRateLimited
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: upstream returned %s", ErrExplorerUnavailable, resp.Status)
+	}
+
+	var response models.ExplorerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExplorerUnavailable, err)
+	}
+	if response.Moves == nil {
+		response.Moves = []models.ExplorerMove{}
+	}
+
+	s.mu.Lock()
+	s.backoff = 0
+	s.mu.Unlock()
+	return &response, nil
+}
+
+// backOff stops upstream requests for the time the server asked for, or
+// for twice as long as the previous backoff when it did not say.
+func (s *ExplorerService) backOff(retryAfter string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.backoff = min(max(2*s.backoff, explorerMinBackoff), explorerMaxBackoff)
+	wait := s.backoff
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		wait = max(wait, time.Duration(seconds)*time.Second)
+	}
+	s.backoffUntil = time.Now().Add(wait)
+}
+
+// normalizeExplorerQuery validates the query and puts it in canonical form,
+// so that equivalent queries share a cache key.
+func normalizeExplorerQuery(query ExplorerQuery) (ExplorerQuery, error) {
+	if query.Source == "" {
+		query.Source = ExplorerSourceLichess
+	}
+	if query.Source != ExplorerSourceLichess && query.Source != ExplorerSourceMasters {
+		return query, fmt.Errorf("%w: unknown source %q", ErrInvalidExplorerQuery, query.Source)
+	}
+
+	pos, err := StartingPosition(query.FEN)
+	if err != nil {
+		return query, fmt.Errorf("%w: %v", ErrInvalidExplorerQuery, err)
+	}
+	// Lichess ignores the move counters, so positions only differing in
+	// them share a cache entry
+	query.FEN = pos.Key() + " 0 1"
+
+	if query.Source == ExplorerSourceMasters {
+		query.Ratings, query.Speeds = nil, nil
+		return query, nil
+	}
+
+	ratings := make([]int, 0, len(query.Ratings))
+	for _, rating := range query.Ratings {
+		if !slices.Contains(explorerRatings, rating) {
+			return query, fmt.Errorf("%w: rating %d is not one of %v", ErrInvalidExplorerQuery, rating, explorerRatings)
+		}
+		if !slices.Contains(ratings, rating) {
+			ratings = append(ratings, rating)
+		}
+	}
+	slices.Sort(ratings)
+	query.Ratings = ratings
+
+	speeds := make([]string, 0, len(query.Speeds))
+	for _, speed := range query.Speeds {
+		if !slices.Contains(explorerSpeeds, speed) {
+			return query, fmt.Errorf("%w: unknown speed %q", ErrInvalidExplorerQuery, speed)
+		}
+		if !slices.Contains(speeds, speed) {
+			speeds = append(speeds, speed)
+		}
+	}
+	slices.SortFunc(speeds, func(a, b string) int {
+		return slices.Index(explorerSpeeds, a) - slices.Index(explorerSpeeds, b)
+	})
+	query.Speeds = speeds
+	return query, nil
+}
+
+func (q ExplorerQuery) cacheKey() string {
+	ratings := make([]string, len(q.Ratings))
+	for i, rating := range q.Ratings {
+		ratings[i] = strconv.Itoa(rating)
+	}
+	return q.Source + "|" + q.FEN + "|" + strings.Join(ratings, ",") + "|" + strings.Join(q.Speeds, ",")
+}
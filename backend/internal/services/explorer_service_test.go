@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+// memoryCache is an ExplorerCache backed by a map. With store unset it
+// never keeps anything, so every lookup reaches the upstream server.
+type memoryCache struct {
+	mu      sync.Mutex
+	store   bool
+	entries map[string]*models.ExplorerResponse
+}
+
+func newMemoryCache(store bool) *memoryCache {
+	return &memoryCache{store: store, entries: map[string]*models.ExplorerResponse{}}
+}
+
+func (c *memoryCache) Get(_ context.Context, key string) (*models.ExplorerResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[key], nil
+}
+
+func (c *memoryCache) Set(_ context.Context, key string, response *models.ExplorerResponse, _ time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.store {
+		c.entries[key] = response
+	}
+	return nil
+}
+
+// explorerServer fakes the Lichess explorer. Each request is answered by
+// reply, which defaults to an empty 200.
+type explorerServer struct {
+	*httptest.Server
+	hits    atomic.Int32
+	queries chan url.Values
+	reply   func(w http.ResponseWriter)
+}
+
+func newExplorerServer(t *testing.T) *explorerServer {
+	s := &explorerServer{queries: make(chan url.Values, 16)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		s.queries <- r.URL.Query()
+		if s.reply != nil {
+			s.reply(w)
+			return
+		}
+		w.Write([]byte(`{"white":1,"draws":2,"black":3,"moves":[]}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestExplorerCache(t *testing.T) {
+	server := newExplorerServer(t)
+	cache := newMemoryCache(true)
+	s := NewExplorerService(server.URL, "", cache, time.Hour)
+	ctx := context.Background()
+
+	first, err := s.Lookup(ctx, ExplorerQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Games() != 6 {
+		t.Errorf("got %d games, want 6", first.Games())
+	}
+	second, err := s.Lookup(ctx, ExplorerQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != first {
+		t.Error("second lookup did not come from the cache")
+	}
+	if hits := server.hits.Load(); hits != 1 {
+		t.Errorf("upstream hit %d times, want 1", hits)
+	}
+	if len(cache.entries) != 1 {
+		t.Errorf("cache holds %d entries, want 1", len(cache.entries))
+	}
+}
+
+func TestExplorerCoalescesConcurrentLookups(t *testing.T) {
+	server := newExplorerServer(t)
+	release := make(chan struct{})
+	server.reply = func(w http.ResponseWriter) {
+		<-release
+		w.Write([]byte(`{"white":1,"draws":0,"black":0,"moves":[]}`))
+	}
+	s := NewExplorerService(server.URL, "", newMemoryCache(false), time.Hour)
+
+	const callers = 8
+	responses := make([]*models.ExplorerResponse, callers)
+	var started, done sync.WaitGroup
+	started.Add(callers)
+	done.Add(callers)
+	for i := range callers {
+		go func() {
+			defer done.Done()
+			started.Done()
+			response, err := s.Lookup(context.Background(), ExplorerQuery{})
+			if err != nil {
+				t.Error(err)
+			}
+			responses[i] = response
+		}()
+	}
+	started.Wait()
+	<-server.queries
+	// Give the callers that have not reached the service yet time to join
+	// the pending request
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	done.Wait()
+
+	if hits := server.hits.Load(); hits != 1 {
+		t.Errorf("upstream hit %d times, want 1", hits)
+	}
+	for i, response := range responses {
+		if response != responses[0] {
+			t.Errorf("caller %d got a different response", i)
+		}
+	}
+}
+
+func TestExplorerRateLimit(t *testing.T) {
+	server := newExplorerServer(t)
+	var mu sync.Mutex
+	status, retryAfter := http.StatusTooManyRequests, ""
+	respond := func(code int, wait string) {
+		mu.Lock()
+		status, retryAfter = code, wait
+		mu.Unlock()
+	}
+	server.reply = func(w http.ResponseWriter) {
+		mu.Lock()
+		defer mu.Unlock()
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"moves":[]}`))
+		}
+	}
+	s := NewExplorerService(server.URL, "", newMemoryCache(false), time.Hour)
+	ctx := context.Background()
+	lookup := func() error {
+		_, err := s.Lookup(ctx, ExplorerQuery{})
+		return err
+	}
+	// expire ends the current backoff without forgetting its length
+	expire := func() {
+		s.mu.Lock()
+		s.backoffUntil = time.Time{}
+		s.mu.Unlock()
+	}
+
+	if err := lookup(); !errors.Is(err, ErrExplorerRateLimited) {
+		t.Fatalf("got %v, want ErrExplorerRateLimited", err)
+	}
+	if wait := s.RetryAfter(); wait <= 59*time.Second || wait > explorerMinBackoff {
+		t.Errorf("backing off for %v, want %v", wait, explorerMinBackoff)
+	}
+
+	// Nothing is sent upstream until the backoff has passed
+	if err := lookup(); !errors.Is(err, ErrExplorerRateLimited) {
+		t.Fatalf("got %v, want ErrExplorerRateLimited", err)
+	}
+	if hits := server.hits.Load(); hits != 1 {
+		t.Errorf("upstream hit %d times during the backoff, want 1", hits)
+	}
+
+	expire()
+	lookup()
+	if s.backoff != 2*explorerMinBackoff {
+		t.Errorf("second backoff is %v, want %v", s.backoff, 2*explorerMinBackoff)
+	}
+
+	expire()
+	respond(http.StatusTooManyRequests, "600")
+	lookup()
+	if wait := s.RetryAfter(); wait <= 9*time.Minute {
+		t.Errorf("backing off for %v, want the 10m the server asked for", wait)
+	}
+
+	for range 4 {
+		expire()
+		respond(http.StatusTooManyRequests, "")
+		lookup()
+	}
+	if s.backoff != explorerMaxBackoff {
+		t.Errorf("backoff grew to %v, want it capped at %v", s.backoff, explorerMaxBackoff)
+	}
+
+	expire()
+	respond(http.StatusOK, "")
+	if err := lookup(); err != nil {
+		t.Fatal(err)
+	}
+	if s.backoff != 0 || s.RetryAfter() != 0 {
+		t.Errorf("backoff is %v after a success, want it reset", s.backoff)
+	}
+}
+
+func TestExplorerQueryCanonicalization(t *testing.T) {
+	server := newExplorerServer(t)
+	s := NewExplorerService(server.URL, "", newMemoryCache(true), time.Hour)
+	ctx := context.Background()
+
+	const fen = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"
+	queries := []ExplorerQuery{
+		{FEN: fen, Ratings: []int{2000, 1600}, Speeds: []string{"rapid", "blitz"}},
+		{Source: ExplorerSourceLichess, FEN: "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 3 9", Ratings: []int{1600, 2000, 1600}, Speeds: []string{"blitz", "rapid"}},
+	}
+	for _, query := range queries {
+		if _, err := s.Lookup(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits := server.hits.Load(); hits != 1 {
+		t.Fatalf("upstream hit %d times, want 1", hits)
+	}
+	params := <-server.queries
+	if got := params.Get("ratings"); got != "1600,2000" {
+		t.Errorf("ratings sent as %q, want 1600,2000", got)
+	}
+	if got := params.Get("speeds"); got != "blitz,rapid" {
+		t.Errorf("speeds sent as %q, want blitz,rapid", got)
+	}
+
+	// The masters database has no rating or speed filters
+	masters := []ExplorerQuery{
+		{Source: ExplorerSourceMasters, FEN: fen},
+		{Source: ExplorerSourceMasters, FEN: fen, Ratings: []int{2500}, Speeds: []string{"classical"}},
+	}
+	for _, query := range masters {
+		if _, err := s.Lookup(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits := server.hits.Load(); hits != 2 {
+		t.Errorf("upstream hit %d times, want 2", hits)
+	}
+
+	invalid := []ExplorerQuery{
+		{Source: "chess.com"},
+		{Ratings: []int{1500}},
+		{Speeds: []string{"daily"}},
+		{FEN: "not a fen"},
+	}
+	for _, query := range invalid {
+		if _, err := s.Lookup(ctx, query); !errors.Is(err, ErrInvalidExplorerQuery) {
+			t.Errorf("%+v: got %v, want ErrInvalidExplorerQuery", query, err)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/pgn"
+)
+
+// GamesFromPGN parses the user's own games from PGN text, keeping the main
+// line of each. The user's side is color when given, otherwise the side
+// whose player name matches username. Games that fail to parse, contain
+// illegal moves or do not say which side the user played are returned as
+// errors without affecting the others.
+func GamesFromPGN(text, color, username string) ([]models.Game, []models.ImportGameError) {
+	parsed, parseErrs := pgn.Parse(text)
+
+	games := []models.Game{}
+	importErrs := []models.ImportGameError{}
+	for _, err := range parseErrs {
+		importErrs = append(importErrs, toImportError(err))
+	}
+
+	for _, g := range parsed {
+		game, err := gameFromPGN(g, color, username)
+		if err != nil {
+			err.Game = g.Number
+			importErrs = append(importErrs, toImportError(err))
+			continue
+		}
+		games = append(games, game)
+	}
+
+	sort.SliceStable(importErrs, func(i, j int) bool {
+		return importErrs[i].Game < importErrs[j].Game
+	})
+	return games, importErrs
+}
+
+func gameFromPGN(g *pgn.Game, color, username string) (models.Game, *pgn.Error) {
+	game := models.Game{
+		White:  g.TagValue("White"),
+		Black:  g.TagValue("Black"),
+		Event:  g.TagValue("Event"),
+		Site:   g.TagValue("Site"),
+		Date:   g.TagValue("Date"),
+		Result: g.Result,
+		ECO:    g.TagValue("ECO"),
+		Moves:  []string{},
+	}
+	if game.Result == "" {
+		game.Result = "*"
+	}
+
+	game.UserColor = color
+	if game.UserColor == "" {
+		white := strings.EqualFold(game.White, username)
+		black := strings.EqualFold(game.Black, username)
+		switch {
+		case white && !black:
+			game.UserColor = "white"
+		case black && !white:
+			game.UserColor = "black"
+		default:
+			return game, &pgn.Error{Line: g.Line, Column: g.Column, Message: "cannot tell which side you played, set the color"}
+		}
+	}
+
+	pos := chess.NewPosition()
+	if fen := g.TagValue("FEN"); fen != "" && g.TagValue("SetUp") != "0" {
+		var err error
+		pos, err = chess.ParseFEN(fen)
+		if err != nil {
+			return game, &pgn.Error{Line: g.Line, Column: g.Column, Message: "FEN header: " + err.Error()}
+		}
+	}
+	game.StartingFEN = pos.FEN()
+
+	if len(g.Moves) == 0 {
+		return game, &pgn.Error{Line: g.Line, Column: g.Column, Message: "game has no moves"}
+	}
+	for node := g.Moves[0]; node != nil; {
+		m, err := pos.ParseSAN(node.SAN)
+		if err != nil {
+			return game, &pgn.Error{Line: node.Line, Column: node.Column, Message: err.Error()}
+		}
+		game.Moves = append(game.Moves, pos.SAN(m))
+		pos, _ = pos.Apply(m)
+
+		if len(node.Children) == 0 {
+			break
+		}
+		node = node.Children[0]
+	}
+
+	game.Fingerprint = gameFingerprint(&game, g.TagValue("Round"))
+	return game, nil
+}
+
+// gameFingerprint hashes what identifies a game, so importing the same PGN
+// twice does not store its games twice.
+func gameFingerprint(game *models.Game, round string) string {
+	h := sha256.New()
+	for _, field := range []string{game.White, game.Black, game.Event, game.Site, game.Date, round, game.Result, game.StartingFEN} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte(strings.Join(game.Moves, " ")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// RepertoireBook indexes the positions of a player's repertoires of one
+// color, to replay games against them.
+type RepertoireBook map[string][]models.PositionEntry
+
+func NewRepertoireBook(repertoires []models.Repertoire, color string) RepertoireBook {
+	book := RepertoireBook{}
+	for i := range repertoires {
+		if repertoires[i].Color != color {
+			continue
+		}
+		for _, entry := range IndexPositions(&repertoires[i]) {
+			book[entry.FEN] = append(book[entry.FEN], entry)
+		}
+	}
+	return book
+}
+
+// ReplayGame follows the game through the book and records where it left
+// the prepared lines. Positions are matched by key, so a game reaching a
+// prepared line by another move order still counts as following it.
+func ReplayGame(book RepertoireBook, game *models.Game) {
+	game.Status = models.GameStatusFollowed
+	game.Deviation = nil
+	game.AnalyzedAt = time.Now()
+
+	pos, err := StartingPosition(game.StartingFEN)
+	if err != nil || len(book[pos.Key()]) == 0 {
+		game.Status = models.GameStatusNoRepertoire
+		return
+	}
+	userColor, _ := chess.ParseColor(game.UserColor)
+
+	var current *models.PositionEntry
+	for i, san := range game.Moves {
+		key := pos.Key()
+		entries := book[key]
+
+		var prepared []string
+		for _, entry := range entries {
+			for _, reply := range entry.Replies {
+				prepared = appendUnique(prepared, reply)
+			}
+		}
+		// A position missing from the book, as below a node without a
+		// FEN, ends the line at the last position that was in it
+		if len(entries) > 0 {
+			current = pickEntry(entries, current, san)
+		}
+
+		if !slices.Contains(prepared, san) {
+			game.Status = models.GameStatusDeviated
+			if len(prepared) == 0 {
+				game.Status = models.GameStatusLineEnded
+				prepared = []string{}
+			}
+			deviatedBy := "opponent"
+			if pos.Turn == userColor {
+				deviatedBy = "user"
+			}
+			game.Deviation = &models.GameDeviation{
+				RepertoireID:  current.RepertoireID,
+				OpeningID:     current.OpeningID,
+				OpeningName:   current.OpeningName,
+				NodeID:        current.NodeID,
+				Path:          current.Path,
+				Ply:           i + 1,
+				FEN:           key,
+				Move:          san,
+				DeviatedBy:    deviatedBy,
+				PreparedMoves: prepared,
+			}
+			return
+		}
+
+		m, err := pos.ParseSAN(san)
+		if err != nil {
+			return
+		}
+		pos, _ = pos.Apply(m)
+	}
+}
+
+// pickEntry chooses which occurrence of a position to attribute the game
+// to: preferably one in the opening followed so far that prepares the move
+// played, then any that prepares it, then one in the same opening.
+func pickEntry(entries []models.PositionEntry, current *models.PositionEntry, san string) *models.PositionEntry {
+	var sameOpening, preparing *models.PositionEntry
+	for i := range entries {
+		entry := &entries[i]
+		same := current != nil && entry.OpeningID == current.OpeningID
+		prepares := slices.Contains(entry.Replies, san)
+		if same && prepares {
+			return entry
+		}
+		if prepares && preparing == nil {
+			preparing = entry
+		}
+		if same && sameOpening == nil {
+			sameOpening = entry
+		}
+	}
+	switch {
+	case preparing != nil:
+		return preparing
+	case sameOpening != nil:
+		return sameOpening
+	}
+	return &entries[0]
+}
+
+// SummarizeGames aggregates the deviations of replayed games per opening.
+func SummarizeGames(games []models.Game) models.GameReport {
+	report := models.GameReport{Games: len(games), Openings: []models.OpeningDeviation{}}
+	byOpening := map[string]int{}
+
+	for i := range games {
+		game := &games[i]
+		switch game.Status {
+		case models.GameStatusFollowed:
+			report.Followed++
+			continue
+		case models.GameStatusNoRepertoire:
+			report.NoRepertoire++
+			continue
+		}
+		d := game.Deviation
+		if d == nil {
+			continue
+		}
+
+		openingKey := d.RepertoireID.Hex() + d.OpeningID.Hex()
+		idx, ok := byOpening[openingKey]
+		if !ok {
+			idx = len(report.Openings)
+			byOpening[openingKey] = idx
+			report.Openings = append(report.Openings, models.OpeningDeviation{
+				RepertoireID: d.RepertoireID,
+				OpeningID:    d.OpeningID,
+				OpeningName:  d.OpeningName,
+				Deviations:   []models.DeviationCount{},
+			})
+		}
+		opening := &report.Openings[idx]
+		opening.Games++
+		switch {
+		case game.Status == models.GameStatusLineEnded:
+			opening.LineEnded++
+		case d.DeviatedBy == "user":
+			opening.ByUser++
+		default:
+			opening.ByOpponent++
+		}
+
+		j := slices.IndexFunc(opening.Deviations, func(c models.DeviationCount) bool {
+			return c.FEN == d.FEN && c.Move == d.Move
+		})
+		if j < 0 {
+			j = len(opening.Deviations)
+			opening.Deviations = append(opening.Deviations, models.DeviationCount{
+				FEN:           d.FEN,
+				Path:          d.Path,
+				Ply:           d.Ply,
+				Move:          d.Move,
+				DeviatedBy:    d.DeviatedBy,
+				Status:        game.Status,
+				PreparedMoves: d.PreparedMoves,
+			})
+		}
+		opening.Deviations[j].Count++
+		opening.Deviations[j].GameIDs = append(opening.Deviations[j].GameIDs, game.ID)
+	}
+
+	for i := range report.Openings {
+		slices.SortStableFunc(report.Openings[i].Deviations, func(a, b models.DeviationCount) int {
+			return b.Count - a.Count
+		})
+	}
+	slices.SortStableFunc(report.Openings, func(a, b models.OpeningDeviation) int {
+		return b.Games - a.Games
+	})
+	return report
+}
+
+// GameMoveStats counts the moves played in each position of the games, in
+// the explorer's format, so imported games can stand in for the explorer.
+func GameMoveStats(games []models.Game) MoveStatsFunc {
+	stats := map[string]*models.ExplorerResponse{}
+
+	for i := range games {
+		game := &games[i]
+		pos, err := StartingPosition(game.StartingFEN)
+		if err != nil {
+			continue
+		}
+		for _, san := range game.Moves {
+			m, err := pos.ParseSAN(san)
+			if err != nil {
+				break
+			}
+			key := pos.Key()
+			response, ok := stats[key]
+			if !ok {
+				response = &models.ExplorerResponse{Moves: []models.ExplorerMove{}}
+				stats[key] = response
+			}
+			j := slices.IndexFunc(response.Moves, func(move models.ExplorerMove) bool {
+				return move.UCI == m.UCI()
+			})
+			if j < 0 {
+				j = len(response.Moves)
+				response.Moves = append(response.Moves, models.ExplorerMove{UCI: m.UCI(), SAN: san})
+			}
+			switch game.Result {
+			case "1-0":
+				response.White++
+				response.Moves[j].White++
+			case "0-1":
+				response.Black++
+				response.Moves[j].Black++
+			default:
+				response.Draws++
+				response.Moves[j].Draws++
+			}
+			pos, _ = pos.Apply(m)
+		}
+	}
+
+	return func(ctx context.Context, fen string) (*models.ExplorerResponse, error) {
+		key, err := chess.NormalizeFEN(fen)
+		if err != nil {
+			return nil, err
+		}
+		if response, ok := stats[key]; ok {
+			return response, nil
+		}
+		return &models.ExplorerResponse{Moves: []models.ExplorerMove{}}, nil
+	}
+}
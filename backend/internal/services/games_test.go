@@ -0,0 +1,77 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func replay(t *testing.T, repertoire models.Repertoire, userColor string, moves ...string) *models.Game {
+	t.Helper()
+	book := NewRepertoireBook([]models.Repertoire{repertoire}, repertoire.Color)
+	game := &models.Game{UserColor: userColor, Moves: moves}
+	ReplayGame(book, game)
+	return game
+}
+
+func TestReplayGameBelowNodeWithoutFEN(t *testing.T) {
+	openingID := primitive.NewObjectID()
+	repertoire := models.Repertoire{
+		ID:    primitive.NewObjectID(),
+		Color: "white",
+		Openings: []models.Opening{{
+			ID:    openingID,
+			Name:  "King's Pawn",
+			Moves: []models.MoveNode{{Move: "e4", UCI: "e2e4", IsMainLine: true}},
+		}},
+	}
+
+	game := replay(t, repertoire, "white", "e4", "e5")
+	if game.Status != models.GameStatusLineEnded {
+		t.Fatalf("status %q, want %q", game.Status, models.GameStatusLineEnded)
+	}
+	d := game.Deviation
+	if d == nil || d.OpeningID != openingID || d.Ply != 2 || d.Move != "e5" || d.DeviatedBy != "opponent" {
+		t.Errorf("deviation %+v, want the opponent's e5 at ply 2 in the opening", d)
+	}
+}
+
+func TestReplayGameDeviation(t *testing.T) {
+	opening := models.Opening{
+		ID:   primitive.NewObjectID(),
+		Name: "Italian",
+		Moves: []models.MoveNode{{Move: "e4", IsMainLine: true, Children: []models.MoveNode{
+			{Move: "e5", IsMainLine: true, Children: []models.MoveNode{{Move: "Nf3", IsMainLine: true}}},
+		}}},
+	}
+	if err := NormalizeOpening(&opening); err != nil {
+		t.Fatal(err)
+	}
+	repertoire := models.Repertoire{ID: primitive.NewObjectID(), Color: "white", Openings: []models.Opening{opening}}
+
+	tests := []struct {
+		moves      []string
+		status     string
+		deviatedBy string
+		ply        int
+	}{
+		{[]string{"e4", "e5"}, models.GameStatusFollowed, "", 0},
+		{[]string{"e4", "c5"}, models.GameStatusDeviated, "opponent", 2},
+		{[]string{"e4", "e5", "Bc4"}, models.GameStatusDeviated, "user", 3},
+		{[]string{"e4", "e5", "Nf3", "Nc6"}, models.GameStatusLineEnded, "opponent", 4},
+	}
+	for _, tt := range tests {
+		game := replay(t, repertoire, "white", tt.moves...)
+		if game.Status != tt.status {
+			t.Errorf("%v: status %q, want %q", tt.moves, game.Status, tt.status)
+			continue
+		}
+		if tt.ply == 0 {
+			continue
+		}
+		if d := game.Deviation; d == nil || d.Ply != tt.ply || d.DeviatedBy != tt.deviatedBy {
+			t.Errorf("%v: deviation %+v, want ply %d by %s", tt.moves, d, tt.ply, tt.deviatedBy)
+		}
+	}
+}
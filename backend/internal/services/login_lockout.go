@@ -0,0 +1,101 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+// Kinds of login failure counters. The mail kinds count requests for
+// account mail instead, which lock out once there are too many.
+const (
+	LockoutAccount     = "account"
+	LockoutIP          = "ip"
+	LockoutMailAccount = "mail_account"
+	LockoutMailIP      = "mail_ip"
+)
+
+// LockoutPolicy decides when failed logins lock a counter out, and for
+// how long.
+type LockoutPolicy struct {
+	MaxFailures int // failures within Window that trigger a lockout
+	Window      time.Duration
+	BaseLockout time.Duration // length of the first lockout, doubled for each next one
+	MaxLockout  time.Duration
+}
+
+// LoginLockout holds the policies of account and IP counters.
+type LoginLockout struct {
+	Account     LockoutPolicy
+	IP          LockoutPolicy
+	MailAccount LockoutPolicy
+	MailIP      LockoutPolicy
+}
+
+func (l *LoginLockout) Policy(kind string) LockoutPolicy {
+	switch kind {
+	case LockoutIP:
+		return l.IP
+	case LockoutMailAccount:
+		return l.MailAccount
+	case LockoutMailIP:
+		return l.MailIP
+	}
+	return l.Account
+}
+
+// LoginAttemptKey names the counter of an account, by email, or of a
+// client IP.
+func LoginAttemptKey(kind, value string) string {
+	if kind == LockoutAccount || kind == LockoutMailAccount {
+		value = strings.ToLower(strings.TrimSpace(value))
+	}
+	return kind + ":" + value
+}
+
+// Fail counts one more failed login at now, locking the counter out when
+// it reaches the limit. It reports whether it did.
+func (p LockoutPolicy) Fail(attempt *models.LoginAttempt, now time.Time) bool {
+	if now.Sub(attempt.WindowStart) > p.Window {
+		attempt.Failures = 0
+		attempt.WindowStart = now
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = now
+
+	locked := p.MaxFailures > 0 && attempt.Failures >= p.MaxFailures
+	if locked {
+		attempt.Lockouts++
+		until := now.Add(p.lockoutLength(attempt.Lockouts))
+		attempt.LockedUntil = &until
+		attempt.Failures = 0
+		attempt.WindowStart = now
+	}
+
+	// Lockouts are remembered, and keep doubling, until the counter has
+	// been quiet for as long as the longest lockout
+	end := now.Add(p.Window)
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(end) {
+		end = *attempt.LockedUntil
+	}
+	attempt.ExpiresAt = end.Add(p.MaxLockout)
+	return locked
+}
+
+func (p LockoutPolicy) lockoutLength(lockouts int) time.Duration {
+	length := p.BaseLockout
+	for i := 1; i < lockouts && length < p.MaxLockout; i++ {
+		length *= 2
+	}
+	return min(length, p.MaxLockout)
+}
+
+// LockRemaining returns how long the counter stays locked out after now,
+// or zero.
+func LockRemaining(attempt *models.LoginAttempt, now time.Time) time.Duration {
+	if attempt.LockedUntil == nil || !attempt.LockedUntil.After(now) {
+		return 0
+	}
+	return attempt.LockedUntil.Sub(now)
+}
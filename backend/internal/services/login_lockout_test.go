@@ -0,0 +1,56 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+func TestLockoutPolicyFail(t *testing.T) {
+	policy := LockoutPolicy{MaxFailures: 3, Window: time.Hour, BaseLockout: time.Hour, MaxLockout: 3 * time.Hour}
+	attempt := &models.LoginAttempt{}
+	now := time.Now()
+
+	for i := 1; i <= 2; i++ {
+		if policy.Fail(attempt, now) {
+			t.Fatalf("locked out after %d failures, want 3", i)
+		}
+	}
+	if !policy.Fail(attempt, now) || LockRemaining(attempt, now) != time.Hour {
+		t.Fatalf("third failure locked out for %v, want 1h", LockRemaining(attempt, now))
+	}
+
+	// Each next lockout doubles, up to the maximum
+	for _, want := range []time.Duration{2 * time.Hour, 3 * time.Hour} {
+		now = attempt.LockedUntil.Add(time.Second)
+		for range 3 {
+			policy.Fail(attempt, now)
+		}
+		if got := LockRemaining(attempt, now); got != want {
+			t.Errorf("locked out for %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLoginLockoutPolicy(t *testing.T) {
+	lockout := &LoginLockout{
+		Account:     LockoutPolicy{MaxFailures: 1},
+		IP:          LockoutPolicy{MaxFailures: 2},
+		MailAccount: LockoutPolicy{MaxFailures: 3},
+		MailIP:      LockoutPolicy{MaxFailures: 4},
+	}
+	kinds := []string{LockoutAccount, LockoutIP, LockoutMailAccount, LockoutMailIP}
+	for i, kind := range kinds {
+		if got := lockout.Policy(kind).MaxFailures; got != i+1 {
+			t.Errorf("%s: got the policy allowing %d failures, want %d", kind, got, i+1)
+		}
+	}
+
+	if a, b := LoginAttemptKey(LockoutMailAccount, " Me@Example.com"), LoginAttemptKey(LockoutMailAccount, "me@example.com"); a != b {
+		t.Errorf("mail keys %q and %q differ by case", a, b)
+	}
+	if a, b := LoginAttemptKey(LockoutAccount, "me@example.com"), LoginAttemptKey(LockoutMailAccount, "me@example.com"); a == b {
+		t.Error("login and mail counters share a key")
+	}
+}
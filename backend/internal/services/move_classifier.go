@@ -0,0 +1,98 @@
+package services
+
+import (
+	"math"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+// evalCap bounds evaluations before comparing them: past a thousand
+// centipawns, or a mate score, the position is won either way.
+const evalCap = 1000
+
+// MoveThresholds are the largest centipawn losses still judged best, good,
+// an inaccuracy and a mistake; a larger loss is a blunder.
+type MoveThresholds struct {
+	Best       int
+	Good       int
+	Inaccuracy int
+	Mistake    int
+}
+
+// MoveJudgement is the verdict on a move from the evaluations around it.
+type MoveJudgement struct {
+	Category      string
+	CentipawnLoss int
+	Accuracy      float64
+}
+
+type MoveClassifier struct {
+	thresholds MoveThresholds
+}
+
+func NewMoveClassifier(thresholds MoveThresholds) *MoveClassifier {
+	return &MoveClassifier{thresholds: thresholds}
+}
+
+// Classify judges a move by mover from the evaluations before and after it,
+// in centipawns from White's point of view.
+func (c *MoveClassifier) Classify(mover chess.Color, evalBefore, evalAfter int) MoveJudgement {
+	before, after := clampEval(evalBefore), clampEval(evalAfter)
+	if mover == chess.Black {
+		before, after = -before, -after
+	}
+
+	loss := max(before-after, 0)
+	judgement := MoveJudgement{
+		CentipawnLoss: loss,
+		Accuracy:      MoveAccuracy(WinPercent(before), WinPercent(after)),
+	}
+	switch {
+	case loss <= c.thresholds.Best:
+		judgement.Category = models.MoveCategoryBest
+	case loss <= c.thresholds.Good:
+		judgement.Category = models.MoveCategoryGood
+	case loss <= c.thresholds.Inaccuracy:
+		judgement.Category = models.MoveCategoryInaccuracy
+	case loss <= c.thresholds.Mistake:
+		judgement.Category = models.MoveCategoryMistake
+	default:
+		judgement.Category = models.MoveCategoryBlunder
+	}
+	return judgement
+}
+
+// WinPercent converts an evaluation in centipawns, from the player's point of
+// view, into their chance of winning, using the curve Lichess fitted to its
+// games.
+func WinPercent(cp int) float64 {
+	return 50 + 50*(2/(1+math.Exp(-0.00368208*float64(clampEval(cp))))-1)
+}
+
+// MoveAccuracy scores a move from 0 to 100 by how much of the player's
+// winning chances it gave away, as Lichess does.
+func MoveAccuracy(winBefore, winAfter float64) float64 {
+	if winAfter >= winBefore {
+		return 100
+	}
+	accuracy := 103.1668*math.Exp(-0.04354*(winBefore-winAfter)) - 3.1669 + 1
+	return math.Max(0, math.Min(100, accuracy))
+}
+
+// EngineEvals keys the evals from the server's engine among evals by
+// position. Submitted evals are left out, since whoever submits them could
+// make their own moves look best.
+func EngineEvals(evals []models.PositionEval) map[string]models.PositionEval {
+	byFEN := make(map[string]models.PositionEval, len(evals))
+	for _, eval := range evals {
+		if eval.Source == models.EvalSourceEngine {
+			byFEN[eval.FEN] = eval
+		}
+	}
+	return byFEN
+}
+
+func clampEval(cp int) int {
+	return max(-evalCap, min(cp, evalCap))
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+func TestWinPercent(t *testing.T) {
+	tests := []struct {
+		cp   int
+		want float64
+	}{
+		{0, 50},
+		{100, 59.1},
+		{-100, 40.9},
+		{300, 75.1},
+		{evalCap, 97.5},
+		{-evalCap, 2.5},
+		{mateScore, 97.5},
+		{-mateScore, 2.5},
+	}
+	for _, tt := range tests {
+		if got := WinPercent(tt.cp); math.Abs(got-tt.want) > 0.05 {
+			t.Errorf("WinPercent(%d) = %.2f, want %.1f", tt.cp, got, tt.want)
+		}
+	}
+}
+
+func TestMoveAccuracy(t *testing.T) {
+	tests := []struct {
+		before, after float64
+		want          float64
+	}{
+		{50, 50, 100},
+		{40, 60, 100},
+		{60, 55, 80.8},
+		{60, 40, 41.0},
+		{95, 5, 0},
+	}
+	for _, tt := range tests {
+		if got := MoveAccuracy(tt.before, tt.after); math.Abs(got-tt.want) > 0.05 {
+			t.Errorf("MoveAccuracy(%v, %v) = %.2f, want %.1f", tt.before, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	classifier := NewMoveClassifier(MoveThresholds{Best: 10, Good: 50, Inaccuracy: 100, Mistake: 300})
+
+	tests := []struct {
+		name          string
+		mover         chess.Color
+		before, after int
+		category      string
+		loss          int
+	}{
+		{"improvement", chess.White, 20, 80, models.MoveCategoryBest, 0},
+		{"best limit", chess.White, 30, 20, models.MoveCategoryBest, 10},
+		{"good from", chess.White, 30, 19, models.MoveCategoryGood, 11},
+		{"good limit", chess.White, 30, -20, models.MoveCategoryGood, 50},
+		{"inaccuracy from", chess.White, 30, -21, models.MoveCategoryInaccuracy, 51},
+		{"inaccuracy limit", chess.White, 30, -70, models.MoveCategoryInaccuracy, 100},
+		{"mistake from", chess.White, 30, -71, models.MoveCategoryMistake, 101},
+		{"mistake limit", chess.White, 30, -270, models.MoveCategoryMistake, 300},
+		{"blunder from", chess.White, 30, -271, models.MoveCategoryBlunder, 301},
+		{"black mover", chess.Black, -30, 71, models.MoveCategoryMistake, 101},
+		{"black improves", chess.Black, 30, -30, models.MoveCategoryBest, 0},
+		{"mate kept", chess.White, mateScore, mateScore, models.MoveCategoryBest, 0},
+		{"mate to a won position", chess.White, mateScore, 1500, models.MoveCategoryBest, 0},
+		{"mate to a smaller edge", chess.White, mateScore, 800, models.MoveCategoryMistake, 200},
+		{"mate thrown away", chess.White, mateScore, 0, models.MoveCategoryBlunder, evalCap},
+		{"walk into mate", chess.Black, 0, mateScore, models.MoveCategoryBlunder, evalCap},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifier.Classify(tt.mover, tt.before, tt.after)
+			if got.Category != tt.category || got.CentipawnLoss != tt.loss {
+				t.Errorf("got %s losing %d, want %s losing %d", got.Category, got.CentipawnLoss, tt.category, tt.loss)
+			}
+			if got.Accuracy < 0 || got.Accuracy > 100 || (tt.loss == 0 && got.Accuracy != 100) {
+				t.Errorf("accuracy %v", got.Accuracy)
+			}
+		})
+	}
+}
+
+func TestEvalCentipawns(t *testing.T) {
+	white, err := chess.ParseFEN(chess.StartingFEN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	black, err := chess.ParseFEN("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		eval models.PositionEval
+		pos  *chess.Position
+		want int
+	}{
+		{models.PositionEval{ScoreType: "cp", Score: -35}, white, -35},
+		{models.PositionEval{ScoreType: "mate", Score: 3}, black, mateScore},
+		{models.PositionEval{ScoreType: "mate", Score: -2}, white, -mateScore},
+		{models.PositionEval{ScoreType: "mate", Score: 0}, white, -mateScore},
+		{models.PositionEval{ScoreType: "mate", Score: 0}, black, mateScore},
+	}
+	for _, tt := range tests {
+		if got := EvalCentipawns(tt.eval, tt.pos); got != tt.want {
+			t.Errorf("EvalCentipawns(%s %d) with %v to move = %d, want %d", tt.eval.ScoreType, tt.eval.Score, tt.pos.Turn, got, tt.want)
+		}
+	}
+}
+
+func TestEngineEvals(t *testing.T) {
+	evals := EngineEvals([]models.PositionEval{
+		{FEN: "a", Score: 10, Source: models.EvalSourceEngine},
+		{FEN: "b", Score: 900, Source: models.EvalSourceClient},
+		{FEN: "c", Score: 20},
+	})
+	if len(evals) != 1 || evals["a"].Score != 10 {
+		t.Errorf("got %v, want only the engine eval of a", evals)
+	}
+}
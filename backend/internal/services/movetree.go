@@ -0,0 +1,341 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrMissingMove  = errors.New("node has neither move nor uci")
+	ErrMoveMismatch = errors.New("move and uci describe different moves")
+	ErrFENMismatch  = errors.New("fen does not match the position after the move")
+
+	ErrInvalidNodePath = errors.New("invalid node path")
+	ErrNodeNotFound    = errors.New("node not found")
+	ErrDuplicateMove   = errors.New("move already exists at this position")
+)
+
+// MoveTreeError points at the first invalid node of an opening's move tree.
+// Path holds the child index at each depth, starting from Opening.Moves.
+type MoveTreeError struct {
+	Path []int
+	Move string
+	Err  error
+}
+
+func (e *MoveTreeError) Error() string {
+	return fmt.Sprintf("move %q at path %s: %v", e.Move, FormatNodePath(e.Path), e.Err)
+}
+
+func (e *MoveTreeError) Unwrap() error {
+	return e.Err
+}
+
+// FormatNodePath renders a node path as dot-separated child indexes, e.g. "0.2.1".
+func FormatNodePath(path []int) string {
+	parts := make([]string, len(path))
+	for i, idx := range path {
+		parts[i] = strconv.Itoa(idx)
+	}
+	return strings.Join(parts, ".")
+}
+
+// ParseNodePath parses a path written by FormatNodePath.
+func ParseNodePath(s string) ([]int, error) {
+	if s == "" {
+		return nil, ErrInvalidNodePath
+	}
+	parts := strings.Split(s, ".")
+	path := make([]int, len(parts))
+	for i, part := range parts {
+		idx, err := strconv.Atoi(part)
+		if err != nil || idx < 0 {
+			return nil, ErrInvalidNodePath
+		}
+		path[i] = idx
+	}
+	return path, nil
+}
+
+// ResolveNode finds the node addressed by ref, which is either a node ID or
+// a dot-separated node path, and returns its path.
+func ResolveNode(nodes []models.MoveNode, ref string) ([]int, error) {
+	if id, err := primitive.ObjectIDFromHex(ref); err == nil {
+		if path := FindNode(nodes, id); path != nil {
+			return path, nil
+		}
+		return nil, ErrNodeNotFound
+	}
+	path, err := ParseNodePath(ref)
+	if err != nil {
+		return nil, err
+	}
+	if NodeAt(nodes, path) == nil {
+		return nil, ErrNodeNotFound
+	}
+	return path, nil
+}
+
+// StartingPosition parses an opening's starting FEN, defaulting to the
+// standard initial position when it is empty.
+func StartingPosition(fen string) (*chess.Position, error) {
+	if fen == "" {
+		return chess.NewPosition(), nil
+	}
+	return chess.ParseFEN(fen)
+}
+
+// NormalizeOpening checks every node of the opening against the rules of
+// chess and fills in missing FEN, SAN and UCI fields. Moves may be given in
+// either SAN or UCI; both are rewritten to their canonical form.
+func NormalizeOpening(opening *models.Opening) error {
+	pos, err := StartingPosition(opening.StartingFEN)
+	if err != nil {
+		return fmt.Errorf("starting_fen: %w", err)
+	}
+	opening.StartingFEN = pos.FEN()
+	return normalizeNodes(pos, opening.Moves, nil)
+}
+
+func normalizeNodes(pos *chess.Position, nodes []models.MoveNode, path []int) error {
+	for i := range nodes {
+		node := &nodes[i]
+		nodePath := append(path[:len(path):len(path)], i)
+
+		m, err := resolveNodeMove(pos, node)
+		if err != nil {
+			return &MoveTreeError{Path: nodePath, Move: node.Move, Err: err}
+		}
+		next, err := pos.Apply(m)
+		if err != nil {
+			return &MoveTreeError{Path: nodePath, Move: node.Move, Err: err}
+		}
+
+		if node.FEN != "" {
+			claimed, err := chess.ParseFEN(node.FEN)
+			if err != nil {
+				return &MoveTreeError{Path: nodePath, Move: node.Move, Err: err}
+			}
+			if claimed.Key() != next.Key() {
+				return &MoveTreeError{Path: nodePath, Move: node.Move, Err: ErrFENMismatch}
+			}
+		}
+
+		node.FEN = next.FEN()
+		node.Move = pos.SAN(m)
+		node.UCI = m.UCI()
+
+		if err := normalizeNodes(next, node.Children, nodePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resolveNodeMove(pos *chess.Position, node *models.MoveNode) (chess.Move, error) {
+	switch {
+	case node.Move != "" && node.UCI != "":
+		m, err := pos.ParseMove(node.Move)
+		if err != nil {
+			return chess.Move{}, err
+		}
+		u, err := pos.ParseUCI(node.UCI)
+		if err != nil {
+			return chess.Move{}, err
+		}
+		if m != u {
+			return chess.Move{}, ErrMoveMismatch
+		}
+		return m, nil
+	case node.Move != "":
+		return pos.ParseMove(node.Move)
+	case node.UCI != "":
+		return pos.ParseUCI(node.UCI)
+	}
+	return chess.Move{}, ErrMissingMove
+}
+
+// AssignNodeIDs gives an ID to every node that lacks one, and a fresh ID to
+// any node repeating an ID already used elsewhere in the tree, so that IDs
+// stay unique within an opening. It reports whether any node changed.
+func AssignNodeIDs(nodes []models.MoveNode) bool {
+	return assignNodeIDs(nodes, map[primitive.ObjectID]bool{})
+}
+
+func assignNodeIDs(nodes []models.MoveNode, seen map[primitive.ObjectID]bool) bool {
+	changed := false
+	for i := range nodes {
+		node := &nodes[i]
+		if node.ID.IsZero() || seen[node.ID] {
+			node.ID = primitive.NewObjectID()
+			changed = true
+		}
+		seen[node.ID] = true
+		if assignNodeIDs(node.Children, seen) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// CarryNodeIDs copies the IDs of the previous version of a tree onto the
+// nodes of the new version that come without one, matching nodes by their
+// move at each branch. Clients that resend the tree without IDs then keep
+// the IDs of the positions they did not touch. Both trees must be normalized.
+func CarryNodeIDs(previous, nodes []models.MoveNode) {
+	for i := range nodes {
+		node := &nodes[i]
+		for j := range previous {
+			if previous[j].UCI != node.UCI {
+				continue
+			}
+			if node.ID.IsZero() {
+				node.ID = previous[j].ID
+			}
+			CarryNodeIDs(previous[j].Children, node.Children)
+			break
+		}
+	}
+}
+
+// FindNode returns the path of the node with the given ID, or nil if the
+// tree has no such node.
+func FindNode(nodes []models.MoveNode, id primitive.ObjectID) []int {
+	for i := range nodes {
+		if nodes[i].ID == id {
+			return []int{i}
+		}
+		if path := FindNode(nodes[i].Children, id); path != nil {
+			return append([]int{i}, path...)
+		}
+	}
+	return nil
+}
+
+// FindPosition returns the path of the shallowest node reaching the
+// position fen, an empty path when fen is the opening's starting position,
+// or ErrNodeNotFound when the opening never reaches it.
+func FindPosition(opening *models.Opening, fen string) ([]int, error) {
+	key, err := chess.NormalizeFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	if start, err := StartingPosition(opening.StartingFEN); err == nil && start.Key() == key {
+		return []int{}, nil
+	}
+
+	type queued struct {
+		nodes []models.MoveNode
+		path  []int
+	}
+	queue := []queued{{nodes: opening.Moves}}
+	for len(queue) > 0 {
+		level := queue[0]
+		queue = queue[1:]
+		for i := range level.nodes {
+			node := &level.nodes[i]
+			path := append(level.path[:len(level.path):len(level.path)], i)
+			if nodeKey, err := chess.NormalizeFEN(node.FEN); err == nil && nodeKey == key {
+				return path, nil
+			}
+			queue = append(queue, queued{nodes: node.Children, path: path})
+		}
+	}
+	return nil, ErrNodeNotFound
+}
+
+// NodeAt returns the node at path, or nil if the path leads nowhere.
+func NodeAt(nodes []models.MoveNode, path []int) *models.MoveNode {
+	var node *models.MoveNode
+	for _, idx := range path {
+		if idx < 0 || idx >= len(nodes) {
+			return nil
+		}
+		node = &nodes[idx]
+		nodes = node.Children
+	}
+	return node
+}
+
+// LineTo lists the SAN moves along path, ending with the node at path.
+func LineTo(nodes []models.MoveNode, path []int) []string {
+	line := make([]string, 0, len(path))
+	for _, idx := range path {
+		if idx < 0 || idx >= len(nodes) {
+			return nil
+		}
+		line = append(line, nodes[idx].Move)
+		nodes = nodes[idx].Children
+	}
+	return line
+}
+
+// ChildrenAt returns the children of the node at path, or the opening's
+// first moves for an empty path.
+func ChildrenAt(opening *models.Opening, path []int) []models.MoveNode {
+	if len(path) == 0 {
+		return opening.Moves
+	}
+	return NodeAt(opening.Moves, path).Children
+}
+
+// WithoutNode returns siblings minus the one at index. When that one was
+// the main line, the first remaining sibling takes its place, so the
+// position keeps a main line as long as it has any move.
+func WithoutNode(siblings []models.MoveNode, index int) []models.MoveNode {
+	remaining := make([]models.MoveNode, 0, len(siblings)-1)
+	remaining = append(remaining, siblings[:index]...)
+	remaining = append(remaining, siblings[index+1:]...)
+	if !siblings[index].IsMainLine || len(remaining) == 0 {
+		return remaining
+	}
+	for _, sibling := range remaining {
+		if sibling.IsMainLine {
+			return remaining
+		}
+	}
+	remaining[0].IsMainLine = true
+	return remaining
+}
+
+// FENBefore returns the position the node at path is played from.
+func FENBefore(opening *models.Opening, path []int) string {
+	if len(path) > 1 {
+		return NodeAt(opening.Moves, path[:len(path)-1]).FEN
+	}
+	if opening.StartingFEN == "" {
+		return chess.StartingFEN
+	}
+	return opening.StartingFEN
+}
+
+// NewChildNode validates a move given in SAN or UCI in the position fen and
+// builds the node it leads to, with a new ID. siblings are the moves already
+// prepared in that position; the first one becomes the main line.
+func NewChildNode(fen, move string, siblings []models.MoveNode) (models.MoveNode, error) {
+	pos, err := StartingPosition(fen)
+	if err != nil {
+		return models.MoveNode{}, err
+	}
+	m, err := pos.ParseMove(move)
+	if err != nil {
+		return models.MoveNode{}, err
+	}
+	if containsMove(siblings, m.UCI()) {
+		return models.MoveNode{}, ErrDuplicateMove
+	}
+	next, _ := pos.Apply(m)
+	return models.MoveNode{
+		ID:         primitive.NewObjectID(),
+		FEN:        next.FEN(),
+		Move:       pos.SAN(m),
+		UCI:        m.UCI(),
+		IsMainLine: len(siblings) == 0,
+	}, nil
+}
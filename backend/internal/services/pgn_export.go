@@ -0,0 +1,64 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/pgn"
+)
+
+// RepertoireToPGN exports every opening of a repertoire as one PGN game each.
+func RepertoireToPGN(repertoire *models.Repertoire) string {
+	var b strings.Builder
+	for i := range repertoire.Openings {
+		b.WriteString(GameFromOpening(repertoire, &repertoire.Openings[i]).String())
+	}
+	return b.String()
+}
+
+// GameFromOpening converts an opening's move tree into a PGN game. At each
+// branch the node flagged IsMainLine (or else the first one) is written as
+// the main line and its siblings as variations, in their stored order.
+func GameFromOpening(repertoire *models.Repertoire, opening *models.Opening) *pgn.Game {
+	game := &pgn.Game{
+		Tags:    []pgn.Tag{{Name: "Event", Value: repertoire.Name}},
+		Comment: opening.Notes,
+		Result:  "*",
+		Moves:   pgnNodes(opening.Moves),
+	}
+	if opening.ECO != "" {
+		game.Tags = append(game.Tags, pgn.Tag{Name: "ECO", Value: opening.ECO})
+	}
+	if opening.Name != "" {
+		game.Tags = append(game.Tags, pgn.Tag{Name: "Opening", Value: opening.Name})
+	}
+	if opening.StartingFEN != "" && opening.StartingFEN != chess.StartingFEN {
+		game.Tags = append(game.Tags,
+			pgn.Tag{Name: "SetUp", Value: "1"},
+			pgn.Tag{Name: "FEN", Value: opening.StartingFEN},
+		)
+	}
+	return game
+}
+
+func pgnNodes(moves []models.MoveNode) []*pgn.Node {
+	nodes := make([]*pgn.Node, 0, len(moves))
+	promoted := len(moves) > 0 && moves[0].IsMainLine
+	for i := range moves {
+		move := &moves[i]
+		node := &pgn.Node{
+			SAN:      move.Move,
+			Comment:  move.Comment,
+			NAGs:     move.NAGs,
+			Children: pgnNodes(move.Children),
+		}
+		if move.IsMainLine && !promoted {
+			nodes = append([]*pgn.Node{node}, nodes...)
+			promoted = true
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func withoutIDs(nodes []models.MoveNode) []models.MoveNode {
+	for i := range nodes {
+		nodes[i].ID = primitive.NilObjectID
+		withoutIDs(nodes[i].Children)
+	}
+	return nodes
+}
+
+func TestOpeningPGNRoundTrip(t *testing.T) {
+	opening := models.Opening{
+		Name:        "Italian Game",
+		ECO:         "C50",
+		Notes:       "Black's main choices after 3.Bc4, with the gambits White can answer them with, for a first look at the open games.",
+		StartingFEN: "r1bqkbnr/pppp1ppp/2n5/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 3 3",
+		Moves: []models.MoveNode{
+			{Move: "Bc5", Comment: "Giuoco Piano", NAGs: []int{1}, IsMainLine: true, Children: []models.MoveNode{
+				{Move: "c3", IsMainLine: true, Children: []models.MoveNode{{Move: "Nf6", IsMainLine: true}}},
+				{Move: "b4", Comment: "The Evans Gambit gives up a pawn for quick development and a strong centre after c3 and d4.", NAGs: []int{5}, Children: []models.MoveNode{
+					{Move: "Bxb4", IsMainLine: true},
+					{Move: "Bb6", NAGs: []int{6}},
+				}},
+			}},
+			{Move: "Nf6", Comment: "Two Knights", Children: []models.MoveNode{
+				{Move: "Ng5", IsMainLine: true, Children: []models.MoveNode{{Move: "d5", IsMainLine: true}}},
+			}},
+			{Move: "Be7", NAGs: []int{6, 14}},
+		},
+	}
+	if err := NormalizeOpening(&opening); err != nil {
+		t.Fatal(err)
+	}
+	repertoire := &models.Repertoire{Name: "Open games", Color: "white", Openings: []models.Opening{opening}}
+
+	exported := RepertoireToPGN(repertoire)
+	for _, header := range []string{`[SetUp "1"]`, `[FEN "` + opening.StartingFEN + `"]`, `[ECO "C50"]`} {
+		if !strings.Contains(exported, header+"\n") {
+			t.Errorf("export lacks %s:\n%s", header, exported)
+		}
+	}
+	movetext := strings.SplitN(exported, "\n\n", 2)[1]
+	lines := strings.Split(strings.TrimSpace(movetext), "\n")
+	if len(lines) < 3 {
+		t.Errorf("movetext is %d lines, want it wrapped:\n%s", len(lines), movetext)
+	}
+	for _, line := range lines {
+		if len(line) > 79 {
+			t.Errorf("line of %d characters: %s", len(line), line)
+		}
+	}
+
+	openings, errs := OpeningsFromPGN(exported)
+	if len(errs) > 0 {
+		t.Fatalf("re-import failed: %+v\n%s", errs, exported)
+	}
+	if len(openings) != 1 {
+		t.Fatalf("re-imported %d openings, want 1", len(openings))
+	}
+	back := openings[0]
+	if back.Name != opening.Name || back.ECO != opening.ECO || back.Notes != opening.Notes || back.StartingFEN != opening.StartingFEN {
+		t.Errorf("re-imported %q %q %q from %q, want %q %q %q from %q",
+			back.Name, back.ECO, back.Notes, back.StartingFEN, opening.Name, opening.ECO, opening.Notes, opening.StartingFEN)
+	}
+	if !reflect.DeepEqual(withoutIDs(back.Moves), withoutIDs(opening.Moves)) {
+		t.Errorf("move tree changed in the round trip:\n%s", exported)
+	}
+
+	again := RepertoireToPGN(&models.Repertoire{Name: repertoire.Name, Openings: openings})
+	if again != exported {
+		t.Errorf("second export differs:\n%s\nwant:\n%s", again, exported)
+	}
+}
+
+func TestGameFromOpeningMainLineFirst(t *testing.T) {
+	opening := models.Opening{Moves: []models.MoveNode{
+		{Move: "d4"},
+		{Move: "e4", IsMainLine: true},
+		{Move: "c4"},
+	}}
+	if err := NormalizeOpening(&opening); err != nil {
+		t.Fatal(err)
+	}
+	game := GameFromOpening(&models.Repertoire{Name: "White"}, &opening)
+	if got := strings.SplitN(game.String(), "\n\n", 2)[1]; got != "1. e4 (1. d4) (1. c4) *\n\n" {
+		t.Errorf("movetext %q, want the main line first", got)
+	}
+	if game.TagValue("SetUp") != "" || game.TagValue("FEN") != "" {
+		t.Errorf("tags %v for the standard starting position", game.Tags)
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+	"github.com/nagara/openings-master/backend/internal/pgn"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OpeningsFromPGN parses PGN text and converts every game into an opening.
+// Games that fail to parse or contain illegal moves are returned as errors
+// without affecting the others.
+func OpeningsFromPGN(text string) ([]models.Opening, []models.ImportGameError) {
+	games, parseErrs := pgn.Parse(text)
+
+	openings := []models.Opening{}
+	importErrs := []models.ImportGameError{}
+	for _, err := range parseErrs {
+		importErrs = append(importErrs, toImportError(err))
+	}
+
+	for _, game := range games {
+		opening, err := OpeningFromGame(game)
+		if err != nil {
+			err.Game = game.Number
+			importErrs = append(importErrs, toImportError(err))
+			continue
+		}
+		openings = append(openings, opening)
+	}
+
+	sort.SliceStable(importErrs, func(i, j int) bool {
+		return importErrs[i].Game < importErrs[j].Game
+	})
+	return openings, importErrs
+}
+
+// OpeningFromGame replays a parsed game move by move and builds the
+// corresponding opening tree. The first move at every branch point is
+// marked as the main line.
+func OpeningFromGame(game *pgn.Game) (models.Opening, *pgn.Error) {
+	opening := models.Opening{
+		Name:  gameName(game),
+		ECO:   game.TagValue("ECO"),
+		Notes: game.Comment,
+	}
+
+	pos := chess.NewPosition()
+	if fen := game.TagValue("FEN"); fen != "" && game.TagValue("SetUp") != "0" {
+		var err error
+		pos, err = chess.ParseFEN(fen)
+		if err != nil {
+			return opening, &pgn.Error{Line: game.Line, Column: game.Column, Message: "FEN header: " + err.Error()}
+		}
+	}
+	opening.StartingFEN = pos.FEN()
+
+	if len(game.Moves) == 0 {
+		return opening, &pgn.Error{Line: game.Line, Column: game.Column, Message: "game has no moves"}
+	}
+
+	moves, err := movesFromPGN(pos, game.Moves)
+	if err != nil {
+		return opening, err
+	}
+	opening.Moves = moves
+	return opening, nil
+}
+
+func movesFromPGN(pos *chess.Position, nodes []*pgn.Node) ([]models.MoveNode, *pgn.Error) {
+	result := make([]models.MoveNode, 0, len(nodes))
+	for i, node := range nodes {
+		m, err := pos.ParseSAN(node.SAN)
+		if err != nil {
+			return nil, &pgn.Error{Line: node.Line, Column: node.Column, Message: err.Error()}
+		}
+		next, _ := pos.Apply(m)
+
+		children, perr := movesFromPGN(next, node.Children)
+		if perr != nil {
+			return nil, perr
+		}
+
+		comment := node.CommentBefore
+		if node.Comment != "" {
+			if comment != "" {
+				comment += " "
+			}
+			comment += node.Comment
+		}
+
+		moveNode := models.MoveNode{
+			ID:         primitive.NewObjectID(),
+			FEN:        next.FEN(),
+			Move:       pos.SAN(m),
+			UCI:        m.UCI(),
+			Comment:    comment,
+			NAGs:       node.NAGs,
+			IsMainLine: i == 0,
+		}
+		if len(children) > 0 {
+			moveNode.Children = children
+		}
+		result = append(result, moveNode)
+	}
+	return result, nil
+}
+
+func gameName(game *pgn.Game) string {
+	if name := game.TagValue("Opening"); name != "" && name != "?" {
+		if variation := game.TagValue("Variation"); variation != "" && variation != "?" {
+			return name + ": " + variation
+		}
+		return name
+	}
+	if event := game.TagValue("Event"); event != "" && event != "?" {
+		return event
+	}
+	return fmt.Sprintf("Imported game %d", game.Number)
+}
+
+func toImportError(err *pgn.Error) models.ImportGameError {
+	return models.ImportGameError{
+		Game:    err.Game,
+		Line:    err.Line,
+		Column:  err.Column,
+		Message: err.Message,
+	}
+}
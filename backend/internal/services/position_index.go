@@ -0,0 +1,65 @@
+package services
+
+import (
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+// IndexPositions lists every position of the repertoire's move trees with
+// the moves prepared from it. Openings whose starting position is invalid
+// are skipped, as are the subtrees below nodes without a FEN.
+func IndexPositions(repertoire *models.Repertoire) []models.PositionEntry {
+	color, colorErr := chess.ParseColor(repertoire.Color)
+	entries := []models.PositionEntry{}
+
+	var walk func(opening *models.Opening, pos *chess.Position, node *models.MoveNode, nodes []models.MoveNode, path []int, line []string)
+	walk = func(opening *models.Opening, pos *chess.Position, node *models.MoveNode, nodes []models.MoveNode, path []int, line []string) {
+		entry := models.PositionEntry{
+			UserID:            repertoire.UserID,
+			RepertoireID:      repertoire.ID,
+			RepertoireVersion: repertoire.Version,
+			RepertoireName:    repertoire.Name,
+			Color:             repertoire.Color,
+			OpeningID:         opening.ID,
+			OpeningName:       opening.Name,
+			Path:              FormatNodePath(path),
+			Line:              line,
+			FEN:               pos.Key(),
+			UserToMove:        colorErr == nil && pos.Turn == color,
+			Replies:           []string{},
+		}
+		if node != nil {
+			entry.NodeID = node.ID
+		}
+		if len(nodes) > 0 {
+			entry.Reply = MainLineMove(nodes).Move
+			entry.Replies = append(entry.Replies, entry.Reply)
+		}
+		for i := range nodes {
+			entry.Replies = appendUnique(entry.Replies, nodes[i].Move)
+		}
+		entries = append(entries, entry)
+
+		for i := range nodes {
+			child := &nodes[i]
+			if child.FEN == "" {
+				continue
+			}
+			next, err := chess.ParseFEN(child.FEN)
+			if err != nil {
+				continue
+			}
+			walk(opening, next, child, child.Children, append(path[:len(path):len(path)], i), append(line[:len(line):len(line)], child.Move))
+		}
+	}
+
+	for i := range repertoire.Openings {
+		opening := &repertoire.Openings[i]
+		pos, err := StartingPosition(opening.StartingFEN)
+		if err != nil {
+			continue
+		}
+		walk(opening, pos, nil, opening.Moves, nil, []string{})
+	}
+	return entries
+}
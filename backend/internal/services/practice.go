@@ -0,0 +1,136 @@
+package services
+
+import (
+	"math/rand/v2"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+// Opponent move policies for PracticeConfig.OpponentPolicy.
+const (
+	PolicyMainline = "mainline"
+	PolicyUniform  = "uniform"
+	PolicyWeighted = "weighted"
+)
+
+// OpeningTree indexes the prepared moves of one or more openings by
+// position key, so a position reached by transposition finds every
+// continuation prepared for it.
+type OpeningTree map[string][]models.MoveNode
+
+func NewOpeningTree(openings ...*models.Opening) OpeningTree {
+	tree := OpeningTree{}
+	for _, opening := range openings {
+		start, err := StartingPosition(opening.StartingFEN)
+		if err != nil {
+			continue
+		}
+		tree.add(start.Key(), opening.Moves)
+	}
+	return tree
+}
+
+func (t OpeningTree) add(key string, nodes []models.MoveNode) {
+	for _, node := range nodes {
+		if !containsMove(t[key], node.UCI) {
+			t[key] = append(t[key], node)
+		}
+		if childKey, err := chess.NormalizeFEN(node.FEN); err == nil {
+			t.add(childKey, node.Children)
+		}
+	}
+}
+
+// Moves returns the prepared continuations for a position key.
+func (t OpeningTree) Moves(key string) []models.MoveNode {
+	return t[key]
+}
+
+// ContainsMove reports whether one of the nodes plays the given UCI move.
+func ContainsMove(nodes []models.MoveNode, uci string) bool {
+	return containsMove(nodes, uci)
+}
+
+func containsMove(nodes []models.MoveNode, uci string) bool {
+	for _, n := range nodes {
+		if n.UCI == uci {
+			return true
+		}
+	}
+	return false
+}
+
+// MainLineMove returns the candidate flagged as main line, or the first one.
+func MainLineMove(candidates []models.MoveNode) models.MoveNode {
+	for _, c := range candidates {
+		if c.IsMainLine {
+			return c
+		}
+	}
+	return candidates[0]
+}
+
+// AcceptedMoves returns the moves the user may play: only the main line in
+// "strict" difficulty, any prepared move otherwise.
+func AcceptedMoves(candidates []models.MoveNode, difficulty string) []models.MoveNode {
+	if len(candidates) == 0 {
+		return nil
+	}
+	if difficulty == "strict" {
+		return []models.MoveNode{MainLineMove(candidates)}
+	}
+	return candidates
+}
+
+// OpponentPolicy resolves the effective policy of a session. Without
+// variations the opponent always sticks to the main line.
+func OpponentPolicy(config models.PracticeConfig) string {
+	if !config.AllowVariations {
+		return PolicyMainline
+	}
+	switch config.OpponentPolicy {
+	case PolicyMainline, PolicyUniform, PolicyWeighted:
+		return config.OpponentPolicy
+	}
+	return PolicyUniform
+}
+
+// ChooseOpponentMove picks the opponent's reply among the prepared moves.
+// For the weighted policy, weight scores each candidate by how much the
+// user struggles with the position it leads to.
+func ChooseOpponentMove(candidates []models.MoveNode, policy string, weight func(models.MoveNode) float64) models.MoveNode {
+	switch policy {
+	case PolicyUniform:
+		return candidates[rand.IntN(len(candidates))]
+	case PolicyWeighted:
+		total := 0.0
+		weights := make([]float64, len(candidates))
+		for i, c := range candidates {
+			weights[i] = weight(c)
+			total += weights[i]
+		}
+		r := rand.Float64() * total
+		for i, w := range weights {
+			if r < w {
+				return candidates[i]
+			}
+			r -= w
+		}
+		return candidates[len(candidates)-1]
+	}
+	return MainLineMove(candidates)
+}
+
+// ReviewWeight scores how often the user gets a position wrong, for the
+// weighted opponent policy. Positions without a card count as average.
+func ReviewWeight(card *models.ReviewCard) float64 {
+	if card == nil {
+		return 1
+	}
+	weight := 1 + 2*float64(card.Lapses) + 4*(DefaultEaseFactor-card.EaseFactor)
+	if weight < 1 {
+		return 1
+	}
+	return weight
+}
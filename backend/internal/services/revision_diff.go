@@ -0,0 +1,153 @@
+package services
+
+import (
+	"slices"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Snapshot captures the editable content of a repertoire.
+func Snapshot(repertoire *models.Repertoire) models.RepertoireSnapshot {
+	return models.RepertoireSnapshot{
+		Name:     repertoire.Name,
+		Color:    repertoire.Color,
+		Openings: repertoire.Openings,
+	}
+}
+
+// DiffSnapshots compares two snapshots of a repertoire. Openings and move
+// nodes are matched by ID, so a node keeps its identity when it moves
+// within its siblings. An added or removed subtree is reported once, at its
+// root.
+func DiffSnapshots(from, to models.RepertoireSnapshot) []models.OpeningDiff {
+	diffs := []models.OpeningDiff{}
+
+	previous := make(map[primitive.ObjectID]*models.Opening, len(from.Openings))
+	for i := range from.Openings {
+		previous[from.Openings[i].ID] = &from.Openings[i]
+	}
+
+	for i := range to.Openings {
+		opening := &to.Openings[i]
+		old, ok := previous[opening.ID]
+		if !ok {
+			diffs = append(diffs, models.OpeningDiff{OpeningID: opening.ID, Name: opening.Name, Change: "added"})
+			continue
+		}
+		delete(previous, opening.ID)
+
+		diff := models.OpeningDiff{OpeningID: opening.ID, Name: opening.Name, Change: "changed"}
+		if old.Name != opening.Name {
+			diff.Fields = append(diff.Fields, "name")
+		}
+		if old.ECO != opening.ECO {
+			diff.Fields = append(diff.Fields, "eco")
+		}
+		if old.StartingFEN != opening.StartingFEN {
+			diff.Fields = append(diff.Fields, "starting_fen")
+		}
+		if old.Notes != opening.Notes {
+			diff.Fields = append(diff.Fields, "notes")
+		}
+		diff.Nodes = diffNodes(old.Moves, opening.Moves, nil, nil, diff.Nodes)
+		if len(diff.Fields) > 0 || len(diff.Nodes) > 0 {
+			diffs = append(diffs, diff)
+		}
+	}
+
+	// Report removed openings in their original order
+	for i := range from.Openings {
+		if opening, ok := previous[from.Openings[i].ID]; ok {
+			diffs = append(diffs, models.OpeningDiff{OpeningID: opening.ID, Name: opening.Name, Change: "removed"})
+		}
+	}
+	return diffs
+}
+
+// SnapshotFields lists the repertoire-level fields that differ.
+func SnapshotFields(from, to models.RepertoireSnapshot) []string {
+	var fields []string
+	if from.Name != to.Name {
+		fields = append(fields, "name")
+	}
+	if from.Color != to.Color {
+		fields = append(fields, "color")
+	}
+	return fields
+}
+
+func diffNodes(from, to []models.MoveNode, path []int, line []string, diffs []models.NodeDiff) []models.NodeDiff {
+	previous := make(map[primitive.ObjectID]int, len(from))
+	for i := range from {
+		previous[from[i].ID] = i
+	}
+
+	for i := range to {
+		node := &to[i]
+		nodePath := append(path[:len(path):len(path)], i)
+		nodeLine := append(line[:len(line):len(line)], node.Move)
+
+		j, ok := previous[node.ID]
+		if !ok {
+			diffs = append(diffs, models.NodeDiff{
+				NodeID:       node.ID,
+				Path:         FormatNodePath(nodePath),
+				Line:         nodeLine,
+				Change:       "added",
+				Subtree:      countNodes(node.Children),
+				CommentAfter: node.Comment,
+			})
+			continue
+		}
+		delete(previous, node.ID)
+
+		old := &from[j]
+		var fields []string
+		if old.Comment != node.Comment {
+			fields = append(fields, "comment")
+		}
+		if !slices.Equal(old.NAGs, node.NAGs) {
+			fields = append(fields, "nags")
+		}
+		if old.IsMainLine != node.IsMainLine {
+			fields = append(fields, "is_main_line")
+		}
+		if len(fields) > 0 {
+			diffs = append(diffs, models.NodeDiff{
+				NodeID:        node.ID,
+				Path:          FormatNodePath(nodePath),
+				Line:          nodeLine,
+				Change:        "changed",
+				Fields:        fields,
+				CommentBefore: old.Comment,
+				CommentAfter:  node.Comment,
+			})
+		}
+		diffs = diffNodes(old.Children, node.Children, nodePath, nodeLine, diffs)
+	}
+
+	for i := range from {
+		if _, ok := previous[from[i].ID]; !ok {
+			continue
+		}
+		node := &from[i]
+		diffs = append(diffs, models.NodeDiff{
+			NodeID:        node.ID,
+			Path:          FormatNodePath(append(path[:len(path):len(path)], i)),
+			Line:          append(line[:len(line):len(line)], node.Move),
+			Change:        "removed",
+			Subtree:       countNodes(node.Children),
+			CommentBefore: node.Comment,
+		})
+	}
+	return diffs
+}
+
+func countNodes(nodes []models.MoveNode) int {
+	n := len(nodes)
+	for i := range nodes {
+		n += countNodes(nodes[i].Children)
+	}
+	return n
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/chess"
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+// SM-2 parameters. Quality grades run from 0 (blackout) to 5 (perfect).
+const (
+	DefaultEaseFactor = 2.5
+	minEaseFactor     = 1.3
+	passingQuality    = 3
+)
+
+// ReviewQuality maps a practice move category to an SM-2 quality grade.
+func ReviewQuality(category string) int {
+	switch category {
+	case "repertoire", "book", "best":
+		return 5
+	case "good":
+		return 4
+	case "inaccuracy":
+		return 3
+	case "mistake":
+		return 1
+	case "blunder":
+		return 0
+	}
+	return 2
+}
+
+// NewReviewCard returns an unreviewed card that is due immediately.
+func NewReviewCard(now time.Time) *models.ReviewCard {
+	return &models.ReviewCard{
+		EaseFactor: DefaultEaseFactor,
+		DueAt:      now,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// ScheduleReview applies one SM-2 review to the card. A failed review resets
+// the repetition count and makes the card due again right away so it is
+// drilled until it sticks.
+func ScheduleReview(card *models.ReviewCard, quality int, now time.Time) {
+	if card.EaseFactor == 0 {
+		card.EaseFactor = DefaultEaseFactor
+	}
+
+	if quality < passingQuality {
+		card.Repetitions = 0
+		card.IntervalDays = 0
+		card.Lapses++
+	} else {
+		switch card.Repetitions {
+		case 0:
+			card.IntervalDays = 1
+		case 1:
+			card.IntervalDays = 6
+		default:
+			card.IntervalDays = int(math.Round(float64(card.IntervalDays) * card.EaseFactor))
+		}
+		card.Repetitions++
+	}
+
+	q := float64(5 - quality)
+	card.EaseFactor = math.Max(minEaseFactor, card.EaseFactor+0.1-q*(0.08+q*0.02))
+
+	card.DueAt = now.AddDate(0, 0, card.IntervalDays)
+	card.LastReviewedAt = &now
+	card.UpdatedAt = now
+}
+
+// reviewUrgency ranks due cards: the further a card is past its due date
+// relative to its interval, the closer it is to being forgotten.
+func reviewUrgency(card *models.ReviewCard, now time.Time) float64 {
+	overdue := now.Sub(card.DueAt).Hours() / 24
+	return overdue / math.Max(1, float64(card.IntervalDays))
+}
+
+// SortByUrgency orders due cards most urgent first. Cards that were never
+// reviewed come after the ones being relearned or reviewed.
+func SortByUrgency(cards []models.ReviewCard, now time.Time) {
+	sort.SliceStable(cards, func(i, j int) bool {
+		iNew, jNew := cards[i].LastReviewedAt == nil, cards[j].LastReviewedAt == nil
+		if iNew != jNew {
+			return !iNew
+		}
+		return reviewUrgency(&cards[i], now) > reviewUrgency(&cards[j], now)
+	})
+}
+
+// ReviewPositions lists every position of the repertoire where the
+// repertoire's side is to move and at least one reply is prepared. The
+// returned cards only carry the position fields; scheduling fields are left
+// for the caller. Positions reached in several openings are merged.
+func ReviewPositions(repertoire *models.Repertoire) []models.ReviewCard {
+	color, err := chess.ParseColor(repertoire.Color)
+	if err != nil {
+		return nil
+	}
+
+	var cards []models.ReviewCard
+	index := map[string]int{}
+
+	var walk func(opening *models.Opening, fen string, line []string, nodes []models.MoveNode)
+	walk = func(opening *models.Opening, fen string, line []string, nodes []models.MoveNode) {
+		if len(nodes) == 0 {
+			return
+		}
+		pos, err := StartingPosition(fen)
+		if err != nil {
+			return
+		}
+
+		if pos.Turn == color {
+			key := pos.Key()
+			i, seen := index[key]
+			if !seen {
+				i = len(cards)
+				index[key] = i
+				cards = append(cards, models.ReviewCard{
+					RepertoireID:  repertoire.ID,
+					OpeningID:     opening.ID,
+					FEN:           key,
+					Moves:         append([]string{}, line...),
+					ExpectedMoves: []string{},
+				})
+			}
+			for _, node := range nodes {
+				cards[i].ExpectedMoves = appendUnique(cards[i].ExpectedMoves, node.Move)
+			}
+		}
+
+		for _, node := range nodes {
+			if node.FEN != "" {
+				walk(opening, node.FEN, append(line[:len(line):len(line)], node.Move), node.Children)
+			}
+		}
+	}
+
+	for i := range repertoire.Openings {
+		opening := &repertoire.Openings[i]
+		walk(opening, opening.StartingFEN, nil, opening.Moves)
+	}
+	return cards
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nagara/openings-master/backend/internal/models"
+)
+
+func TestReviewQuality(t *testing.T) {
+	tests := []struct {
+		category string
+		want     int
+	}{
+		{models.MoveCategoryRepertoire, 5},
+		{"book", 5},
+		{models.MoveCategoryBest, 5},
+		{models.MoveCategoryGood, 4},
+		{models.MoveCategoryInaccuracy, 3},
+		{models.MoveCategoryMistake, 1},
+		{models.MoveCategoryBlunder, 0},
+		{"", 2},
+		{"unknown", 2},
+	}
+	for _, tt := range tests {
+		if got := ReviewQuality(tt.category); got != tt.want {
+			t.Errorf("ReviewQuality(%q) = %d, want %d", tt.category, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleReviewIntervals(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	card := NewReviewCard(now)
+
+	// Perfect answers: 1 day, 6 days, then the previous interval times the
+	// ease factor, which grows by 0.1 each time
+	for i, want := range []int{1, 6, 16, 45} {
+		ScheduleReview(card, 5, now)
+		if card.IntervalDays != want || card.Repetitions != i+1 {
+			t.Fatalf("review %d: interval %d after %d repetitions, want %d", i+1, card.IntervalDays, card.Repetitions, want)
+		}
+		if !card.DueAt.Equal(now.AddDate(0, 0, want)) || card.LastReviewedAt == nil || !card.LastReviewedAt.Equal(now) {
+			t.Errorf("review %d: due %v, last reviewed %v", i+1, card.DueAt, card.LastReviewedAt)
+		}
+	}
+	if math.Abs(card.EaseFactor-2.9) > 1e-9 {
+		t.Errorf("ease factor %v, want 2.9", card.EaseFactor)
+	}
+
+	// A passing but hard answer still grows the interval, by the lowered
+	// ease factor
+	ScheduleReview(card, 3, now)
+	if card.IntervalDays != int(math.Round(45*2.9)) || math.Abs(card.EaseFactor-2.76) > 1e-9 {
+		t.Errorf("interval %d with ease factor %v, want %d with 2.76", card.IntervalDays, card.EaseFactor, int(math.Round(45*2.9)))
+	}
+}
+
+func TestScheduleReviewLapse(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	card := &models.ReviewCard{EaseFactor: 2.5, IntervalDays: 16, Repetitions: 3}
+
+	for _, quality := range []int{2, 1, 0} {
+		card.IntervalDays, card.Repetitions = 16, 3
+		ScheduleReview(card, quality, now)
+		if card.Repetitions != 0 || card.IntervalDays != 0 || !card.DueAt.Equal(now) {
+			t.Errorf("quality %d: %d repetitions, interval %d, due %v; want the card reset and due now",
+				quality, card.Repetitions, card.IntervalDays, card.DueAt)
+		}
+	}
+	if card.Lapses != 3 {
+		t.Errorf("%d lapses, want 3", card.Lapses)
+	}
+
+	// Relearning starts over at one day
+	ScheduleReview(card, 4, now)
+	if card.IntervalDays != 1 || card.Repetitions != 1 {
+		t.Errorf("interval %d after %d repetitions, want 1 after 1", card.IntervalDays, card.Repetitions)
+	}
+}
+
+func TestScheduleReviewEaseFloor(t *testing.T) {
+	now := time.Now()
+	card := &models.ReviewCard{}
+
+	ScheduleReview(card, 0, now)
+	if math.Abs(card.EaseFactor-1.7) > 1e-9 {
+		t.Errorf("ease factor %v after a blackout, want 1.7 down from the default", card.EaseFactor)
+	}
+	for range 3 {
+		ScheduleReview(card, 0, now)
+		if card.EaseFactor != minEaseFactor {
+			t.Errorf("ease factor %v, want it floored at %v", card.EaseFactor, minEaseFactor)
+		}
+	}
+	ScheduleReview(card, 3, now)
+	if card.EaseFactor != minEaseFactor {
+		t.Errorf("ease factor %v after a hard answer, want it floored at %v", card.EaseFactor, minEaseFactor)
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890", in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// The RFC vectors have 8 digits; a 6-digit code is their last six
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("code at T=%d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	// Secrets are accepted in either case
+	if got, err := TOTPCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1); err != nil || got != "287082" {
+		t.Errorf("lowercase secret: got %q, %v", got, err)
+	}
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestVerifyTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	current := TOTPStep(now)
+
+	tests := []struct {
+		step int64
+		ok   bool
+	}{
+		{current - 2, false},
+		{current - 1, true},
+		{current, true},
+		{current + 1, true},
+		{current + 2, false},
+	}
+	for _, tt := range tests {
+		code, err := TOTPCode(rfcSecret, tt.step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := VerifyTOTP(rfcSecret, code, now, 0)
+		if ok != tt.ok || (ok && step != tt.step) {
+			t.Errorf("code of step %+d: got step %d, %v; want %v", tt.step-current, step, ok, tt.ok)
+		}
+	}
+}
+
+func TestVerifyTOTPReplay(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	current := TOTPStep(now)
+	code, err := TOTPCode(rfcSecret, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A code is refused once its step or a later one was used
+	for _, lastStep := range []int64{current, current + 1} {
+		if _, ok := VerifyTOTP(rfcSecret, code, now, lastStep); ok {
+			t.Errorf("code of step %d accepted after step %d was used", current, lastStep)
+		}
+	}
+	if step, ok := VerifyTOTP(rfcSecret, code, now, current-1); !ok || step != current {
+		t.Errorf("code of step %d refused after only step %d was used", current, current-1)
+	}
+
+	// The previous step's code stays refused when a later one was used
+	previous, err := TOTPCode(rfcSecret, current-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := VerifyTOTP(rfcSecret, previous, now, current); ok {
+		t.Error("code of an earlier step accepted after a later one was used")
+	}
+}
+
+func TestIsTOTPCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"287082", true},
+		{"000000", true},
+		{"28708", false},
+		{"2870820", false},
+		{"", false},
+		{"28708a", false},
+		{"287 82", false},
+		{"-28708", false},
+		{"２８７０８２", false}, // fullwidth digits
+		{"abcdefgh-ijklmnop", false},
+	}
+	for _, tt := range tests {
+		if got := IsTOTPCode(tt.code); got != tt.want {
+			t.Errorf("IsTOTPCode(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	want := HashRecoveryCode("abcdefgh-ijklmnop")
+	for _, entered := range []string{
+		"abcdefghijklmnop",
+		"ABCDEFGH-IJKLMNOP",
+		"AbCdEfGh IjKlMnOp",
+		" abcd-efgh ijkl-mnop ",
+	} {
+		if got := HashRecoveryCode(entered); got != want {
+			t.Errorf("%q hashes unlike the code it was given as", entered)
+		}
+	}
+	if HashRecoveryCode("abcdefgh-ijklmnoq") == want {
+		t.Error("different codes hash alike")
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount || len(hashes) != RecoveryCodeCount {
+		t.Fatalf("got %d codes and %d hashes, want %d", len(codes), len(hashes), RecoveryCodeCount)
+	}
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if len(code) != 17 || code[8] != '-' || IsTOTPCode(code) {
+			t.Errorf("code %q is not formatted as a recovery code", code)
+		}
+		if hashes[i] != HashRecoveryCode(code) {
+			t.Errorf("hash of %q does not match", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q given twice", code)
+		}
+		seen[code] = true
+	}
+}
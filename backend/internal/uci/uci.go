@@ -0,0 +1,333 @@
+// Package uci drives a chess engine that speaks the Universal Chess
+// Interface over its standard input and output.
+package uci
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	handshakeTimeout = 10 * time.Second
+	stopTimeout      = 2 * time.Second
+	quitTimeout      = time.Second
+)
+
+var ErrEngineExited = errors.New("engine exited")
+
+// Search limits one analysis. Depth and MoveTime may be combined; the
+// engine stops at whichever it reaches first.
+type Search struct {
+	FEN      string
+	Depth    int
+	MoveTime time.Duration
+	MultiPV  int
+}
+
+// Info is the latest complete line reported for one MultiPV slot.
+type Info struct {
+	MultiPV   int
+	Depth     int
+	ScoreType string // "cp" | "mate"
+	Score     int    // from the point of view of the side to move
+	PV        []string
+}
+
+type Result struct {
+	BestMove string
+	Lines    []Info // by MultiPV slot
+}
+
+// Engine is one running engine process. It runs one search at a time.
+type Engine struct {
+	Name string
+
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	lines   chan string
+	exited  chan struct{}
+	multiPV int
+	broken  bool
+}
+
+// Start launches the engine at path, completes the UCI handshake and sets
+// the given options.
+func Start(ctx context.Context, path string, options map[string]string) (*Engine, error) {
+	cmd := exec.Command(path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	e := &Engine{
+		cmd:     cmd,
+		stdin:   stdin,
+		lines:   make(chan string, 256),
+		exited:  make(chan struct{}),
+		multiPV: 1,
+	}
+	go e.read(stdout)
+
+	ctx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
+
+	if err := e.handshake(ctx, options); err != nil {
+		e.Close()
+		return nil, fmt.Errorf("uci handshake: %w", err)
+	}
+	return e, nil
+}
+
+func (e *Engine) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		e.lines <- scanner.Text()
+	}
+	close(e.exited)
+}
+
+func (e *Engine) handshake(ctx context.Context, options map[string]string) error {
+	if err := e.send("uci"); err != nil {
+		return err
+	}
+	for {
+		line, err := e.next(ctx)
+		if err != nil {
+			return err
+		}
+		if name, ok := strings.CutPrefix(line, "id name "); ok {
+			e.Name = name
+		}
+		if line == "uciok" {
+			break
+		}
+	}
+
+	for name, value := range options {
+		if err := e.send("setoption name " + name + " value " + value); err != nil {
+			return err
+		}
+	}
+	return e.sync(ctx)
+}
+
+// sync waits until the engine has processed every command sent so far.
+func (e *Engine) sync(ctx context.Context) error {
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+	for {
+		line, err := e.next(ctx)
+		if err != nil {
+			return err
+		}
+		if line == "readyok" {
+			return nil
+		}
+	}
+}
+
+// Analyze searches the position within the limits. When ctx ends first the
+// search is stopped and ctx's error returned; the engine stays usable if it
+// acknowledges the stop in time.
+func (e *Engine) Analyze(ctx context.Context, search Search) (*Result, error) {
+	multiPV := max(search.MultiPV, 1)
+	if multiPV != e.multiPV {
+		if err := e.send("setoption name MultiPV value " + strconv.Itoa(multiPV)); err != nil {
+			return nil, e.fail(err)
+		}
+		e.multiPV = multiPV
+	}
+	if err := e.send("position fen " + search.FEN); err != nil {
+		return nil, e.fail(err)
+	}
+	if err := e.sync(ctx); err != nil {
+		return nil, e.fail(err)
+	}
+
+	goCmd := "go"
+	if search.Depth > 0 {
+		goCmd += " depth " + strconv.Itoa(search.Depth)
+	}
+	if search.MoveTime > 0 {
+		goCmd += " movetime " + strconv.FormatInt(search.MoveTime.Milliseconds(), 10)
+	}
+	if search.Depth <= 0 && search.MoveTime <= 0 {
+		goCmd += " depth 1"
+	}
+	if err := e.send(goCmd); err != nil {
+		return nil, e.fail(err)
+	}
+
+	lines := make([]Info, multiPV)
+	for {
+		line, err := e.next(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				e.stop()
+				return nil, err
+			}
+			return nil, e.fail(err)
+		}
+
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "bestmove" {
+			result := &Result{Lines: []Info{}}
+			if len(fields) > 1 && fields[1] != "(none)" {
+				result.BestMove = fields[1]
+			}
+			for _, info := range lines {
+				if info.MultiPV > 0 {
+					result.Lines = append(result.Lines, info)
+				}
+			}
+			return result, nil
+		}
+		if info, ok := ParseInfo(line); ok && info.MultiPV <= multiPV {
+			lines[info.MultiPV-1] = info
+		}
+	}
+}
+
+// stop interrupts a search and waits for its bestmove, giving up on the
+// engine if it does not answer.
+func (e *Engine) stop() {
+	if err := e.send("stop"); err != nil {
+		e.fail(err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	for {
+		line, err := e.next(ctx)
+		if err != nil {
+			e.fail(err)
+			return
+		}
+		if strings.HasPrefix(line, "bestmove") {
+			return
+		}
+	}
+}
+
+// ParseInfo reads an "info" line carrying a score and a PV. Lines with a
+// bound instead of an exact score are ignored.
+func ParseInfo(line string) (Info, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "info" {
+		return Info{}, false
+	}
+
+	info := Info{MultiPV: 1}
+	for i := 1; i < len(fields); i++ {
+		switch fields[i] {
+		case "depth":
+			if i+1 < len(fields) {
+				info.Depth, _ = strconv.Atoi(fields[i+1])
+				i++
+			}
+		case "multipv":
+			if i+1 < len(fields) {
+				info.MultiPV, _ = strconv.Atoi(fields[i+1])
+				i++
+			}
+		case "score":
+			if i+2 < len(fields) {
+				info.ScoreType = fields[i+1]
+				info.Score, _ = strconv.Atoi(fields[i+2])
+				i += 2
+			}
+		case "lowerbound", "upperbound":
+			return Info{}, false
+		case "pv":
+			info.PV = fields[i+1:]
+			i = len(fields)
+		case "string":
+			i = len(fields)
+		}
+	}
+
+	if info.MultiPV < 1 || (info.ScoreType != "cp" && info.ScoreType != "mate") || len(info.PV) == 0 {
+		return Info{}, false
+	}
+	return info, true
+}
+
+func (e *Engine) send(cmd string) error {
+	_, err := io.WriteString(e.stdin, cmd+"\n")
+	return err
+}
+
+func (e *Engine) next(ctx context.Context) (string, error) {
+	select {
+	case line := <-e.lines:
+		return line, nil
+	case <-e.exited:
+		// Lines read before the engine exited still count
+		select {
+		case line := <-e.lines:
+			return line, nil
+		default:
+			return "", ErrEngineExited
+		}
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (e *Engine) fail(err error) error {
+	e.broken = true
+	return err
+}
+
+// Healthy reports whether the engine can run another search.
+func (e *Engine) Healthy() bool {
+	if e.broken {
+		return false
+	}
+	select {
+	case <-e.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// Close asks the engine to quit and kills it if it does not.
+func (e *Engine) Close() error {
+	// Drain what the engine still writes so its reader can finish
+	go func() {
+		for {
+			select {
+			case <-e.lines:
+			case <-e.exited:
+				return
+			}
+		}
+	}()
+
+	e.send("quit")
+	e.stdin.Close()
+
+	select {
+	case <-e.exited:
+	case <-time.After(quitTimeout):
+		e.cmd.Process.Kill()
+		<-e.exited
+	}
+	return e.cmd.Wait()
+}
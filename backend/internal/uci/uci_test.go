@@ -0,0 +1,169 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+var fakeUCI string
+
+// TestMain builds cmd/fakeuci, the stand-in engine the tests drive.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "fakeuci")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fakeUCI = filepath.Join(dir, "fakeuci")
+	build := exec.Command("go", "build", "-o", fakeUCI, "github.com/nagara/openings-master/backend/cmd/fakeuci")
+	if out, err := build.CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "building fakeuci: %v\n%s", err, out)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func startEngine(t *testing.T, options map[string]string) *Engine {
+	t.Helper()
+	e, err := Start(context.Background(), fakeUCI, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+func TestHandshake(t *testing.T) {
+	e := startEngine(t, map[string]string{"MultiPV": "2"})
+	if e.Name != "FakeUCI" {
+		t.Errorf("name %q, want FakeUCI", e.Name)
+	}
+	if !e.Healthy() {
+		t.Error("engine unhealthy after the handshake")
+	}
+}
+
+func TestStartMissingBinary(t *testing.T) {
+	if _, err := Start(context.Background(), filepath.Join(t.TempDir(), "missing"), nil); err == nil {
+		t.Error("started an engine that does not exist")
+	}
+}
+
+func TestAnalyzeMultiPV(t *testing.T) {
+	e := startEngine(t, nil)
+
+	// White wins the queen on d8 first, then the rook on a8
+	const fen = "r2qk3/8/8/8/8/8/8/R2QK3 w - - 0 1"
+	result, err := e.Analyze(context.Background(), Search{FEN: fen, Depth: 3, MultiPV: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BestMove != "d1d8" {
+		t.Errorf("best move %q, want d1d8", result.BestMove)
+	}
+	if len(result.Lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(result.Lines))
+	}
+	for i, line := range result.Lines {
+		if line.MultiPV != i+1 || line.Depth != 3 || line.ScoreType != "cp" || len(line.PV) == 0 {
+			t.Errorf("line %d: %+v", i, line)
+		}
+	}
+	if result.Lines[0].Score != 900 || result.Lines[1].PV[0] != "a1a8" {
+		t.Errorf("lines out of order: %+v", result.Lines)
+	}
+
+	// Going back to a single line
+	result, err = e.Analyze(context.Background(), Search{FEN: fen, Depth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Lines) != 1 {
+		t.Errorf("got %d lines, want 1", len(result.Lines))
+	}
+}
+
+func TestAnalyzeNoLegalMoves(t *testing.T) {
+	e := startEngine(t, nil)
+
+	const mated = "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3"
+	result, err := e.Analyze(context.Background(), Search{FEN: mated, Depth: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BestMove != "" || len(result.Lines) != 0 {
+		t.Errorf("got %+v for a mated position", result)
+	}
+}
+
+func TestAnalyzeCancel(t *testing.T) {
+	e := startEngine(t, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err := e.Analyze(ctx, Search{FEN: startFEN, MoveTime: time.Minute})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(started); elapsed > stopTimeout {
+		t.Errorf("cancelled search took %v", elapsed)
+	}
+
+	// The engine acknowledged the stop, so it can search again
+	if !e.Healthy() {
+		t.Fatal("engine unhealthy after a stopped search")
+	}
+	result, err := e.Analyze(context.Background(), Search{FEN: startFEN, Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BestMove == "" || result.Lines[0].Depth != 2 {
+		t.Errorf("search after the stop returned %+v", result)
+	}
+}
+
+func TestAnalyzeEngineExits(t *testing.T) {
+	e := startEngine(t, map[string]string{"CrashDepth": "2"})
+
+	_, err := e.Analyze(context.Background(), Search{FEN: startFEN, Depth: 5})
+	if !errors.Is(err, ErrEngineExited) {
+		t.Fatalf("got %v, want ErrEngineExited", err)
+	}
+	if e.Healthy() {
+		t.Error("engine healthy after exiting")
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	tests := []struct {
+		line string
+		want Info
+		ok   bool
+	}{
+		{"info depth 12 seldepth 18 multipv 2 score cp -35 nodes 1000 pv e7e5 g1f3", Info{MultiPV: 2, Depth: 12, ScoreType: "cp", Score: -35, PV: []string{"e7e5", "g1f3"}}, true},
+		{"info depth 30 score mate 3 pv d1h5", Info{MultiPV: 1, Depth: 30, ScoreType: "mate", Score: 3, PV: []string{"d1h5"}}, true},
+		{"info depth 12 score cp 40 lowerbound pv e2e4", Info{}, false},
+		{"info depth 12 score cp 40", Info{}, false},
+		{"info string NNUE evaluation enabled", Info{}, false},
+		{"bestmove e2e4", Info{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseInfo(tt.line)
+		if ok != tt.ok || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("ParseInfo(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -1,9 +1,26 @@
 import api from './client';
-import type { AuthResponse, LoginRequest, RegisterRequest, User } from '../types/auth';
+import type {
+  AuthResponse,
+  LoginRequest,
+  RegisterRequest,
+  Session,
+  TwoFactorChallenge,
+  TwoFactorSetup,
+  UpdateUserRequest,
+  User,
+} from '../types/auth';
 
 export const authApi = {
-  login: async (data: LoginRequest): Promise<AuthResponse> => {
-    const response = await api.post<AuthResponse>('/auth/login', data);
+  login: async (data: LoginRequest): Promise<AuthResponse | TwoFactorChallenge> => {
+    const response = await api.post<AuthResponse | TwoFactorChallenge>('/auth/login', data);
+    return response.data;
+  },
+
+  loginTwoFactor: async (challengeToken: string, code: string): Promise<AuthResponse> => {
+    const response = await api.post<AuthResponse>('/auth/login/2fa', {
+      challenge_token: challengeToken,
+      code,
+    });
     return response.data;
   },
 
@@ -19,8 +36,80 @@ export const authApi = {
     return response.data;
   },
 
+  logout: async (refreshToken: string): Promise<void> => {
+    await api.post('/auth/logout', { refresh_token: refreshToken });
+  },
+
+  logoutAll: async (): Promise<void> => {
+    await api.post('/auth/logout-all');
+  },
+
+  getSessions: async (): Promise<Session[]> => {
+    const response = await api.get<Session[]>('/auth/sessions');
+    return response.data;
+  },
+
+  revokeSession: async (sessionId: string): Promise<void> => {
+    await api.delete(`/auth/sessions/${sessionId}`);
+  },
+
+  verifyEmail: async (token: string): Promise<void> => {
+    await api.post('/auth/verify-email', { token });
+  },
+
+  resendVerification: async (): Promise<void> => {
+    await api.post('/auth/verify-email/resend');
+  },
+
+  setupTwoFactor: async (): Promise<TwoFactorSetup> => {
+    const response = await api.post<TwoFactorSetup>('/auth/2fa/setup');
+    return response.data;
+  },
+
+  enableTwoFactor: async (code: string): Promise<string[]> => {
+    const response = await api.post<{ recovery_codes: string[] }>('/auth/2fa/enable', { code });
+    return response.data.recovery_codes;
+  },
+
+  disableTwoFactor: async (password: string, code: string): Promise<void> => {
+    await api.post('/auth/2fa/disable', { password, code });
+  },
+
+  regenerateRecoveryCodes: async (code: string): Promise<string[]> => {
+    const response = await api.post<{ recovery_codes: string[] }>('/auth/2fa/recovery-codes', { code });
+    return response.data.recovery_codes;
+  },
+
+  forgotPassword: async (email: string): Promise<void> => {
+    await api.post('/auth/forgot-password', { email });
+  },
+
+  resetPassword: async (token: string, password: string): Promise<void> => {
+    await api.post('/auth/reset-password', { token, password });
+  },
+
   getMe: async (): Promise<User> => {
     const response = await api.get<User>('/users/me');
     return response.data;
   },
+
+  updateMe: async (data: UpdateUserRequest): Promise<User> => {
+    const response = await api.patch<User>('/users/me', data);
+    return response.data;
+  },
+
+  changePassword: async (currentPassword: string, newPassword: string): Promise<void> => {
+    await api.post('/users/me/password', {
+      current_password: currentPassword,
+      new_password: newPassword,
+    });
+  },
+
+  changeEmail: async (email: string, password: string, code?: string): Promise<void> => {
+    await api.post('/users/me/email', { email, password, code });
+  },
+
+  deleteMe: async (password: string, code?: string): Promise<void> => {
+    await api.delete('/users/me', { data: { password, code } });
+  },
 };
@@ -1,15 +1,14 @@
+import api from './client';
 import type { ExplorerResponse } from '../types/chess';
 
-const LICHESS_EXPLORER_URL = import.meta.env.VITE_LICHESS_EXPLORER_URL || 'https://explorer.lichess.ovh';
-
+// Explorer requests go through the backend, which caches them and keeps
+// within the Lichess rate limits.
 export const lichessApi = {
   getMasterGames: async (fen: string): Promise<ExplorerResponse> => {
-    const params = new URLSearchParams({
-      fen,
-      topGames: '0',
+    const response = await api.get<ExplorerResponse>('/explorer', {
+      params: { source: 'masters', fen },
     });
-    const response = await fetch(`${LICHESS_EXPLORER_URL}/masters?${params}`);
-    return response.json();
+    return response.data;
   },
 
   getLichessGames: async (
@@ -17,13 +16,14 @@ export const lichessApi = {
     ratings: number[] = [1600, 1800, 2000, 2200, 2500],
     speeds: string[] = ['rapid', 'classical']
   ): Promise<ExplorerResponse> => {
-    const params = new URLSearchParams({
-      fen,
-      ratings: ratings.join(','),
-      speeds: speeds.join(','),
-      topGames: '0',
+    const response = await api.get<ExplorerResponse>('/explorer', {
+      params: {
+        source: 'lichess',
+        fen,
+        ratings: ratings.join(','),
+        speeds: speeds.join(','),
+      },
     });
-    const response = await fetch(`${LICHESS_EXPLORER_URL}/lichess?${params}`);
-    return response.json();
+    return response.data;
   },
 };
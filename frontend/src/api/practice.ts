@@ -1,5 +1,5 @@
 import api from './client';
-import type { PracticeSession, StartPracticeRequest, SubmitMoveRequest, PracticeMove } from '../types/practice';
+import type { PracticeSession, StartPracticeRequest, SubmitMoveRequest, SubmitMoveResponse } from '../types/practice';
 
 export const practiceApi = {
   start: async (data: StartPracticeRequest): Promise<PracticeSession> => {
@@ -7,8 +7,8 @@ export const practiceApi = {
     return response.data;
   },
 
-  submitMove: async (sessionId: string, data: SubmitMoveRequest): Promise<PracticeMove> => {
-    const response = await api.post<PracticeMove>(`/practice/${sessionId}/move`, data);
+  submitMove: async (sessionId: string, data: SubmitMoveRequest): Promise<SubmitMoveResponse> => {
+    const response = await api.post<SubmitMoveResponse>(`/practice/${sessionId}/move`, data);
     return response.data;
   },
 
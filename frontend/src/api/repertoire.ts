@@ -1,5 +1,5 @@
 import api from './client';
-import type { Repertoire, CreateRepertoireRequest, AddOpeningRequest, Opening } from '../types/repertoire';
+import type { Repertoire, CreateRepertoireRequest, AddOpeningRequest, Opening, AddNodeRequest, NodeResponse } from '../types/repertoire';
 
 export const repertoireApi = {
   list: async (): Promise<Repertoire[]> => {
@@ -39,4 +39,28 @@ export const repertoireApi = {
   deleteOpening: async (repertoireId: string, openingId: string): Promise<void> => {
     await api.delete(`/repertoires/${repertoireId}/openings/${openingId}`);
   },
+
+  addNode: async (repertoireId: string, openingId: string, data: AddNodeRequest): Promise<NodeResponse> => {
+    const response = await api.post<NodeResponse>(`/repertoires/${repertoireId}/openings/${openingId}/nodes`, data);
+    return response.data;
+  },
+
+  deleteNode: async (repertoireId: string, openingId: string, node: string): Promise<void> => {
+    await api.delete(`/repertoires/${repertoireId}/openings/${openingId}/nodes/${node}`);
+  },
+
+  promoteNode: async (repertoireId: string, openingId: string, node: string): Promise<NodeResponse> => {
+    const response = await api.post<NodeResponse>(`/repertoires/${repertoireId}/openings/${openingId}/nodes/${node}/promote`);
+    return response.data;
+  },
+
+  reorderNode: async (repertoireId: string, openingId: string, node: string, index: number): Promise<NodeResponse> => {
+    const response = await api.put<NodeResponse>(`/repertoires/${repertoireId}/openings/${openingId}/nodes/${node}/index`, { index });
+    return response.data;
+  },
+
+  updateNodeComment: async (repertoireId: string, openingId: string, node: string, comment: string): Promise<NodeResponse> => {
+    const response = await api.put<NodeResponse>(`/repertoires/${repertoireId}/openings/${openingId}/nodes/${node}/comment`, { comment });
+    return response.data;
+  },
 };
@@ -1,7 +1,7 @@
 import { useState } from 'react';
 import { Link, useNavigate } from 'react-router-dom';
 import { motion } from 'framer-motion';
-import { Crown, Mail, Lock, ArrowRight, AlertCircle, Zap } from 'lucide-react';
+import { Crown, Mail, Lock, ArrowRight, AlertCircle, Zap, ShieldCheck } from 'lucide-react';
 import { useAuth } from '../../hooks/useAuth';
 import { Button } from '../ui/Button';
 import { Input } from '../ui/Input';
@@ -9,9 +9,11 @@ import { Input } from '../ui/Input';
 export default function LoginPage() {
   const [email, setEmail] = useState('');
   const [password, setPassword] = useState('');
+  const [code, setCode] = useState('');
+  const [challengeToken, setChallengeToken] = useState('');
   const [error, setError] = useState('');
   const [isLoading, setIsLoading] = useState(false);
-  const { login } = useAuth();
+  const { login, completeTwoFactor } = useAuth();
   const navigate = useNavigate();
 
   const handleSubmit = async (e: React.FormEvent) => {
@@ -20,10 +22,35 @@ export default function LoginPage() {
     setIsLoading(true);
 
     try {
-      await login(email, password);
+      if (challengeToken) {
+        await completeTwoFactor(challengeToken, code.trim());
+        navigate('/');
+        return;
+      }
+      const challenge = await login(email, password);
+      if (challenge) {
+        setChallengeToken(challenge.challenge_token);
+        return;
+      }
       navigate('/');
-    } catch {
-      setError('Invalid email or password');
+    } catch (err: unknown) {
+      const axiosErr = err as {
+        response?: { status?: number; data?: { error?: string; retry_after?: number } };
+      };
+      const retryAfter = axiosErr?.response?.data?.retry_after;
+      if (axiosErr?.response?.status === 429 && retryAfter) {
+        const minutes = Math.ceil(retryAfter / 60);
+        setError(`Too many failed attempts. Try again in ${minutes} minute${minutes === 1 ? '' : 's'}.`);
+      } else if (challengeToken && axiosErr?.response?.data?.error === 'invalid or expired challenge') {
+        // Start over from the password
+        setChallengeToken('');
+        setCode('');
+        setError('Your sign-in expired. Please sign in again.');
+      } else if (challengeToken) {
+        setError('Invalid code');
+      } else {
+        setError('Invalid email or password');
+      }
     } finally {
       setIsLoading(false);
     }
@@ -150,29 +177,43 @@ export default function LoginPage() {
                     </motion.div>
                   )}
 
-                  <div className="flex flex-col gap-5">
+                  {challengeToken ? (
                     <Input
-                      type="email"
-                      value={email}
-                      onChange={(e) => setEmail(e.target.value)}
-                      label="Email Address"
-                      leftIcon={<Mail className="w-5 h-5" />}
-                      placeholder="player@example.com"
-                      autoComplete="email"
+                      type="text"
+                      value={code}
+                      onChange={(e) => setCode(e.target.value)}
+                      label="Authentication Code"
+                      leftIcon={<ShieldCheck className="w-5 h-5" />}
+                      placeholder="6-digit code or recovery code"
+                      autoComplete="one-time-code"
+                      autoFocus
                       required
                     />
-
-                    <Input
-                      type="password"
-                      value={password}
-                      onChange={(e) => setPassword(e.target.value)}
-                      label="Password"
-                      leftIcon={<Lock className="w-5 h-5" />}
-                      placeholder="••••••••"
-                      autoComplete="current-password"
-                      required
-                    />
-                  </div>
+                  ) : (
+                    <div className="flex flex-col gap-5">
+                      <Input
+                        type="email"
+                        value={email}
+                        onChange={(e) => setEmail(e.target.value)}
+                        label="Email Address"
+                        leftIcon={<Mail className="w-5 h-5" />}
+                        placeholder="player@example.com"
+                        autoComplete="email"
+                        required
+                      />
+
+                      <Input
+                        type="password"
+                        value={password}
+                        onChange={(e) => setPassword(e.target.value)}
+                        label="Password"
+                        leftIcon={<Lock className="w-5 h-5" />}
+                        placeholder="••••••••"
+                        autoComplete="current-password"
+                        required
+                      />
+                    </div>
+                  )}
 
                   <Button
                     type="submit"
@@ -1,11 +1,10 @@
-import { useState, useEffect, useCallback } from 'react';
+import { useState, useEffect } from 'react';
 import { useParams, useNavigate, Link } from 'react-router-dom';
 import { motion, AnimatePresence } from 'framer-motion';
 import { Chessboard } from 'react-chessboard';
 import { Chess } from 'chess.js';
 import { practiceApi } from '../../api/practice';
 import { repertoireApi } from '../../api/repertoire';
-import { RepertoireNavigator } from '../../utils/repertoireNavigator';
 import { CATEGORY_COLORS, CATEGORY_LABELS } from '../../utils/moveEvaluation';
 import type { PracticeSession as PracticeSessionType, MoveCategory } from '../../types/practice';
 import type { Repertoire } from '../../types/repertoire';
@@ -48,7 +47,6 @@ export default function PracticeSession() {
   const navigate = useNavigate();
   const [session, setSession] = useState<PracticeSessionType | null>(null);
   const [repertoire, setRepertoire] = useState<Repertoire | null>(null);
-  const [navigator, setNavigator] = useState<RepertoireNavigator | null>(null);
   const [game] = useState(new Chess());
   const [fen, setFen] = useState(game.fen());
   const [isLoading, setIsLoading] = useState(true);
@@ -73,19 +71,14 @@ export default function PracticeSession() {
       const repertoireData = await repertoireApi.get(sessionData.repertoire_id);
       setRepertoire(repertoireData);
 
-      // Initialize navigator with config
-      const nav = new RepertoireNavigator(
-        repertoireData,
-        sessionData.color,
-        sessionData.config?.allow_variations || false
-      );
-      setNavigator(nav);
-
-      // If playing black, AI makes first move
-      if (sessionData.color === 'black') {
-        // Small delay to ensure state is set
-        setTimeout(() => makeAiMoveWithNav(nav), 300);
+      // Replay the line chosen by the server, including the opponent's first move
+      if (sessionData.starting_fen) {
+        game.load(sessionData.starting_fen);
       }
+      for (const san of sessionData.line || []) {
+        game.move(san);
+      }
+      setFen(game.fen());
     } catch (error) {
       console.error('Failed to load session:', error);
       navigate('/practice');
@@ -94,55 +87,43 @@ export default function PracticeSession() {
     }
   };
 
-  // Separate function for initial AI move when navigator isn't in state yet
-  const makeAiMoveWithNav = useCallback(async (nav: RepertoireNavigator) => {
+  const submitMove = async (fenBefore: string, san: string) => {
     setAiThinking(true);
     try {
-      const currentFen = game.fen();
-      const move = nav.getOpponentMove(currentFen);
-
-      if (move) {
-        const moveResult = game.move({
-          from: move.substring(0, 2),
-          to: move.substring(2, 4),
-          promotion: move.length === 5 ? move[4] : undefined,
-        });
-
-        if (moveResult) {
-          setFen(game.fen());
-        }
+      const result = await practiceApi.submitMove(sessionId!, {
+        fen_before: fenBefore,
+        user_move: san,
+      });
+
+      const moveResult: MoveResult = { move: result.move.user_move, category: result.move.category };
+      setMoveResults(prev => [...prev, moveResult]);
+      setLastMoveResult(moveResult);
+
+      if (!result.correct) {
+        // Wrong move - undo and show feedback
+        game.undo();
+        setFen(game.fen());
+        setWrongMove({ played: result.move.user_move, expected: result.expected_moves });
+        return;
       }
-    } finally {
-      setAiThinking(false);
-    }
-  }, [game]);
 
-  const makeAiMove = useCallback(async () => {
-    if (!navigator) return;
+      setWrongMove(null);
+      if (result.opponent_move) {
+        game.move(result.opponent_move.move);
+        setFen(game.fen());
+      }
 
-    setAiThinking(true);
-    try {
-      const currentFen = game.fen();
-      const move = navigator.getOpponentMove(currentFen);
-
-      if (move) {
-        const moveResult = game.move({
-          from: move.substring(0, 2),
-          to: move.substring(2, 4),
-          promotion: move.length === 5 ? move[4] : undefined,
-        });
-
-        if (moveResult) {
-          setFen(game.fen());
-        }
-      } else {
-        // Out of repertoire - end session
+      if (result.session_complete) {
         await endSession();
       }
+    } catch (error) {
+      console.error('Failed to submit move:', error);
+      game.undo();
+      setFen(game.fen());
     } finally {
       setAiThinking(false);
     }
-  }, [navigator, game]);
+  };
 
   const handleMove = (sourceSquare: string, targetSquare: string): boolean => {
     if (aiThinking || isSessionComplete || wrongMove) return false;
@@ -166,60 +147,10 @@ export default function PracticeSession() {
         return false;
       }
 
-      // Check if move matches repertoire
-      const isCorrect = navigator?.isRepertoireMove(fenBefore, move.san);
-
-      if (isCorrect) {
-        // Correct move - continue
-        setFen(game.fen());
-        setWrongMove(null);
-
-        const result: MoveResult = { move: move.san, category: 'repertoire' };
-        setMoveResults(prev => [...prev, result]);
-        setLastMoveResult(result);
-
-        // Record correct move
-        practiceApi.submitMove(sessionId!, {
-          fen_before: fenBefore,
-          fen_after: game.fen(),
-          user_move: move.san,
-          category: 'repertoire',
-        });
-
-        // Check if session should end
-        if (shouldEndSession()) {
-          endSession();
-        } else {
-          // AI responds after short delay
-          setTimeout(() => makeAiMove(), 300);
-        }
-        return true;
-      } else {
-        // Wrong move - undo and show feedback
-        game.undo();
-
-        const expectedMoves = navigator?.getExpectedMoves(fenBefore) || [];
-        setWrongMove({
-          played: move.san,
-          expected: expectedMoves.map(m => m.move),
-        });
-
-        // Record mistake for stats
-        const result: MoveResult = { move: move.san, category: 'mistake' };
-        setMoveResults(prev => [...prev, result]);
-        setLastMoveResult(result);
-
-        // Submit mistake to backend
-        practiceApi.submitMove(sessionId!, {
-          fen_before: fenBefore,
-          fen_after: fenBefore, // Same position since we undid
-          user_move: move.san,
-          expected_move: expectedMoves.find(m => m.is_main_line)?.move,
-          category: 'mistake',
-        });
-
-        return false;
-      }
+      // Show the move right away; the server decides whether it stands
+      setFen(game.fen());
+      submitMove(fenBefore, move.san);
+      return true;
     } catch {
       return false;
     }
@@ -230,34 +161,6 @@ export default function PracticeSession() {
     setLastMoveResult(null);
   };
 
-  const shouldEndSession = useCallback((): boolean => {
-    const maxMoves = session?.config?.max_moves || 30;
-    const moveCount = game.history().length;
-
-    // Check move limit (0 = unlimited)
-    if (maxMoves > 0 && moveCount >= maxMoves) {
-      return true;
-    }
-
-    // Check game over
-    if (game.isGameOver()) {
-      return true;
-    }
-
-    // Check if out of repertoire for AI's next move
-    const aiMove = navigator?.getOpponentMove(game.fen());
-    if (!aiMove) {
-      // In strict mode, end if out of repertoire
-      if (session?.config?.difficulty === 'strict') {
-        return true;
-      }
-      // In flexible mode, also end since we have no moves to make
-      return true;
-    }
-
-    return false;
-  }, [session, game, navigator]);
-
   const endSession = async () => {
     try {
       const finalSession = await practiceApi.end(sessionId!);
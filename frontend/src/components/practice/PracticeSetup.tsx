@@ -4,7 +4,7 @@ import { motion, AnimatePresence } from 'framer-motion';
 import { repertoireApi } from '../../api/repertoire';
 import { practiceApi } from '../../api/practice';
 import type { Repertoire } from '../../types/repertoire';
-import type { PracticeConfig } from '../../types/practice';
+import type { PracticeConfig, OpponentPolicy } from '../../types/practice';
 import { Play, Shuffle, Target, ChevronLeft, ChevronRight, Swords, BookOpen } from 'lucide-react';
 import { Header } from '../layout/Header';
 import { PageContainer } from '../layout/PageContainer';
@@ -409,6 +409,24 @@ export default function PracticeSetup() {
                           </div>
                         </label>
                       </div>
+
+                      {/* Opponent Policy */}
+                      {config.allow_variations && (
+                        <div>
+                          <label className="block text-sm font-medium text-white/80 mb-2">
+                            Opponent Replies
+                          </label>
+                          <select
+                            value={config.opponent_policy || 'uniform'}
+                            onChange={(e) => setConfig({ ...config, opponent_policy: e.target.value as OpponentPolicy })}
+                            className="w-full p-3 rounded-xl bg-[#1a1a2e]/60 border border-white/10 text-white focus:border-primary focus:outline-none"
+                          >
+                            <option value="uniform">Any prepared line</option>
+                            <option value="weighted">Focus on lines I get wrong</option>
+                            <option value="mainline">Main line only</option>
+                          </select>
+                        </div>
+                      )}
                     </div>
                   )}
 
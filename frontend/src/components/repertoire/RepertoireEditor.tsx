@@ -87,15 +87,20 @@ export default function RepertoireEditor() {
     const moves: MoveNode[] = [];
     const tempGame = new Chess();
 
+    // Each move is the child of the previous one so the line forms a tree
+    let siblings = moves;
     for (const san of moveHistory) {
       const move = tempGame.move(san);
       if (move) {
-        moves.push({
+        const node: MoveNode = {
           fen: tempGame.fen(),
           move: move.san,
           uci: `${move.from}${move.to}${move.promotion || ''}`,
           is_main_line: true,
-        });
+          children: [],
+        };
+        siblings.push(node);
+        siblings = node.children!;
       }
     }
 
@@ -11,7 +11,7 @@ export interface BadgeProps {
 }
 
 const categoryToDaisyUIMap: Record<MoveCategory, string> = {
-  book: 'badge-accent',      // purple
+  repertoire: 'badge-accent', // purple
   best: 'badge-success',     // green
   good: 'badge-info',        // cyan
   inaccuracy: 'badge-warning', // yellow
@@ -32,7 +32,7 @@ export function Badge({
     : 'badge-neutral';
 
   const glowClass = glow && variant === 'move' && category ? {
-    book: 'glow-purple',
+    repertoire: 'glow-purple',
     best: 'glow-success',
     good: 'glow-primary',
     inaccuracy: 'glow-warning',
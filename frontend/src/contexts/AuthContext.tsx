@@ -1,12 +1,13 @@
 import { createContext, useState, useEffect, type ReactNode } from 'react';
 import { authApi } from '../api/auth';
-import type { User } from '../types/auth';
+import type { AuthResponse, TwoFactorChallenge, User } from '../types/auth';
 
 interface AuthContextType {
   user: User | null;
   isAuthenticated: boolean;
   isLoading: boolean;
-  login: (email: string, password: string) => Promise<void>;
+  login: (email: string, password: string) => Promise<TwoFactorChallenge | null>;
+  completeTwoFactor: (challengeToken: string, code: string) => Promise<void>;
   register: (email: string, password: string, username: string) => Promise<void>;
   logout: () => void;
 }
@@ -33,21 +34,37 @@ export function AuthProvider({ children }: { children: ReactNode }) {
     }
   }, []);
 
-  const login = async (email: string, password: string) => {
-    const response = await authApi.login({ email, password });
+  const signIn = (response: AuthResponse) => {
     localStorage.setItem('accessToken', response.access_token);
     localStorage.setItem('refreshToken', response.refresh_token);
     setUser(response.user);
   };
 
+  // Resolves to the challenge when the account needs a second factor,
+  // which completeTwoFactor then answers
+  const login = async (email: string, password: string) => {
+    const response = await authApi.login({ email, password });
+    if ('two_factor_required' in response) {
+      return response;
+    }
+    signIn(response);
+    return null;
+  };
+
+  const completeTwoFactor = async (challengeToken: string, code: string) => {
+    signIn(await authApi.loginTwoFactor(challengeToken, code));
+  };
+
   const register = async (email: string, password: string, username: string) => {
-    const response = await authApi.register({ email, password, username });
-    localStorage.setItem('accessToken', response.access_token);
-    localStorage.setItem('refreshToken', response.refresh_token);
-    setUser(response.user);
+    signIn(await authApi.register({ email, password, username }));
   };
 
   const logout = () => {
+    const refreshToken = localStorage.getItem('refreshToken');
+    if (refreshToken) {
+      // Best effort: the session expires on its own if this fails
+      authApi.logout(refreshToken).catch(() => {});
+    }
     localStorage.removeItem('accessToken');
     localStorage.removeItem('refreshToken');
     setUser(null);
@@ -60,6 +77,7 @@ export function AuthProvider({ children }: { children: ReactNode }) {
         isAuthenticated: !!user,
         isLoading,
         login,
+        completeTwoFactor,
         register,
         logout,
       }}
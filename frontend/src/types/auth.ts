@@ -1,6 +1,9 @@
 export interface User {
   id: string;
   email: string;
+  email_verified: boolean;
+  pending_email?: string;
+  two_factor_enabled: boolean;
   username: string;
   preferences: UserPreferences;
   created_at: string;
@@ -19,6 +22,34 @@ export interface AuthResponse {
   user: User;
 }
 
+// Login answers with this instead of tokens when the account has two-factor
+// authentication on.
+export interface TwoFactorChallenge {
+  two_factor_required: true;
+  challenge_token: string;
+  expires_at: string;
+}
+
+export interface TwoFactorSetup {
+  secret: string;
+  provisioning_uri: string;
+}
+
+export interface Session {
+  id: string;
+  user_agent: string;
+  ip: string;
+  created_at: string;
+  last_used_at: string;
+  expires_at: string;
+  current: boolean;
+}
+
+export interface UpdateUserRequest {
+  username?: string;
+  preferences?: Partial<UserPreferences>;
+}
+
 export interface LoginRequest {
   email: string;
   password: string;
@@ -1,5 +1,5 @@
-// Simplified categories for repertoire-only drilling
-export type MoveCategory = 'repertoire' | 'mistake';
+// Repertoire moves are 'repertoire'; other moves are judged from engine evals
+export type MoveCategory = 'repertoire' | 'best' | 'good' | 'inaccuracy' | 'mistake' | 'blunder';
 
 export interface PracticeSession {
   id: string;
@@ -10,9 +10,15 @@ export interface PracticeSession {
   color: 'white' | 'black';
   started_at: string;
   ended_at?: string;
+  starting_fen?: string;
+  current_fen?: string;  // Position the user is to move in
+  line?: string[];       // SAN of every move played so far, both sides
   moves: PracticeMove[];
   stats: PracticeStats;
   config: PracticeConfig;
+  root_node_id?: string; // Node the drill starts after
+  root_path?: string;
+  root_line?: string[];  // SAN from the opening's start to the root
 }
 
 export interface PracticeMove {
@@ -22,6 +28,8 @@ export interface PracticeMove {
   user_move: string;
   expected_move?: string;
   category: MoveCategory;
+  centipawn_loss?: number;
+  accuracy?: number;  // 0-100
 }
 
 export interface PracticeStats {
@@ -35,6 +43,8 @@ export interface PracticeStats {
 export interface StartPracticeRequest {
   repertoire_id: string;
   opening_id?: string;
+  node?: string;  // Node ID or path in the opening to start after
+  fen?: string;   // Position to start from
   mode: 'specific' | 'random';
   config?: PracticeConfig;
 }
@@ -43,12 +53,32 @@ export interface PracticeConfig {
   max_moves: number;           // 0 = unlimited
   difficulty: 'strict' | 'flexible';
   allow_variations: boolean;
+  opponent_policy?: OpponentPolicy;
 }
 
+// How the server picks the opponent's reply when several are prepared
+export type OpponentPolicy = 'mainline' | 'uniform' | 'weighted';
+
+// The server judges the move against the repertoire and replies for the opponent
 export interface SubmitMoveRequest {
-  fen_before: string;
-  fen_after: string;
+  fen_before?: string;
   user_move: string;
-  expected_move?: string;
-  category: MoveCategory;
+  eval_before?: number;  // Centipawns from White's point of view
+  eval_after?: number;
+}
+
+export interface OpponentMove {
+  move: string;
+  uci: string;
+  fen: string;
+}
+
+export interface SubmitMoveResponse {
+  move: PracticeMove;
+  correct: boolean;
+  expected_moves: string[];
+  opponent_move?: OpponentMove;
+  current_fen: string;
+  line_complete: boolean;
+  session_complete: boolean;
 }
@@ -4,6 +4,7 @@ export interface Repertoire {
   name: string;
   color: 'white' | 'black';
   openings: Opening[];
+  version: number;  // Sent back as If-Match to detect concurrent edits
   created_at: string;
   updated_at: string;
 }
@@ -13,10 +14,12 @@ export interface Opening {
   name: string;
   eco: string;
   starting_fen: string;
+  notes?: string; // PGN game comment
   moves: MoveNode[];
 }
 
 export interface MoveNode {
+  id?: string;  // Assigned by the server, stable across edits
   fen: string;
   move: string;
   uci: string;
@@ -31,8 +34,24 @@ export interface CreateRepertoireRequest {
 }
 
 export interface AddOpeningRequest {
-  name: string;
+  name?: string; // filled in from the ECO table when empty
   eco?: string;
   starting_fen?: string;
+  notes?: string;
   moves?: MoveNode[];
 }
+
+// A node addressed by ID or by path in its opening's move tree, e.g. "0.2.1"
+export interface NodeResponse {
+  opening_id: string;
+  path: string;
+  line: string[];
+  fen_before: string;
+  node: MoveNode;
+}
+
+export interface AddNodeRequest {
+  parent?: string;  // Node ID or path, omitted for a first move
+  move: string;     // SAN or UCI
+  comment?: string;
+}
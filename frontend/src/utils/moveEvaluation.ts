@@ -1,14 +1,22 @@
 import type { MoveCategory } from '../types/practice';
 
-// Simplified for repertoire-only drilling
-// No engine evaluation needed - just correct/incorrect
+// Repertoire moves are correct or not; moves off the repertoire are
+// classified by the server when engine evals are sent with them
 
 export const CATEGORY_COLORS: Record<MoveCategory, string> = {
   repertoire: '#a855f7', // Purple - correct move
+  best: '#22c55e',       // Green
+  good: '#06b6d4',       // Cyan
+  inaccuracy: '#eab308', // Yellow
   mistake: '#ef4444',    // Red - wrong move
+  blunder: '#ec4899',    // Pink
 };
 
 export const CATEGORY_LABELS: Record<MoveCategory, string> = {
   repertoire: 'Correct',
+  best: 'Best',
+  good: 'Good',
+  inaccuracy: 'Inaccuracy',
   mistake: 'Try Again',
+  blunder: 'Blunder',
 };